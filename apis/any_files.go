@@ -11,13 +11,17 @@ import (
 
 	// so we use this beta one
 
+	"github.com/webitel/storage/apis/helper"
 	"github.com/webitel/storage/model"
+	"github.com/webitel/storage/transcoding"
 	"github.com/webitel/storage/utils"
 )
 
 func (api *API) InitAnyFile() {
 	api.PublicRoutes.AnyFiles.Handle("/{id}/stream", api.ApiHandler(streamAnyFile)).Methods("GET")
 	api.PublicRoutes.AnyFiles.Handle("/{id}/download", api.ApiHandler(downloadAnyFile)).Methods("GET")
+	api.PublicRoutes.AnyFiles.Handle("/{id}/image", api.ApiHandler(imageAnyFile)).Methods("GET")
+	api.PublicRoutes.AnyFiles.Handle("/{id}/hls", api.ApiHandler(hlsAnyFile)).Methods("GET")
 	api.PublicRoutes.AnyFiles.Handle("/stream", api.ApiHandler(streamAnyFileByQuery)).Methods("GET")
 	api.PublicRoutes.AnyFiles.Handle("/download", api.ApiHandler(downloadAnyFileByQuery)).Methods("GET")
 }
@@ -53,6 +57,7 @@ func streamAnyFile(c *Context, w http.ResponseWriter, r *http.Request) {
 	var ranges []HttpRange
 	var offset int64 = 0
 	var reader io.ReadCloser
+	var opts *transcoding.AudioOptions
 
 	if id, err = strconv.Atoi(c.Params.Id); err != nil {
 		c.SetInvalidUrlParam("id")
@@ -61,10 +66,19 @@ func streamAnyFile(c *Context, w http.ResponseWriter, r *http.Request) {
 
 	domainId, _ = strconv.Atoi(c.Params.Domain)
 
+	if opts, c.Err = helper.ConvertOptions(r); c.Err != nil {
+		return
+	}
+
 	if file, backend, c.Err = c.App.GetFileWithProfile(int64(domainId), int64(id)); c.Err != nil {
 		return
 	}
 
+	if opts != nil {
+		streamConvertedFile(c, w, r, file, backend, opts)
+		return
+	}
+
 	if ranges, c.Err = parseRange(r.Header.Get("Range"), file.Size); c.Err != nil {
 		return
 	}
@@ -82,7 +96,7 @@ func streamAnyFile(c *Context, w http.ResponseWriter, r *http.Request) {
 
 	}
 
-	if reader, c.Err = backend.Reader(file, offset); c.Err != nil {
+	if reader, c.Err = c.App.FileReader(r.Context(), backend, file, offset); c.Err != nil {
 		return
 	}
 
@@ -95,10 +109,77 @@ func streamAnyFile(c *Context, w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Accept-Ranges", "bytes")
 	w.Header().Set("Content-Type", file.MimeType)
 
+	helper.SetContentSecurity(w, file.GetViewName(), c.App.FileContentSecurity(file.Domain(), file.GetChannel(), file.GetMimeType()))
 	w.WriteHeader(code)
 	io.CopyN(w, reader, sendSize)
 }
 
+// hlsAnyFile serves the HLS master playlist, a variant playlist (playlist=) or a segment (segment=).
+// Links in the playlists are signed the same way as the link to the playlist itself
+func hlsAnyFile(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequireId()
+	c.RequireDomain()
+	c.RequireExpire()
+	c.RequireSignature()
+
+	if c.Err != nil {
+		return
+	}
+
+	if c.Params.Expires < model.GetMillis() {
+		c.SetSessionExpire()
+		return
+	}
+
+	// region VALIDATION
+	validationString := createValidationKey(*r.URL)
+	if !c.App.ValidateSignature(model.AnyFileRouteName+validationString, c.Params.Signature) {
+		c.SetSessionErrSignature()
+		return
+	}
+	// endregion
+
+	var file *model.File
+	var backend utils.FileBackend
+	var id, domainId int
+	var err error
+	var data []byte
+
+	if id, err = strconv.Atoi(c.Params.Id); err != nil {
+		c.SetInvalidUrlParam("id")
+		return
+	}
+
+	domainId, _ = strconv.Atoi(c.Params.Domain)
+
+	if file, backend, c.Err = c.App.GetFileWithProfile(int64(domainId), int64(id)); c.Err != nil {
+		return
+	}
+
+	query := r.URL.Query()
+	if segment := query.Get("segment"); segment != "" {
+		var variant *model.FileVariant
+		if variant, c.Err = c.App.GetHLSFile(r.Context(), file, segment); c.Err != nil {
+			return
+		}
+
+		w.Header().Set("Cache-Control", "private, max-age=86400")
+		streamFileVariant(c, w, r, file, backend, variant, segment)
+		return
+	}
+
+	if data, c.Err = c.App.SignedHLSPlaylist(r.Context(), file, backend, query.Get("playlist")); c.Err != nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", transcoding.HLSPlaylistMimeType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Cache-Control", "no-store")
+	helper.SetDefaultContentSecurity(w)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
 func downloadAnyFile(c *Context, w http.ResponseWriter, r *http.Request) {
 	c.RequireId()
 	c.RequireDomain()
@@ -142,7 +223,7 @@ func downloadAnyFile(c *Context, w http.ResponseWriter, r *http.Request) {
 	sendSize := file.Size
 	code := http.StatusOK
 
-	if reader, c.Err = backend.Reader(file, 0); c.Err != nil {
+	if reader, c.Err = c.App.FileReader(r.Context(), backend, file, 0); c.Err != nil {
 		return
 	}
 
@@ -152,10 +233,85 @@ func downloadAnyFile(c *Context, w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", file.MimeType)
 	w.Header().Set("Content-Length", strconv.FormatInt(sendSize, 10))
 
+	helper.SetContentSecurity(w, file.GetViewName(), c.App.FileContentSecurity(file.Domain(), file.GetChannel(), file.GetMimeType()))
 	w.WriteHeader(code)
 	io.Copy(w, reader)
 }
 
+// imageAnyFile /any/file/{id}/image?w=&h=&fit=&format=, the image variant is created on the first request
+func imageAnyFile(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequireId()
+	c.RequireDomain()
+	c.RequireExpire()
+	c.RequireSignature()
+
+	if c.Err != nil {
+		return
+	}
+
+	if c.Params.Expires < model.GetMillis() {
+		c.SetSessionExpire()
+		return
+	}
+
+	// region VALIDATION
+	validationString := createValidationKey(*r.URL)
+	// dynamic parameters validation
+	if !c.App.ValidateSignature(model.AnyFileRouteName+validationString, c.Params.Signature) {
+		c.SetSessionErrSignature()
+		return
+	}
+	// endregion
+
+	var variant *model.FileVariant
+	var backend utils.FileBackend
+	var id, domainId int
+	var err error
+	var reader io.ReadCloser
+
+	if id, err = strconv.Atoi(c.Params.Id); err != nil {
+		c.SetInvalidUrlParam("id")
+		return
+	}
+
+	domainId, _ = strconv.Atoi(c.Params.Domain)
+
+	q := r.URL.Query()
+	v := &model.ImageVariant{
+		Fit:    q.Get("fit"),
+		Format: q.Get("format"),
+	}
+	if q.Get("w") != "" {
+		if v.Width, err = strconv.Atoi(q.Get("w")); err != nil {
+			c.SetInvalidUrlParam("w")
+			return
+		}
+	}
+	if q.Get("h") != "" {
+		if v.Height, err = strconv.Atoi(q.Get("h")); err != nil {
+			c.SetInvalidUrlParam("h")
+			return
+		}
+	}
+
+	if variant, backend, c.Err = c.App.GetImageVariant(r.Context(), int64(domainId), int64(id), v); c.Err != nil {
+		return
+	}
+
+	if reader, c.Err = c.App.FileReader(r.Context(), backend, variant, 0); c.Err != nil {
+		return
+	}
+
+	defer reader.Close()
+
+	w.Header().Set("Content-Type", variant.MimeType)
+	w.Header().Set("Content-Length", strconv.FormatInt(variant.Size, 10))
+	helper.SetContentSecurity(w, variant.Name, c.App.FileContentSecurity(variant.Domain(), variant.GetChannel(), variant.GetMimeType()))
+
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, reader)
+}
+
 func streamAnyFileByQuery(c *Context, w http.ResponseWriter, r *http.Request) {
 	c.RequireDomain()
 	c.RequireExpire()
@@ -218,7 +374,7 @@ func streamAnyFileByQuery(c *Context, w http.ResponseWriter, r *http.Request) {
 
 	}
 
-	if reader, c.Err = backend.Reader(file, offset); c.Err != nil {
+	if reader, c.Err = c.App.FileReader(r.Context(), backend, file, offset); c.Err != nil {
 		return
 	}
 
@@ -231,6 +387,7 @@ func streamAnyFileByQuery(c *Context, w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Accept-Ranges", "bytes")
 	w.Header().Set("Content-Type", file.MimeType)
 
+	helper.SetContentSecurity(w, file.GetViewName(), c.App.FileContentSecurity(file.Domain(), file.GetChannel(), file.GetMimeType()))
 	w.WriteHeader(code)
 	io.CopyN(w, reader, sendSize)
 }
@@ -293,9 +450,12 @@ func downloadAnyFileByQuery(c *Context, w http.ResponseWriter, r *http.Request)
 			c.SetInvalidUrlParam("uuid")
 			return
 		}
-		backend = c.App.MediaFileStore
 		mediaId, _ := strconv.Atoi(uuid)
-		file, c.Err = c.App.GetMediaFile(int64(domainId), mediaId)
+		var mediaFile *model.MediaFile
+		if mediaFile, c.Err = c.App.GetMediaFile(int64(domainId), mediaId); c.Err == nil {
+			file = mediaFile
+			backend, c.Err = c.App.MediaFileBackend(mediaFile)
+		}
 	case "file":
 		if uuid == "" {
 			c.SetInvalidUrlParam("uuid")
@@ -337,6 +497,7 @@ func downloadAnyFileByQuery(c *Context, w http.ResponseWriter, r *http.Request)
 		}
 
 		w.Header().Set("Content-Type", "image/png")
+		helper.SetDefaultContentSecurity(w)
 		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", model.EncodeURIComponent("code.png")))
 		io.Copy(w, buf)
 		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(buf.Bytes())))
@@ -364,7 +525,7 @@ func downloadAnyFileByQuery(c *Context, w http.ResponseWriter, r *http.Request)
 	sendSize := file.GetSize()
 	code := http.StatusOK
 
-	if reader, c.Err = backend.Reader(file, 0); c.Err != nil {
+	if reader, c.Err = c.App.FileReader(r.Context(), backend, file, 0); c.Err != nil {
 		return
 	}
 
@@ -374,6 +535,7 @@ func downloadAnyFileByQuery(c *Context, w http.ResponseWriter, r *http.Request)
 	w.Header().Set("Content-Type", file.GetMimeType())
 	w.Header().Set("Content-Length", strconv.FormatInt(sendSize, 10))
 
+	helper.SetContentSecurity(w, file.GetStoreName(), c.App.FileContentSecurity(file.Domain(), file.GetChannel(), file.GetMimeType()))
 	w.WriteHeader(code)
 	io.Copy(w, reader)
 }
@@ -10,7 +10,9 @@ import (
 
 	"github.com/webitel/engine/auth_manager"
 	engine "github.com/webitel/engine/model"
+	"github.com/webitel/storage/apis/helper"
 	"github.com/webitel/storage/model"
+	"github.com/webitel/storage/transcoding"
 	"github.com/webitel/storage/utils"
 	"github.com/webitel/storage/web"
 )
@@ -20,6 +22,10 @@ var errNoPermissionRecordFile = engine.NewForbiddenError("call.recordings.access
 func (api *API) InitCallRecordingsFiles() {
 	api.PublicRoutes.CallRecordingsFiles.Handle("/{id}/stream", api.ApiSessionRequired(streamRecordFile)).Methods("GET")
 	api.PublicRoutes.CallRecordingsFiles.Handle("/{id}/download", api.ApiSessionRequired(downloadRecordFile)).Methods("GET")
+	api.PublicRoutes.CallRecordingsFiles.Handle("/{id}/waveform", api.ApiSessionRequired(waveformRecordFile)).Methods("GET")
+	api.PublicRoutes.CallRecordingsFiles.Handle("/{id}/clip", api.ApiSessionRequired(clipRecordFile)).Methods("POST")
+	api.PublicRoutes.CallRecordingsFiles.Handle("/{id}/channel", api.ApiSessionRequired(channelRecordFile)).Methods("POST")
+	api.PublicRoutes.CallRecordingsFiles.Handle("/merge", api.ApiSessionRequired(mergeRecordFiles)).Methods("POST")
 }
 
 func streamRecordFile(c *Context, w http.ResponseWriter, r *http.Request) {
@@ -50,6 +56,74 @@ func downloadRecordFile(c *Context, w http.ResponseWriter, r *http.Request) {
 
 }
 
+func waveformRecordFile(c *Context, w http.ResponseWriter, r *http.Request) {
+	isAccessible, appErr := checkCallRecordPermission(c, r)
+	if appErr != nil {
+		c.Err = appErr
+		return
+	}
+	if !isAccessible {
+		c.Err = errNoPermissionRecordFile
+		return
+	}
+
+	c.RequireId()
+	if c.Err != nil {
+		return
+	}
+
+	var file *model.File
+	var backend utils.FileBackend
+	var variant *model.FileVariant
+	var id, domainId int
+	var err error
+	var reader io.ReadCloser
+
+	if id, err = strconv.Atoi(c.Params.Id); err != nil {
+		c.SetInvalidUrlParam("id")
+		return
+	}
+
+	domainId, _ = strconv.Atoi(c.Params.Domain)
+
+	v := &model.WaveformVariant{}
+	if spp := r.URL.Query().Get("samples_per_pixel"); spp != "" {
+		if v.SamplesPerPixel, err = strconv.Atoi(spp); err != nil {
+			c.SetInvalidUrlParam("samples_per_pixel")
+			return
+		}
+	}
+
+	if file, backend, c.Err = c.Ctrl.GetFileWithProfile(&c.Session, int64(domainId), int64(id)); c.Err != nil {
+		return
+	}
+
+	if file.Channel != nil && *file.Channel == model.UploadFileChannelCall {
+		if !allowTimeLimited(r.Context(), c, file.CreatedAt) {
+			c.Err = errNoPermissionRecordFile
+			return
+		}
+	}
+
+	if variant, c.Err = c.App.GetWaveformVariant(r.Context(), file, backend, v); c.Err != nil {
+		return
+	}
+
+	if reader, c.Err = c.App.FileReader(r.Context(), backend, variant, 0); c.Err != nil {
+		return
+	}
+
+	defer reader.Close()
+
+	w.Header().Set("Content-Type", variant.MimeType)
+	w.Header().Set("Content-Length", strconv.FormatInt(variant.Size, 10))
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	helper.SetDefaultContentSecurity(w)
+
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, reader)
+}
+
 func streamFile(c *Context, w http.ResponseWriter, r *http.Request) {
 
 	c.RequireId()
@@ -65,6 +139,7 @@ func streamFile(c *Context, w http.ResponseWriter, r *http.Request) {
 	var ranges []HttpRange
 	var offset int64 = 0
 	var reader io.ReadCloser
+	var opts *transcoding.AudioOptions
 
 	if id, err = strconv.Atoi(c.Params.Id); err != nil {
 		c.SetInvalidUrlParam("id")
@@ -78,6 +153,30 @@ func streamFile(c *Context, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if opts, c.Err = helper.ConvertOptions(r); c.Err != nil {
+		return
+	}
+
+	var clip *model.ClipVariant
+	if clip = clipFromRequest(c, r); c.Err != nil {
+		return
+	}
+
+	var channel *model.ChannelVariant
+	if channel = channelFromRequest(c, r); c.Err != nil {
+		return
+	}
+
+	if clip != nil && (opts != nil || channel != nil) {
+		c.SetInvalidUrlParam("start_sec")
+		return
+	}
+
+	if channel != nil && opts != nil {
+		c.SetInvalidUrlParam("channel")
+		return
+	}
+
 	if file, backend, c.Err = c.Ctrl.GetFileWithProfile(&c.Session, int64(domainId), int64(id)); c.Err != nil {
 		return
 	}
@@ -92,6 +191,15 @@ func streamFile(c *Context, w http.ResponseWriter, r *http.Request) {
 
 	if file.Thumbnail != nil && query.Get("fetch_thumbnail") == "true" {
 		file.BaseFile = file.Thumbnail.BaseFile
+	} else if clip != nil {
+		streamFileClip(c, w, r, file, backend, clip, clipFileName(file, clip))
+		return
+	} else if channel != nil {
+		streamFileChannel(c, w, r, file, backend, channel, false)
+		return
+	} else if opts != nil {
+		streamConvertedFile(c, w, r, file, backend, opts)
+		return
 	}
 
 	if ranges, c.Err = parseRange(r.Header.Get("Range"), file.Size); c.Err != nil {
@@ -111,13 +219,13 @@ func streamFile(c *Context, w http.ResponseWriter, r *http.Request) {
 
 	}
 
-	if reader, c.Err = backend.Reader(file, offset); c.Err != nil {
+	if reader, c.Err = c.App.FileReader(r.Context(), backend, file, offset); c.Err != nil {
 		return
 	}
 
 	defer reader.Close()
 
-	reader, c.Err = c.App.FilePolicyForDownload(file.DomainId, &file.BaseFile, reader)
+	reader, c.Err = c.App.FilePolicyForDownload(r.Context(), file.DomainId, &file.BaseFile, reader)
 	if c.Err != nil {
 		return
 	}
@@ -129,6 +237,7 @@ func streamFile(c *Context, w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Accept-Ranges", "bytes")
 	w.Header().Set("Content-Type", file.MimeType)
 
+	helper.SetContentSecurity(w, file.GetViewName(), c.App.FileContentSecurity(file.Domain(), file.GetChannel(), file.GetMimeType()))
 	w.WriteHeader(code)
 	io.CopyN(w, reader, sendSize)
 }
@@ -159,6 +268,21 @@ func downloadFile(c *Context, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var clip *model.ClipVariant
+	if clip = clipFromRequest(c, r); c.Err != nil {
+		return
+	}
+
+	var channel *model.ChannelVariant
+	if channel = channelFromRequest(c, r); c.Err != nil {
+		return
+	}
+
+	if clip != nil && channel != nil {
+		c.SetInvalidUrlParam("channel")
+		return
+	}
+
 	if file, backend, c.Err = c.Ctrl.GetFileWithProfile(&c.Session, int64(domainId), int64(id)); c.Err != nil {
 		return
 	}
@@ -173,18 +297,29 @@ func downloadFile(c *Context, w http.ResponseWriter, r *http.Request) {
 
 	if file.Thumbnail != nil && query.Get("fetch_thumbnail") == "true" {
 		file.BaseFile = file.Thumbnail.BaseFile
+	} else if clip != nil {
+		name := clipFileName(file, clip)
+		if c.Params.Name != "" {
+			name = c.Params.Name
+		}
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment;  filename=\"%s\"", model.EncodeURIComponent(name)))
+		streamFileClip(c, w, r, file, backend, clip, name)
+		return
+	} else if channel != nil {
+		streamFileChannel(c, w, r, file, backend, channel, true)
+		return
 	}
 
 	sendSize := file.Size
 	code := http.StatusOK
 
-	if reader, c.Err = backend.Reader(file, 0); c.Err != nil {
+	if reader, c.Err = c.App.FileReader(r.Context(), backend, file, 0); c.Err != nil {
 		return
 	}
 
 	defer reader.Close()
 
-	reader, c.Err = c.App.FilePolicyForDownload(file.DomainId, &file.BaseFile, reader)
+	reader, c.Err = c.App.FilePolicyForDownload(r.Context(), file.DomainId, &file.BaseFile, reader)
 	if c.Err != nil {
 		return
 	}
@@ -198,6 +333,7 @@ func downloadFile(c *Context, w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", file.MimeType)
 	w.Header().Set("Content-Length", strconv.FormatInt(sendSize, 10))
 
+	helper.SetContentSecurity(w, name, c.App.FileContentSecurity(file.Domain(), file.GetChannel(), file.GetMimeType()))
 	w.WriteHeader(code)
 	io.Copy(w, reader)
 }
@@ -218,6 +354,11 @@ func allowTimeLimited(ctx context.Context, c *Context, createdAt int64) bool {
 }
 
 func checkCallRecordPermission(c *Context, r *http.Request) (bool, engine.AppError) {
+	return checkCallRecordFilePermission(c, r, c.Params.Id)
+}
+
+// checkCallRecordFilePermission checkCallRecordPermission for a file id that is not the id url parameter
+func checkCallRecordFilePermission(c *Context, r *http.Request, fileId string) (bool, engine.AppError) {
 	if !c.Session.HasAction(auth_manager.PermissionRecordFile) {
 		session := c.Session
 		permission := session.GetPermission(model.PERMISSION_SCOPE_RECORD_FILE)
@@ -225,7 +366,7 @@ func checkCallRecordPermission(c *Context, r *http.Request) (bool, engine.AppErr
 			return false, errNoPermissionRecordFile
 		}
 		if session.UseRBAC(auth_manager.PERMISSION_ACCESS_READ, permission) {
-			id, err := strconv.Atoi(c.Params.Id)
+			id, err := strconv.Atoi(fileId)
 			if err != nil {
 				return false, web.NewInvalidUrlParamError("id")
 			}
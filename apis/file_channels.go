@@ -0,0 +1,144 @@
+package apis
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/webitel/storage/model"
+	"github.com/webitel/storage/utils"
+)
+
+// channelFromRequest reads channel, nil - the whole file is served
+func channelFromRequest(c *Context, r *http.Request) *model.ChannelVariant {
+	channel := r.URL.Query().Get("channel")
+	if channel == "" {
+		return nil
+	}
+
+	v := &model.ChannelVariant{}
+	var err error
+	if v.Channel, err = strconv.Atoi(channel); err != nil {
+		c.SetInvalidUrlParam("channel")
+		return nil
+	}
+
+	return v
+}
+
+// streamFileChannel serves one channel of the recording, access to the file is checked by the caller
+func streamFileChannel(c *Context, w http.ResponseWriter, r *http.Request, file *model.File, backend utils.FileBackend, v *model.ChannelVariant, attachment bool) {
+	var variant *model.FileVariant
+	if variant, c.Err = c.App.GetChannelVariant(r.Context(), file, backend, v); c.Err != nil {
+		return
+	}
+
+	name := file.GetViewName()
+	name = fmt.Sprintf("%s_ch%d%s", strings.TrimSuffix(name, path.Ext(name)), v.Channel, path.Ext(variant.Key))
+	if attachment {
+		if c.Params.Name != "" {
+			name = c.Params.Name
+		}
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment;  filename=\"%s\"", model.EncodeURIComponent(name)))
+	}
+
+	streamFileVariant(c, w, r, file, backend, variant, name)
+}
+
+// channelRecordFile creates a derived file with one channel of the recording
+func channelRecordFile(c *Context, w http.ResponseWriter, r *http.Request) {
+	isAccessible, appErr := checkCallRecordPermission(c, r)
+	if appErr != nil {
+		c.Err = appErr
+		return
+	}
+	if !isAccessible {
+		c.Err = errNoPermissionRecordFile
+		return
+	}
+
+	c.RequireId()
+	if c.Err != nil {
+		return
+	}
+
+	var file *model.File
+	var backend utils.FileBackend
+	var variant *model.FileVariant
+	var v model.ChannelVariant
+	var id int
+	var err error
+
+	if id, err = strconv.Atoi(c.Params.Id); err != nil {
+		c.SetInvalidUrlParam("id")
+		return
+	}
+
+	if err = json.NewDecoder(r.Body).Decode(&v); err != nil {
+		c.SetInvalidParam("body")
+		return
+	}
+
+	if file, backend, c.Err = c.Ctrl.GetFileWithProfile(&c.Session, 0, int64(id)); c.Err != nil {
+		return
+	}
+
+	if file.Channel != nil && *file.Channel == model.UploadFileChannelCall {
+		if !allowTimeLimited(r.Context(), c, file.CreatedAt) {
+			c.Err = errNoPermissionRecordFile
+			return
+		}
+	}
+
+	if variant, c.Err = c.App.GetChannelVariant(r.Context(), file, backend, &v); c.Err != nil {
+		return
+	}
+
+	data, _ := json.Marshal(variant)
+	w.Write(data)
+}
+
+// mergeRecordFiles merges two mono recordings of the same call into stereo
+func mergeRecordFiles(c *Context, w http.ResponseWriter, r *http.Request) {
+	var m model.MergeFileChannels
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		c.SetInvalidParam("body")
+		return
+	}
+
+	// both legs are played back in the result, so each of them must be accessible
+	for _, id := range []int64{m.Left, m.Right} {
+		isAccessible, appErr := checkCallRecordFilePermission(c, r, strconv.FormatInt(id, 10))
+		if appErr != nil {
+			c.Err = appErr
+			return
+		}
+		if !isAccessible {
+			c.Err = errNoPermissionRecordFile
+			return
+		}
+
+		var leg *model.File
+		if leg, _, c.Err = c.Ctrl.GetFileWithProfile(&c.Session, 0, id); c.Err != nil {
+			return
+		}
+
+		if leg.Channel != nil && *leg.Channel == model.UploadFileChannelCall {
+			if !allowTimeLimited(r.Context(), c, leg.CreatedAt) {
+				c.Err = errNoPermissionRecordFile
+				return
+			}
+		}
+	}
+
+	var file *model.File
+	if file, c.Err = c.Ctrl.MergeFileChannels(r.Context(), &c.Session, &m); c.Err != nil {
+		return
+	}
+
+	data, _ := json.Marshal(file)
+	w.Write(data)
+}
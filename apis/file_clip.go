@@ -0,0 +1,110 @@
+package apis
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/webitel/storage/model"
+	"github.com/webitel/storage/transcoding"
+	"github.com/webitel/storage/utils"
+)
+
+// clipFromRequest reads start_sec and end_sec, nil - the whole file is served
+func clipFromRequest(c *Context, r *http.Request) *model.ClipVariant {
+	query := r.URL.Query()
+	start, end := query.Get("start_sec"), query.Get("end_sec")
+	if start == "" && end == "" {
+		return nil
+	}
+
+	clip := &model.ClipVariant{}
+	var err error
+	if start != "" {
+		if clip.StartSec, err = strconv.ParseFloat(start, 64); err != nil {
+			c.SetInvalidUrlParam("start_sec")
+			return nil
+		}
+	}
+
+	if clip.EndSec, err = strconv.ParseFloat(end, 64); err != nil {
+		c.SetInvalidUrlParam("end_sec")
+		return nil
+	}
+
+	return clip
+}
+
+// clipFileName name of the clip with the bounds in milliseconds
+func clipFileName(file *model.File, clip *model.ClipVariant) string {
+	name := file.GetViewName()
+
+	return fmt.Sprintf("%s_%d_%d%s", strings.TrimSuffix(name, path.Ext(name)), int64(clip.StartSec*1000), int64(clip.EndSec*1000),
+		transcoding.ClipExtension(file.MimeType))
+}
+
+// streamFileClip serves a clip of the file, access to the file is checked by the caller
+func streamFileClip(c *Context, w http.ResponseWriter, r *http.Request, file *model.File, backend utils.FileBackend, clip *model.ClipVariant, name string) {
+	var variant *model.FileVariant
+	if variant, c.Err = c.App.GetClipVariant(r.Context(), file, backend, clip); c.Err != nil {
+		return
+	}
+
+	streamFileVariant(c, w, r, file, backend, variant, name)
+}
+
+// clipRecordFile creates a derived file with a clip of the recording, the bounds can be taken from a transcript phrase
+func clipRecordFile(c *Context, w http.ResponseWriter, r *http.Request) {
+	isAccessible, appErr := checkCallRecordPermission(c, r)
+	if appErr != nil {
+		c.Err = appErr
+		return
+	}
+	if !isAccessible {
+		c.Err = errNoPermissionRecordFile
+		return
+	}
+
+	c.RequireId()
+	if c.Err != nil {
+		return
+	}
+
+	var file *model.File
+	var backend utils.FileBackend
+	var variant *model.FileVariant
+	var clip model.ClipVariant
+	var id int
+	var err error
+
+	if id, err = strconv.Atoi(c.Params.Id); err != nil {
+		c.SetInvalidUrlParam("id")
+		return
+	}
+
+	if err = json.NewDecoder(r.Body).Decode(&clip); err != nil {
+		c.SetInvalidParam("body")
+		return
+	}
+
+	if file, backend, c.Err = c.Ctrl.GetFileWithProfile(&c.Session, 0, int64(id)); c.Err != nil {
+		return
+	}
+
+	if file.Channel != nil && *file.Channel == model.UploadFileChannelCall {
+		if !allowTimeLimited(r.Context(), c, file.CreatedAt) {
+			c.Err = errNoPermissionRecordFile
+			return
+		}
+	}
+
+	if variant, c.Err = c.App.GetClipVariant(r.Context(), file, backend, &clip); c.Err != nil {
+		return
+	}
+
+	data, _ := json.Marshal(variant)
+	w.Write(data)
+}
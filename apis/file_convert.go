@@ -0,0 +1,88 @@
+package apis
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/webitel/storage/apis/helper"
+	"github.com/webitel/storage/model"
+	"github.com/webitel/storage/transcoding"
+	"github.com/webitel/storage/utils"
+)
+
+// streamConvertedFile serves the file in the opts format. If conversion results are stored, the converted
+// file is saved as a variant and served with Range support, otherwise it is converted while streaming
+func streamConvertedFile(c *Context, w http.ResponseWriter, r *http.Request, file *model.File, backend utils.FileBackend, opts *transcoding.AudioOptions) {
+	var reader io.ReadCloser
+	name := opts.FileName(file.GetViewName())
+
+	if !c.App.ConvertCacheEnabled() {
+		if reader, c.Err = c.App.FileReader(r.Context(), backend, file, 0); c.Err != nil {
+			return
+		}
+
+		defer reader.Close()
+
+		if reader, c.Err = c.App.FilePolicyForDownload(r.Context(), file.DomainId, &file.BaseFile, reader); c.Err != nil {
+			return
+		}
+
+		if reader, c.Err = c.App.ConvertedReader(file.MimeType, reader, opts); c.Err != nil {
+			return
+		}
+
+		defer reader.Close()
+
+		helper.SetContentSecurity(w, name, c.App.FileContentSecurity(file.Domain(), file.GetChannel(), opts.MimeType()))
+		helper.StreamConverted(w, opts, reader)
+		return
+	}
+
+	var variant *model.FileVariant
+	if variant, c.Err = c.App.GetConvertedVariant(r.Context(), file, backend, opts); c.Err != nil {
+		return
+	}
+
+	streamFileVariant(c, w, r, file, backend, variant, name)
+}
+
+// streamFileVariant serves a derived file with Range support, the download policy is checked
+// against the parent file
+func streamFileVariant(c *Context, w http.ResponseWriter, r *http.Request, file *model.File, backend utils.FileBackend, variant *model.FileVariant, name string) {
+	var reader io.ReadCloser
+	var ranges []HttpRange
+	var offset int64 = 0
+
+	if ranges, c.Err = parseRange(r.Header.Get("Range"), variant.Size); c.Err != nil {
+		return
+	}
+
+	sendSize := variant.Size
+	code := http.StatusOK
+
+	if len(ranges) == 1 {
+		code = http.StatusPartialContent
+		offset = ranges[0].Start
+		sendSize = ranges[0].Length
+		w.Header().Set("Content-Range", ranges[0].ContentRange(variant.Size))
+	}
+
+	if reader, c.Err = c.App.FileReader(r.Context(), backend, variant, offset); c.Err != nil {
+		return
+	}
+
+	defer reader.Close()
+
+	if reader, c.Err = c.App.FilePolicyForDownload(r.Context(), file.DomainId, &file.BaseFile, reader); c.Err != nil {
+		return
+	}
+
+	w.Header().Set("Content-Length", strconv.FormatInt(sendSize, 10))
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("Content-Type", variant.MimeType)
+
+	helper.SetContentSecurity(w, name, c.App.FileContentSecurity(file.Domain(), file.GetChannel(), variant.MimeType))
+	w.WriteHeader(code)
+	io.CopyN(w, reader, sendSize)
+}
@@ -0,0 +1,114 @@
+package apis
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/webitel/storage/model"
+)
+
+// searchFileJobs synchronizer jobs: action, state (0 - queued, 1 - active, 3 - error), created_at_from, created_at_to
+func searchFileJobs(c *Context, w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	search := &model.SearchFileJob{
+		ListRequest: model.ListRequest{
+			Page:    c.Params.Page,
+			PerPage: c.Params.PerPage,
+		},
+		Actions: q["action"],
+	}
+
+	for _, v := range q["state"] {
+		state, err := strconv.Atoi(v)
+		if err != nil {
+			c.SetInvalidUrlParam("state")
+			return
+		}
+		search.States = append(search.States, state)
+	}
+
+	var ok bool
+	if search.CreatedAt, ok = betweenFromQuery(c, r, "created_at"); !ok {
+		return
+	}
+
+	var jobs []*model.FileJob
+	var endOfList bool
+	if jobs, endOfList, c.Err = c.Ctrl.SearchFileJobs(r.Context(), &c.Session, search); c.Err != nil {
+		return
+	}
+
+	data, _ := json.Marshal(map[string]interface{}{
+		"items": jobs,
+		"next":  !endOfList,
+	})
+	w.Write(data)
+}
+
+func getFileJob(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequireId()
+
+	if c.Err != nil {
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Params.Id, 10, 64)
+	if err != nil {
+		c.SetInvalidUrlParam("id")
+		return
+	}
+
+	var job *model.FileJob
+	if job, c.Err = c.Ctrl.GetFileJob(r.Context(), &c.Session, id); c.Err != nil {
+		return
+	}
+
+	data, _ := json.Marshal(job)
+	w.Write(data)
+}
+
+func retryFileJobs(c *Context, w http.ResponseWriter, r *http.Request) {
+	var bulk model.FileJobsBulk
+	if err := json.NewDecoder(r.Body).Decode(&bulk); err != nil {
+		c.SetInvalidParam("body")
+		return
+	}
+
+	var cnt int64
+	if cnt, c.Err = c.Ctrl.RetryFileJobs(r.Context(), &c.Session, &bulk); c.Err != nil {
+		return
+	}
+
+	data, _ := json.Marshal(map[string]int64{"updated": cnt})
+	w.Write(data)
+}
+
+func cancelFileJobs(c *Context, w http.ResponseWriter, r *http.Request) {
+	var bulk model.FileJobsBulk
+	if err := json.NewDecoder(r.Body).Decode(&bulk); err != nil {
+		c.SetInvalidParam("body")
+		return
+	}
+
+	var cnt int64
+	if cnt, c.Err = c.Ctrl.CancelFileJobs(r.Context(), &c.Session, &bulk); c.Err != nil {
+		return
+	}
+
+	data, _ := json.Marshal(map[string]int64{"canceled": cnt})
+	w.Write(data)
+}
+
+func fileJobsQueue(c *Context, w http.ResponseWriter, r *http.Request) {
+	var queue []*model.FileJobQueue
+	if queue, c.Err = c.Ctrl.FileJobsQueue(r.Context(), &c.Session); c.Err != nil {
+		return
+	}
+
+	data, _ := json.Marshal(ListResponse{
+		Items: queue,
+	})
+	w.Write(data)
+}
@@ -0,0 +1,70 @@
+package apis
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/webitel/storage/model"
+)
+
+// InitFilePolicies settings of file policies that the gRPC contract does not carry: extension lists,
+// attachment types, CSP, strip_metadata and transcoding
+func (api *API) InitFilePolicies() {
+	api.PublicRoutes.FilePolicies.Handle("/{id}", api.ApiSessionRequired(getFilePolicy)).Methods("GET")
+	api.PublicRoutes.FilePolicies.Handle("/{id}", api.ApiSessionRequired(patchFilePolicy)).Methods("PATCH")
+}
+
+func getFilePolicy(c *Context, w http.ResponseWriter, r *http.Request) {
+	id := filePolicyIdFromRequest(c)
+	if c.Err != nil {
+		return
+	}
+
+	var policy *model.FilePolicy
+	if policy, c.Err = c.Ctrl.GetFilePolicy(r.Context(), &c.Session, id); c.Err != nil {
+		return
+	}
+
+	data, _ := json.Marshal(policy)
+	w.Write(data)
+}
+
+// patchFilePolicy changes only the fields present in the body: {"deny_extensions": ["exe"], "strip_metadata": true};
+// an empty list clears it, "transcode_codec": "" disables transcoding
+func patchFilePolicy(c *Context, w http.ResponseWriter, r *http.Request) {
+	id := filePolicyIdFromRequest(c)
+	if c.Err != nil {
+		return
+	}
+
+	var patch model.FilePolicyPath
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		c.SetInvalidParam("body")
+		return
+	}
+
+	var policy *model.FilePolicy
+	if policy, c.Err = c.Ctrl.PatchFilePolicy(r.Context(), &c.Session, id, &patch); c.Err != nil {
+		return
+	}
+
+	data, _ := json.Marshal(policy)
+	w.Write(data)
+}
+
+func filePolicyIdFromRequest(c *Context) int32 {
+	c.RequireId()
+
+	if c.Err != nil {
+		return 0
+	}
+
+	id, err := strconv.ParseInt(c.Params.Id, 10, 32)
+	if err != nil {
+		c.SetInvalidUrlParam("id")
+		return 0
+	}
+
+	return int32(id)
+}
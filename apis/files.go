@@ -27,6 +27,41 @@ func (api *API) InitFile() {
 	api.PublicRoutes.Files.Handle("/{id}/download", api.ApiSessionRequired(downloadFile)).Methods("GET")
 	api.PublicRoutes.Files.Handle("/{id}/upload", api.ApiSessionRequired(uploadAnyFile)).Methods("POST")
 	api.PublicRoutes.Files.Handle("/{id}/transcript", api.ApiSessionRequired(transcriptFile)).Methods("GET")
+
+	api.PublicRoutes.Files.Handle("/thumbnails/jobs", api.ApiSessionRequired(createThumbnailJobs)).Methods("POST")
+	api.PublicRoutes.Files.Handle("/thumbnails/jobs", api.ApiSessionRequired(thumbnailJobsProgress)).Methods("GET")
+	api.PublicRoutes.Files.Handle("/metadata/jobs", api.ApiSessionRequired(createMediaMetadataJobs)).Methods("POST")
+	api.PublicRoutes.Files.Handle("/metadata/jobs", api.ApiSessionRequired(mediaMetadataJobsProgress)).Methods("GET")
+	api.PublicRoutes.Files.Handle("/jobs", api.ApiSessionRequired(searchFileJobs)).Methods("GET")
+	api.PublicRoutes.Files.Handle("/jobs/queue", api.ApiSessionRequired(fileJobsQueue)).Methods("GET")
+	api.PublicRoutes.Files.Handle("/jobs/retry", api.ApiSessionRequired(retryFileJobs)).Methods("POST")
+	api.PublicRoutes.Files.Handle("/jobs/cancel", api.ApiSessionRequired(cancelFileJobs)).Methods("POST")
+	api.PublicRoutes.Files.Handle("/jobs/{id}", api.ApiSessionRequired(getFileJob)).Methods("GET")
+}
+
+func createThumbnailJobs(c *Context, w http.ResponseWriter, r *http.Request) {
+	var job model.ThumbnailJob
+	if err := json.NewDecoder(r.Body).Decode(&job); err != nil {
+		c.SetInvalidParam("body")
+		return
+	}
+
+	var cnt int64
+	if cnt, c.Err = c.Ctrl.CreateThumbnailJobs(r.Context(), &c.Session, &job); c.Err != nil {
+		return
+	}
+
+	data, _ := json.Marshal(map[string]int64{"created": cnt})
+	w.Write(data)
+}
+
+func thumbnailJobsProgress(c *Context, w http.ResponseWriter, r *http.Request) {
+	var progress *model.FileJobsProgress
+	if progress, c.Err = c.Ctrl.ThumbnailJobsProgress(r.Context(), &c.Session); c.Err != nil {
+		return
+	}
+
+	w.Write([]byte(progress.ToJson()))
 }
 
 func transcriptFile(c *Context, w http.ResponseWriter, r *http.Request) {
@@ -55,6 +90,31 @@ func transcriptFile(c *Context, w http.ResponseWriter, r *http.Request) {
 	w.Write(data)
 }
 
+func createMediaMetadataJobs(c *Context, w http.ResponseWriter, r *http.Request) {
+	var job model.MediaMetadataJob
+	if err := json.NewDecoder(r.Body).Decode(&job); err != nil {
+		c.SetInvalidParam("body")
+		return
+	}
+
+	var cnt int64
+	if cnt, c.Err = c.Ctrl.CreateMediaMetadataJobs(r.Context(), &c.Session, &job); c.Err != nil {
+		return
+	}
+
+	data, _ := json.Marshal(map[string]int64{"created": cnt})
+	w.Write(data)
+}
+
+func mediaMetadataJobsProgress(c *Context, w http.ResponseWriter, r *http.Request) {
+	var progress *model.FileJobsProgress
+	if progress, c.Err = c.Ctrl.MediaMetadataJobsProgress(r.Context(), &c.Session); c.Err != nil {
+		return
+	}
+
+	w.Write([]byte(progress.ToJson()))
+}
+
 func uploadAnyFile(c *Context, w http.ResponseWriter, r *http.Request) {
 	c.RequireId()
 
@@ -103,13 +163,13 @@ func uploadAnyFile(c *Context, w http.ResponseWriter, r *http.Request) {
 			file.UploadedBy = &model.Lookup{Id: int(c.Session.UserId)}
 
 			var reader io.ReadCloser
-			reader, c.Err = c.App.FilePolicyForUpload(c.Session.DomainId, &file.BaseFile, part)
+			reader, c.Err = c.App.FilePolicyForUpload(r.Context(), c.Session.DomainId, &file.BaseFile, part)
 			if c.Err != nil {
 				return
 			}
 
 			// TODO PERMISSION
-			if c.Err = c.App.SyncUpload(reader, file); c.Err != nil {
+			if c.Err = c.App.SyncUpload(r.Context(), reader, file); c.Err != nil {
 				if c.Err.GetId() == utils.ErrMaxLimitId {
 					c.Err.SetDetailedError(utils.BytesSize(float64(c.App.MaxUploadFileSize())))
 				}
@@ -142,14 +202,14 @@ func uploadAnyFile(c *Context, w http.ResponseWriter, r *http.Request) {
 		file.UploadedBy = &model.Lookup{Id: int(c.Session.UserId)}
 
 		var reader io.ReadCloser
-		reader, c.Err = c.App.FilePolicyForUpload(c.Session.DomainId, &file.BaseFile, r.Body)
+		reader, c.Err = c.App.FilePolicyForUpload(r.Context(), c.Session.DomainId, &file.BaseFile, r.Body)
 		if c.Err != nil {
 			return
 		}
 		defer reader.Close()
 
 		// TODO PERMISSION
-		if c.Err = c.App.SyncUpload(reader, file); c.Err != nil {
+		if c.Err = c.App.SyncUpload(r.Context(), reader, file); c.Err != nil {
 			if c.Err.GetId() == utils.ErrMaxLimitId {
 				c.Err.SetDetailedError(utils.BytesSize(float64(c.App.MaxUploadFileSize())))
 			}
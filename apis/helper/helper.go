@@ -3,10 +3,14 @@ package helper
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
 	"strconv"
 	"strings"
 
 	engine "github.com/webitel/engine/model"
+	"github.com/webitel/storage/model"
+	"github.com/webitel/storage/transcoding"
 )
 
 type HttpRange struct {
@@ -98,3 +102,57 @@ func (list *ListResponse) ToJson() string {
 	b, _ := json.Marshal(list)
 	return string(b)
 }
+
+// SetContentSecurity hardening headers for file responses; name is used when the file is forced to download
+func SetContentSecurity(w http.ResponseWriter, name string, sec model.ContentSecurity) {
+	h := w.Header()
+	h.Set("X-Content-Type-Options", "nosniff")
+	if sec.Policy != "" {
+		h.Set("Content-Security-Policy", sec.Policy)
+	}
+
+	if sec.Attachment && h.Get("Content-Disposition") == "" {
+		h.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", model.EncodeURIComponent(name)))
+	}
+}
+
+// SetDefaultContentSecurity hardening headers for generated content (audio, images, JSON), which is never markup
+func SetDefaultContentSecurity(w http.ResponseWriter) {
+	SetContentSecurity(w, "", model.ContentSecurity{})
+}
+
+// ConvertOptions reads format and sample_rate query parameters; nil when the file is streamed as is
+func ConvertOptions(r *http.Request) (*transcoding.AudioOptions, engine.AppError) {
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		return nil, nil
+	}
+
+	opts := &transcoding.AudioOptions{
+		Codec: format,
+	}
+
+	if rate := query.Get("sample_rate"); rate != "" {
+		var err error
+		if opts.SampleRate, err = strconv.Atoi(rate); err != nil {
+			return nil, engine.NewBadRequestError("api.helper.convert.sample_rate.app_error", err.Error())
+		}
+	}
+
+	if err := opts.IsValid(); err != nil {
+		return nil, engine.NewBadRequestError("api.helper.convert.valid.app_error", err.Error())
+	}
+
+	return opts, nil
+}
+
+// StreamConverted writes converted content; its size is unknown, so Range is ignored and the whole output
+// is sent with 200 without Content-Length
+func StreamConverted(w http.ResponseWriter, opts *transcoding.AudioOptions, reader io.Reader) {
+	w.Header().Set("Accept-Ranges", "none")
+	w.Header().Set("Content-Type", opts.MimeType())
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, reader)
+}
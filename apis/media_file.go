@@ -1,6 +1,7 @@
 package apis
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"mime"
@@ -9,13 +10,45 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/webitel/storage/apis/helper"
 	"github.com/webitel/storage/model"
+	"github.com/webitel/storage/transcoding"
+	"github.com/webitel/storage/utils"
 )
 
 func (api *API) InitMediaFile() {
 	api.PublicRoutes.MediaFiles.Handle("", api.ApiSessionRequired(saveMediaFile)).Methods("POST")
+	api.PublicRoutes.MediaFiles.Handle("", api.ApiSessionRequired(searchMediaFiles)).Methods("GET")
+	api.PublicRoutes.MediaFiles.Handle("/folders", api.ApiSessionRequired(mediaFolders)).Methods("GET")
+	api.PublicRoutes.MediaFiles.Handle("/bulk", api.ApiSessionRequired(bulkUpdateMediaFiles)).Methods("POST")
+	api.PublicRoutes.MediaFiles.Handle("/import", api.ApiSessionRequired(importMediaFiles)).Methods("POST")
+	api.PublicRoutes.MediaFiles.Handle("/{id}", api.ApiSessionRequired(deleteMediaFile)).Methods("DELETE")
+	api.PublicRoutes.MediaFiles.Handle("/{id}/usage", api.ApiSessionRequired(mediaFileUsage)).Methods("GET")
+	api.PublicRoutes.MediaFiles.Handle("/{id}/references", api.ApiSessionRequired(addMediaFileReference)).Methods("POST")
+	api.PublicRoutes.MediaFiles.Handle("/{id}/references", api.ApiSessionRequired(removeMediaFileReference)).Methods("DELETE")
 	api.PublicRoutes.MediaFiles.Handle("/{id}/stream", api.ApiSessionRequired(streamMediaFile)).Methods("GET")
 	api.PublicRoutes.MediaFiles.Handle("/{id}/download", api.ApiSessionRequired(downloadMediaFile)).Methods("GET")
+	api.PublicRoutes.MediaFiles.Handle("/{id}/versions", api.ApiSessionRequired(mediaFileVersions)).Methods("GET")
+	api.PublicRoutes.MediaFiles.Handle("/{id}/versions", api.ApiSessionRequired(uploadMediaFileVersion)).Methods("POST")
+	api.PublicRoutes.MediaFiles.Handle("/{id}/versions/activate", api.ApiSessionRequired(activateMediaFileVersion)).Methods("POST")
+	api.PublicRoutes.MediaFiles.Handle("/migrate", api.ApiSessionRequired(migrateMediaFiles)).Methods("POST")
+}
+
+// migrateMediaFiles moves a batch of the domain media files to the storage profile, call again until migrated = 0
+func migrateMediaFiles(c *Context, w http.ResponseWriter, r *http.Request) {
+	var migration model.MediaMigration
+	if err := json.NewDecoder(r.Body).Decode(&migration); err != nil {
+		c.SetInvalidParam("body")
+		return
+	}
+
+	var res *model.MediaMigrationResult
+	if res, c.Err = c.Ctrl.MigrateMediaFiles(r.Context(), &c.Session, &migration); c.Err != nil {
+		return
+	}
+
+	data, _ := json.Marshal(res)
+	w.Write(data)
 }
 
 func streamMediaFile(c *Context, w http.ResponseWriter, r *http.Request) {
@@ -31,6 +64,9 @@ func streamMediaFile(c *Context, w http.ResponseWriter, r *http.Request) {
 	var ranges []HttpRange
 	var offset int64 = 0
 	var reader io.ReadCloser
+	var backend utils.FileBackend
+	var ok bool
+	var opts *transcoding.AudioOptions
 
 	if id, err = strconv.Atoi(c.Params.Id); err != nil {
 		c.SetInvalidUrlParam("id")
@@ -39,7 +75,37 @@ func streamMediaFile(c *Context, w http.ResponseWriter, r *http.Request) {
 
 	domainId, _ = strconv.Atoi(c.Params.Domain)
 
-	if file, c.Err = c.Ctrl.GetMediaFile(&c.Session, int64(domainId), id); c.Err != nil {
+	if opts, c.Err = helper.ConvertOptions(r); c.Err != nil {
+		return
+	}
+
+	if file, ok = getMediaFile(c, r, int64(domainId), id); !ok {
+		return
+	}
+
+	if backend, c.Err = c.App.MediaFileBackend(file); c.Err != nil {
+		return
+	}
+
+	if opts != nil {
+		if reader, c.Err = c.App.FileReader(r.Context(), backend, file, 0); c.Err != nil {
+			return
+		}
+
+		defer reader.Close()
+
+		if reader, c.Err = c.App.FilePolicyForDownload(r.Context(), file.DomainId, &file.BaseFile, reader); c.Err != nil {
+			return
+		}
+
+		if reader, c.Err = c.App.ConvertedReader(file.MimeType, reader, opts); c.Err != nil {
+			return
+		}
+
+		defer reader.Close()
+
+		helper.SetContentSecurity(w, opts.FileName(file.GetViewName()), c.App.FileContentSecurity(file.Domain(), file.GetChannel(), opts.MimeType()))
+		helper.StreamConverted(w, opts, reader)
 		return
 	}
 
@@ -60,13 +126,13 @@ func streamMediaFile(c *Context, w http.ResponseWriter, r *http.Request) {
 
 	}
 
-	if reader, c.Err = c.App.MediaFileStore.Reader(file, offset); c.Err != nil {
+	if reader, c.Err = c.App.FileReader(r.Context(), backend, file, offset); c.Err != nil {
 		return
 	}
 
 	defer reader.Close()
 
-	reader, c.Err = c.App.FilePolicyForDownload(file.DomainId, &file.BaseFile, reader)
+	reader, c.Err = c.App.FilePolicyForDownload(r.Context(), file.DomainId, &file.BaseFile, reader)
 	if c.Err != nil {
 		return
 	}
@@ -78,6 +144,7 @@ func streamMediaFile(c *Context, w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Accept-Ranges", "bytes")
 	w.Header().Set("Content-Type", file.MimeType)
 
+	helper.SetContentSecurity(w, file.GetViewName(), c.App.FileContentSecurity(file.Domain(), file.GetChannel(), file.GetMimeType()))
 	w.WriteHeader(code)
 	io.CopyN(w, reader, sendSize)
 }
@@ -93,6 +160,8 @@ func downloadMediaFile(c *Context, w http.ResponseWriter, r *http.Request) {
 	var id, domainId int
 	var err error
 	var reader io.ReadCloser
+	var backend utils.FileBackend
+	var ok bool
 
 	if id, err = strconv.Atoi(c.Params.Id); err != nil {
 		c.SetInvalidUrlParam("id")
@@ -101,20 +170,24 @@ func downloadMediaFile(c *Context, w http.ResponseWriter, r *http.Request) {
 
 	domainId, _ = strconv.Atoi(c.Params.Domain)
 
-	if file, c.Err = c.Ctrl.GetMediaFile(&c.Session, int64(domainId), id); c.Err != nil {
+	if file, ok = getMediaFile(c, r, int64(domainId), id); !ok {
+		return
+	}
+
+	if backend, c.Err = c.App.MediaFileBackend(file); c.Err != nil {
 		return
 	}
 
 	sendSize := file.Size
 	code := http.StatusOK
 
-	if reader, c.Err = c.App.MediaFileStore.Reader(file, 0); c.Err != nil {
+	if reader, c.Err = c.App.FileReader(r.Context(), backend, file, 0); c.Err != nil {
 		return
 	}
 
 	defer reader.Close()
 
-	reader, c.Err = c.App.FilePolicyForDownload(file.DomainId, &file.BaseFile, reader)
+	reader, c.Err = c.App.FilePolicyForDownload(r.Context(), file.DomainId, &file.BaseFile, reader)
 	if c.Err != nil {
 		return
 	}
@@ -123,6 +196,7 @@ func downloadMediaFile(c *Context, w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", file.MimeType)
 	w.Header().Set("Content-Length", strconv.FormatInt(sendSize, 10))
 
+	helper.SetContentSecurity(w, file.GetViewName(), c.App.FileContentSecurity(file.Domain(), file.GetChannel(), file.GetMimeType()))
 	w.WriteHeader(code)
 	io.Copy(w, reader)
 }
@@ -138,6 +212,24 @@ func saveMediaFile(c *Context, w http.ResponseWriter, r *http.Request) {
 		panic(err)
 	}
 
+	// normalize=true|false overrides the domain media_normalize setting
+	var normalize *bool
+	if v := r.URL.Query().Get("normalize"); v != "" {
+		b, e := strconv.ParseBool(v)
+		if e != nil {
+			c.SetInvalidUrlParam("normalize")
+			return
+		}
+		normalize = &b
+	}
+
+	// folder and tag (multiple) for all uploaded files
+	var folder *string
+	if r.URL.Query().Has("folder") {
+		folder = model.NewString(r.URL.Query().Get("folder"))
+	}
+	tags := r.URL.Query()["tag"]
+
 	if strings.HasPrefix(mediaType, "multipart/form-data") {
 		writer := multipart.NewReader(r.Body, params["boundary"])
 
@@ -155,6 +247,9 @@ func saveMediaFile(c *Context, w http.ResponseWriter, r *http.Request) {
 			file.Properties = model.StringInterface{}
 			file.Name = part.FileName()
 			file.MimeType = part.Header.Get("Content-Type")
+			file.Normalize = normalize
+			file.Folder = folder
+			file.Tags = tags
 
 			if file, c.Err = c.Ctrl.CreateMediaFile(&c.Session, part, file); c.Err != nil {
 				break
@@ -167,6 +262,9 @@ func saveMediaFile(c *Context, w http.ResponseWriter, r *http.Request) {
 		file.Properties = model.StringInterface{}
 		file.Name = r.URL.Query().Get("name")
 		file.MimeType = r.Header.Get("Content-Type")
+		file.Normalize = normalize
+		file.Folder = folder
+		file.Tags = tags
 
 		if file, c.Err = c.Ctrl.CreateMediaFile(&c.Session, r.Body, file); c.Err == nil {
 			files = append(files, file)
@@ -0,0 +1,92 @@
+package apis
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	engine "github.com/webitel/engine/model"
+	"github.com/webitel/storage/model"
+	"github.com/webitel/storage/utils"
+)
+
+// importMediaFiles imports media files from a ZIP archive (request body or the first multipart part).
+// Parameters: atomic, folder, tag, normalize; the archive may contain manifest.json with names and tags
+func importMediaFiles(c *Context, w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	q := r.URL.Query()
+	imp := &model.MediaImport{
+		Tags: q["tag"],
+	}
+
+	if v := q.Get("atomic"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			c.SetInvalidUrlParam("atomic")
+			return
+		}
+		imp.Atomic = b
+	}
+
+	if v := q.Get("normalize"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			c.SetInvalidUrlParam("normalize")
+			return
+		}
+		imp.Normalize = &b
+	}
+
+	if q.Has("folder") {
+		imp.Folder = model.NewString(q.Get("folder"))
+	}
+
+	var src io.Reader = r.Body
+	mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if strings.HasPrefix(mediaType, "multipart/form-data") {
+		part, err := multipart.NewReader(r.Body, params["boundary"]).NextPart()
+		if err != nil {
+			c.SetInvalidParam("body")
+			return
+		}
+		defer part.Close()
+		src = part
+	}
+
+	// zip needs random access, the archive is stored in a temporary file
+	tmp, err := os.CreateTemp("", "media_import_*.zip")
+	if err != nil {
+		c.Err = engine.NewInternalError("api.media.import.app_error", err.Error())
+		return
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+
+	maxSize := c.App.Config().MediaFileStoreSettings.MaxImportFileSize
+	size, err := io.Copy(tmp, io.LimitReader(src, maxSize+1))
+	if err != nil {
+		c.Err = engine.NewBadRequestError("api.media.import.read", err.Error())
+		return
+	}
+
+	if size > maxSize {
+		c.Err = engine.NewCustomCodeError("api.media.import.max_size", "archive size exceeds "+utils.BytesSize(float64(maxSize)), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var res *model.MediaImportResult
+	if res, c.Err = c.Ctrl.ImportMediaFiles(r.Context(), &c.Session, tmp, size, imp); c.Err != nil {
+		return
+	}
+
+	data, _ := json.Marshal(res)
+	w.Write(data)
+}
@@ -0,0 +1,123 @@
+package apis
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/webitel/storage/model"
+)
+
+// searchMediaFiles media file search: q, folder, tag, mime_type, size_from, size_to, duration_from, duration_to (seconds), created_by
+func searchMediaFiles(c *Context, w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	search := &model.SearchMediaFile{
+		ListRequest: model.ListRequest{
+			Q:       q.Get("q"),
+			Page:    c.Params.Page,
+			PerPage: c.Params.PerPage,
+			Sort:    q.Get("sort"),
+		},
+		Tags:      q["tag"],
+		MimeTypes: q["mime_type"],
+	}
+
+	if v := q.Get("fields"); v != "" {
+		search.Fields = strings.Split(v, ",")
+	}
+
+	if q.Has("folder") {
+		search.Folder = model.NewString(q.Get("folder"))
+	}
+
+	var ok bool
+	if search.Size, ok = betweenFromQuery(c, r, "size"); !ok {
+		return
+	}
+
+	if search.Duration, ok = betweenFromQuery(c, r, "duration"); !ok {
+		return
+	}
+
+	for _, v := range q["created_by"] {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			c.SetInvalidUrlParam("created_by")
+			return
+		}
+		search.CreatedBy = append(search.CreatedBy, id)
+	}
+
+	var files []*model.MediaFile
+	var endOfList bool
+	if files, endOfList, c.Err = c.Ctrl.SearchMediaFile(&c.Session, 0, search); c.Err != nil {
+		return
+	}
+
+	data, _ := json.Marshal(map[string]interface{}{
+		"items": files,
+		"next":  !endOfList,
+	})
+	w.Write(data)
+}
+
+func betweenFromQuery(c *Context, r *http.Request, name string) (*model.FilterBetween, bool) {
+	var between *model.FilterBetween
+	q := r.URL.Query()
+
+	for i, p := range []string{name + "_from", name + "_to"} {
+		v := q.Get(p)
+		if v == "" {
+			continue
+		}
+
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			c.SetInvalidUrlParam(p)
+			return nil, false
+		}
+
+		if between == nil {
+			between = &model.FilterBetween{}
+		}
+		if i == 0 {
+			between.From = n
+		} else {
+			between.To = n
+		}
+	}
+
+	return between, true
+}
+
+func mediaFolders(c *Context, w http.ResponseWriter, r *http.Request) {
+	var folders []*model.MediaFolder
+	if folders, c.Err = c.Ctrl.GetMediaFolders(r.Context(), &c.Session); c.Err != nil {
+		return
+	}
+
+	response := &ListResponse{
+		Items: folders,
+	}
+
+	w.Write([]byte(response.ToJson()))
+}
+
+// bulkUpdateMediaFiles moves a group of media files to a folder and changes their tags
+func bulkUpdateMediaFiles(c *Context, w http.ResponseWriter, r *http.Request) {
+	var bulk model.MediaFilesBulk
+	if err := json.NewDecoder(r.Body).Decode(&bulk); err != nil {
+		c.SetInvalidParam("body")
+		return
+	}
+
+	var cnt int64
+	if cnt, c.Err = c.Ctrl.BulkUpdateMediaFiles(r.Context(), &c.Session, &bulk); c.Err != nil {
+		return
+	}
+
+	data, _ := json.Marshal(map[string]int64{"updated": cnt})
+	w.Write(data)
+}
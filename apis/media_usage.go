@@ -0,0 +1,116 @@
+package apis
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/webitel/storage/model"
+)
+
+// deleteMediaFile removes the media file; force=true removes a file that is still used by other services
+func deleteMediaFile(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequireId()
+
+	if c.Err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Params.Id)
+	if err != nil {
+		c.SetInvalidUrlParam("id")
+		return
+	}
+	domainId, _ := strconv.Atoi(c.Params.Domain)
+
+	var force bool
+	if v := r.URL.Query().Get("force"); v != "" {
+		if force, err = strconv.ParseBool(v); err != nil {
+			c.SetInvalidUrlParam("force")
+			return
+		}
+	}
+
+	var file *model.MediaFile
+	if file, c.Err = c.Ctrl.DeleteMediaFile(&c.Session, int64(domainId), id, force); c.Err != nil {
+		return
+	}
+
+	w.Write([]byte(file.ToJson()))
+}
+
+func mediaFileUsage(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequireId()
+
+	if c.Err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Params.Id)
+	if err != nil {
+		c.SetInvalidUrlParam("id")
+		return
+	}
+	domainId, _ := strconv.Atoi(c.Params.Domain)
+
+	var usage *model.MediaFileUsage
+	if usage, c.Err = c.Ctrl.GetMediaFileUsage(r.Context(), &c.Session, int64(domainId), id); c.Err != nil {
+		return
+	}
+
+	data, _ := json.Marshal(usage)
+	w.Write(data)
+}
+
+// addMediaFileReference registers a usage of the media file: {"source": "flow", "object_id": "12"}
+func addMediaFileReference(c *Context, w http.ResponseWriter, r *http.Request) {
+	ref, domainId := mediaFileReferenceFromRequest(c, r)
+	if c.Err != nil {
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(ref); err != nil {
+		c.SetInvalidParam("body")
+		return
+	}
+
+	if c.Err = c.Ctrl.AddMediaFileReference(r.Context(), &c.Session, domainId, ref); c.Err != nil {
+		return
+	}
+
+	ReturnStatusOK(w)
+}
+
+// removeMediaFileReference removes the reference given by the source and object_id parameters
+func removeMediaFileReference(c *Context, w http.ResponseWriter, r *http.Request) {
+	ref, domainId := mediaFileReferenceFromRequest(c, r)
+	if c.Err != nil {
+		return
+	}
+
+	ref.Source = r.URL.Query().Get("source")
+	ref.ObjectId = r.URL.Query().Get("object_id")
+
+	if c.Err = c.Ctrl.RemoveMediaFileReference(r.Context(), &c.Session, domainId, ref); c.Err != nil {
+		return
+	}
+
+	ReturnStatusOK(w)
+}
+
+func mediaFileReferenceFromRequest(c *Context, r *http.Request) (*model.MediaFileReference, int64) {
+	c.RequireId()
+
+	if c.Err != nil {
+		return nil, 0
+	}
+
+	id, err := strconv.ParseInt(c.Params.Id, 10, 64)
+	if err != nil {
+		c.SetInvalidUrlParam("id")
+		return nil, 0
+	}
+	domainId, _ := strconv.ParseInt(c.Params.Domain, 10, 64)
+
+	return &model.MediaFileReference{MediaFileId: id}, domainId
+}
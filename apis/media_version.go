@@ -0,0 +1,140 @@
+package apis
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/webitel/storage/model"
+)
+
+// getMediaFile media file with the active content version, or with the version from the version parameter
+func getMediaFile(c *Context, r *http.Request, domainId int64, id int) (*model.MediaFile, bool) {
+	var file *model.MediaFile
+
+	if v := r.URL.Query().Get("version"); v != "" {
+		version, err := strconv.Atoi(v)
+		if err != nil || version < 1 {
+			c.SetInvalidUrlParam("version")
+			return nil, false
+		}
+
+		file, c.Err = c.Ctrl.GetMediaFileVersion(r.Context(), &c.Session, domainId, id, version)
+	} else {
+		file, c.Err = c.Ctrl.GetMediaFile(&c.Session, domainId, id)
+	}
+
+	return file, c.Err == nil
+}
+
+func mediaFileVersions(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequireId()
+
+	if c.Err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Params.Id)
+	if err != nil {
+		c.SetInvalidUrlParam("id")
+		return
+	}
+	domainId, _ := strconv.Atoi(c.Params.Domain)
+
+	var versions []*model.MediaFileVersion
+	if versions, c.Err = c.Ctrl.GetMediaFileVersions(r.Context(), &c.Session, int64(domainId), id); c.Err != nil {
+		return
+	}
+
+	response := &ListResponse{
+		Items: versions,
+	}
+
+	w.Write([]byte(response.ToJson()))
+}
+
+// uploadMediaFileVersion replaces the media file content with a new version: request body, or the first multipart part
+func uploadMediaFileVersion(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequireId()
+
+	if c.Err != nil {
+		return
+	}
+
+	defer r.Body.Close()
+
+	id, err := strconv.Atoi(c.Params.Id)
+	if err != nil {
+		c.SetInvalidUrlParam("id")
+		return
+	}
+	domainId, _ := strconv.Atoi(c.Params.Domain)
+
+	upload := &model.MediaFile{}
+	if v := r.URL.Query().Get("normalize"); v != "" {
+		b, e := strconv.ParseBool(v)
+		if e != nil {
+			c.SetInvalidUrlParam("normalize")
+			return
+		}
+		upload.Normalize = &b
+	}
+
+	var src io.ReadCloser = r.Body
+	upload.MimeType = r.Header.Get("Content-Type")
+
+	mediaType, params, _ := mime.ParseMediaType(upload.MimeType)
+	if strings.HasPrefix(mediaType, "multipart/form-data") {
+		part, err := multipart.NewReader(r.Body, params["boundary"]).NextPart()
+		if err != nil {
+			c.SetInvalidParam("body")
+			return
+		}
+		defer part.Close()
+
+		src = part
+		upload.MimeType = part.Header.Get("Content-Type")
+	}
+
+	var file *model.MediaFile
+	if file, c.Err = c.Ctrl.UploadMediaFileVersion(r.Context(), &c.Session, int64(domainId), id, src, upload); c.Err != nil {
+		return
+	}
+
+	w.Write([]byte(file.ToJson()))
+}
+
+// activateMediaFileVersion reverts the media file to the version from the request body {"version": N}
+func activateMediaFileVersion(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequireId()
+
+	if c.Err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Params.Id)
+	if err != nil {
+		c.SetInvalidUrlParam("id")
+		return
+	}
+	domainId, _ := strconv.Atoi(c.Params.Domain)
+
+	var req struct {
+		Version int `json:"version"`
+	}
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil || req.Version < 1 {
+		c.SetInvalidParam("version")
+		return
+	}
+
+	var file *model.MediaFile
+	if file, c.Err = c.Ctrl.ActivateMediaFileVersion(r.Context(), &c.Session, int64(domainId), id, req.Version); c.Err != nil {
+		return
+	}
+
+	w.Write([]byte(file.ToJson()))
+}
@@ -15,6 +15,7 @@ type RoutesInternal struct {
 	Files    *mux.Router // '/records'
 	Media    *mux.Router // '/media'
 	TTS      *mux.Router // '/tts'
+	Jobs     *mux.Router // '/jobs'
 	Redirect *mux.Router // for freeswitch redirection
 
 }
@@ -35,12 +36,16 @@ func Init(a *app.App, root *mux.Router) *API {
 	api.Routes.Files = api.Routes.ApiRoot.PathPrefix("/recordings").Subrouter()
 	api.Routes.Media = api.Routes.ApiRoot.PathPrefix("/media").Subrouter()
 	api.Routes.TTS = api.Routes.ApiRoot.PathPrefix("/tts").Subrouter()
+	api.Routes.Jobs = api.Routes.ApiRoot.PathPrefix("/jobs").Subrouter()
 	api.Routes.Redirect = api.Routes.ApiRoot.PathPrefix("/redirect").Subrouter()
 
 	api.InitFile()
 	api.InitMedia()
 	api.InitTTS()
 	api.InitRedirect()
+	api.InitJobs()
+	api.InitMetrics()
+	web.InitHealth(a, root)
 
 	return api
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"github.com/webitel/storage/apis/helper"
 	"github.com/webitel/storage/model"
 	"github.com/webitel/wlog"
 	"io"
@@ -54,7 +55,7 @@ func putRecordCallFile(c *Context, w http.ResponseWriter, r *http.Request) {
 
 	defer r.Body.Close()
 
-	if err := c.App.AddUploadJobFile(r.Body, &fileRequest); err != nil {
+	if err := c.App.AddUploadJobFile(r.Context(), r.Body, &fileRequest); err != nil {
 		c.Err = err
 		return
 	}
@@ -112,6 +113,7 @@ func getAiSpeech(c *Context, w http.ResponseWriter, r *http.Request) {
 		ai.Close()
 	}()
 
+	helper.SetDefaultContentSecurity(w)
 	w.WriteHeader(http.StatusOK)
 	io.Copy(w, ai.res.Body)
 
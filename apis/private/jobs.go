@@ -0,0 +1,112 @@
+package private
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/webitel/storage/model"
+)
+
+func (api *API) InitJobs() {
+	api.Routes.Jobs.Handle("/lag", api.ApiHandler(domainsJobsLag)).Methods("GET")
+	api.Routes.Jobs.Handle("", api.ApiHandler(searchJobs)).Methods("GET")
+	api.Routes.Jobs.Handle("", api.ApiHandler(createJob)).Methods("POST")
+	api.Routes.Jobs.Handle("/{id}", api.ApiHandler(getJob)).Methods("GET")
+	api.Routes.Jobs.Handle("/{id}/cancel", api.ApiHandler(cancelJob)).Methods("POST")
+}
+
+// /sys/jobs/lag pending uploads and file jobs per domain
+func domainsJobsLag(c *Context, w http.ResponseWriter, r *http.Request) {
+	var lag []*model.DomainJobsLag
+	if lag, c.Err = c.App.DomainsJobsLag(r.Context()); c.Err != nil {
+		return
+	}
+
+	data, _ := json.Marshal(map[string]interface{}{
+		"items": lag,
+	})
+	w.Write(data)
+}
+
+// /sys/jobs?type=&status= job server jobs; they belong to no domain, so they are served only internally
+func searchJobs(c *Context, w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	search := &model.SearchJob{
+		ListRequest: model.ListRequest{
+			Page:    c.Params.Page,
+			PerPage: c.Params.PerPage,
+		},
+		Types:    q["type"],
+		Statuses: q["status"],
+	}
+
+	var jobs []*model.Job
+	var endOfList bool
+	if jobs, endOfList, c.Err = c.App.SearchJobs(r.Context(), search); c.Err != nil {
+		return
+	}
+
+	data, _ := json.Marshal(map[string]interface{}{
+		"items": jobs,
+		"next":  !endOfList,
+	})
+	w.Write(data)
+}
+
+// /sys/jobs/:id
+func getJob(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequireId()
+
+	if c.Err != nil {
+		return
+	}
+
+	var job *model.Job
+	if job, c.Err = c.App.GetJob(c.Params.Id); c.Err != nil {
+		return
+	}
+
+	w.Write([]byte(job.ToJson()))
+}
+
+// /sys/jobs runs a job outside of its schedule: {"type": "data_retention", "data": {"days": "30"}}
+func createJob(c *Context, w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Type string            `json:"type"`
+		Data map[string]string `json:"data"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		c.SetInvalidParam("body")
+		return
+	}
+
+	if req.Type == "" {
+		c.SetInvalidParam("type")
+		return
+	}
+
+	var job *model.Job
+	if job, c.Err = c.App.CreateJob(req.Type, req.Data); c.Err != nil {
+		return
+	}
+
+	w.Write([]byte(job.ToJson()))
+}
+
+// /sys/jobs/:id/cancel
+func cancelJob(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequireId()
+
+	if c.Err != nil {
+		return
+	}
+
+	var job *model.Job
+	if job, c.Err = c.App.CancelJob(c.Params.Id); c.Err != nil {
+		return
+	}
+
+	w.Write([]byte(job.ToJson()))
+}
@@ -7,6 +7,8 @@ import (
 
 	"github.com/webitel/storage/apis/helper"
 	"github.com/webitel/storage/model"
+	"github.com/webitel/storage/transcoding"
+	"github.com/webitel/storage/utils"
 )
 
 func (api *API) InitMedia() {
@@ -27,6 +29,8 @@ func streamMedia(c *Context, w http.ResponseWriter, r *http.Request) {
 	var ranges []helper.HttpRange
 	var offset int64 = 0
 	var reader io.ReadCloser
+	var backend utils.FileBackend
+	var opts *transcoding.AudioOptions
 
 	if id, err = strconv.Atoi(c.Params.Id); err != nil {
 		c.SetInvalidUrlParam("id")
@@ -35,7 +39,46 @@ func streamMedia(c *Context, w http.ResponseWriter, r *http.Request) {
 
 	domainId, _ = strconv.Atoi(c.Params.Domain)
 
-	if file, c.Err = c.App.GetMediaFile(int64(domainId), id); c.Err != nil {
+	if opts, c.Err = helper.ConvertOptions(r); c.Err != nil {
+		return
+	}
+
+	// version=N plays a stored version instead of the active one
+	if v := r.URL.Query().Get("version"); v != "" {
+		var version int
+		if version, err = strconv.Atoi(v); err != nil || version < 1 {
+			c.SetInvalidUrlParam("version")
+			return
+		}
+		file, c.Err = c.App.GetMediaFileVersion(r.Context(), int64(domainId), id, version)
+	} else {
+		file, c.Err = c.App.GetMediaFile(int64(domainId), id)
+	}
+
+	if c.Err != nil {
+		return
+	}
+
+	if backend, c.Err = c.App.MediaFileBackend(file); c.Err != nil {
+		return
+	}
+
+	if opts != nil {
+		if reader, c.Err = c.App.FileReader(r.Context(), backend, file, 0); c.Err != nil {
+			return
+		}
+
+		defer reader.Close()
+
+		if reader, c.Err = c.App.ConvertedReader(file.MimeType, reader, opts); c.Err != nil {
+			return
+		}
+
+		defer reader.Close()
+
+		c.App.RegisterMediaPlayback(file.Id)
+		helper.SetContentSecurity(w, opts.FileName(file.GetViewName()), c.App.FileContentSecurity(file.Domain(), file.GetChannel(), opts.MimeType()))
+		helper.StreamConverted(w, opts, reader)
 		return
 	}
 
@@ -56,12 +99,17 @@ func streamMedia(c *Context, w http.ResponseWriter, r *http.Request) {
 
 	}
 
-	if reader, c.Err = c.App.MediaFileStore.Reader(file, offset); c.Err != nil {
+	if reader, c.Err = c.App.FileReader(r.Context(), backend, file, offset); c.Err != nil {
 		return
 	}
 
 	defer reader.Close()
 
+	// continuation requests (Range with a non-zero offset) are not a new playback
+	if offset == 0 {
+		c.App.RegisterMediaPlayback(file.Id)
+	}
+
 	if w.Header().Get("Content-Encoding") == "" {
 		w.Header().Set("Content-Length", strconv.FormatInt(sendSize, 10))
 	}
@@ -69,6 +117,7 @@ func streamMedia(c *Context, w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Accept-Ranges", "bytes")
 	w.Header().Set("Content-Type", file.MimeType)
 
+	helper.SetContentSecurity(w, file.GetViewName(), c.App.FileContentSecurity(file.Domain(), file.GetChannel(), file.GetMimeType()))
 	w.WriteHeader(code)
 	io.CopyN(w, reader, sendSize)
 }
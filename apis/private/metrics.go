@@ -0,0 +1,14 @@
+package private
+
+import (
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// InitMetrics /metrics for Prometheus, the metrics are registered by the OTel exporter with metrics_prometheus
+func (api *API) InitMetrics() {
+	if !api.App.Config().Metrics.Prometheus {
+		return
+	}
+
+	api.Routes.Root.Handle("/metrics", promhttp.Handler()).Methods("GET")
+}
@@ -1,10 +1,12 @@
 package private
 
 import (
+	"context"
 	"fmt"
 	. "github.com/webitel/storage/apis/helper"
 	"github.com/webitel/storage/utils"
 	"github.com/webitel/wlog"
+	"go.opentelemetry.io/otel/trace"
 	"io"
 	"net/http"
 	"strconv"
@@ -23,6 +25,7 @@ type ttsPerform struct {
 	size            *int
 	mime            *string
 	cancelSleepChan chan struct{}
+	done            func()
 	mx              sync.RWMutex
 }
 
@@ -44,6 +47,7 @@ func (tts *ttsPerform) timeout() {
 	tts.stopPerform()
 	wlog.Debug(fmt.Sprintf("[%s] timeout tts", tts))
 	tts.src.Close()
+	tts.done()
 }
 
 func (tts *ttsPerform) store() {
@@ -72,10 +76,18 @@ func doTTSByProfile(c *Context, w http.ResponseWriter, r *http.Request) {
 			requestId: c.RequestId,
 			key:       r.RequestURI,
 		}
-		tts.src, tts.mime, tts.size, c.Err = c.App.TTS(c.Params.Id, params)
+		// prepared TTS must be played before the server stops
+		tts.done, c.Err = c.App.BeginWork()
 		if c.Err != nil {
 			return
 		}
+		// the provider response is read after the request ends, so only the trace is taken from its ctx
+		ctx := trace.ContextWithSpanContext(context.Background(), trace.SpanContextFromContext(r.Context()))
+		tts.src, tts.mime, tts.size, c.Err = c.App.TTS(ctx, c.Params.Id, params)
+		if c.Err != nil {
+			tts.done()
+			return
+		}
 
 		tts.store()
 		wlog.Debug(fmt.Sprintf("[%s] store tts, generate duration %v", tts, time.Since(t)))
@@ -87,7 +99,9 @@ func doTTSByProfile(c *Context, w http.ResponseWriter, r *http.Request) {
 			tts.stopPerform()
 			wlog.Debug(fmt.Sprintf("[%s] play tts", tts))
 
+			defer tts.done()
 			defer tts.src.Close()
+			SetDefaultContentSecurity(w)
 			if tts.mime != nil {
 				w.Header().Set("Content-Type", *tts.mime)
 			}
@@ -111,7 +125,7 @@ func doTTSByProfile(c *Context, w http.ResponseWriter, r *http.Request) {
 func ttsByProfile(c *Context, w http.ResponseWriter, r *http.Request) {
 	params := TtsParamsFromRequest(r)
 
-	out, t, size, err := c.App.TTS(c.Params.Id, params)
+	out, t, size, err := c.App.TTS(r.Context(), c.Params.Id, params)
 	if err != nil {
 		c.Err = err
 		return
@@ -119,6 +133,7 @@ func ttsByProfile(c *Context, w http.ResponseWriter, r *http.Request) {
 
 	defer out.Close()
 
+	SetDefaultContentSecurity(w)
 	if t != nil {
 		w.Header().Set("Content-Type", *t)
 	}
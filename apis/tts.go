@@ -23,7 +23,7 @@ func tts(c *Context, w http.ResponseWriter, r *http.Request, download bool) {
 	if params.DomainId == 0 {
 		params.DomainId = int(c.Session.DomainId)
 	}
-	out, t, size, err := c.App.TTS(app.TtsProfile, params)
+	out, t, size, err := c.App.TTS(r.Context(), app.TtsProfile, params)
 	if err != nil {
 		c.Err = err
 		return
@@ -31,6 +31,7 @@ func tts(c *Context, w http.ResponseWriter, r *http.Request, download bool) {
 
 	defer out.Close()
 
+	helper.SetDefaultContentSecurity(w)
 	if t != nil {
 		w.Header().Set("Content-Type", *t)
 	}
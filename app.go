@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"github.com/webitel/storage/apis"
 	"github.com/webitel/storage/app"
 	"github.com/webitel/storage/grpc_api"
+	_ "github.com/webitel/storage/jobs"
 	_ "github.com/webitel/storage/stt"
 	_ "github.com/webitel/storage/synchronizer"
 	_ "github.com/webitel/storage/uploader"
@@ -43,6 +45,7 @@ func main() {
 
 	a.Uploader.Start()
 	a.Synchronizer.Start()
+	a.Jobs.Start()
 
 	grpc_api.Init(a, a.GrpcServer.Server())
 
@@ -56,15 +59,24 @@ func main() {
 	signal.Notify(interruptChan, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 	<-interruptChan
 
+	// one deadline for the whole shutdown: new uploads get 503, active uploads and jobs are completed
+	// until DrainTimeout, what is left is returned to the queue
+	ctx, cancel := context.WithTimeout(context.Background(), a.Config().Shutdown.GetDrainTimeout())
+	defer cancel()
+	a.Drain(ctx)
+
 	a.Shutdown()
 
 	//a.Broker.Close()
 
+	wlog.Info("Stopping job server")
+	a.Jobs.Stop()
+
 	wlog.Info("Stopping synchronizer server")
-	a.Synchronizer.Stop()
+	a.Synchronizer.Stop(ctx)
 
 	wlog.Info("Stopping uploader server")
-	a.Uploader.Stop()
+	a.Uploader.Stop(ctx)
 
 }
 
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	otelsdk "github.com/webitel/webitel-go-kit/otel/sdk"
+	"go.opentelemetry.io/otel/exporters/prometheus"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	"net/http"
 	"sync/atomic"
@@ -44,9 +46,11 @@ type App struct {
 	FileCache        utils.FileBackend
 	DefaultFileStore utils.FileBackend
 
-	fileBackendCache *utils.Cache
-	sttProfilesCache *utils.Cache
-	jobCallback      *utils.Cache
+	fileBackendCache  *utils.Cache
+	mediaBackendCache *utils.Cache
+	mediaReadCache    *utils.ReadCache
+	sttProfilesCache  *utils.Cache
+	jobCallback       *utils.Cache
 
 	Store store.Store
 
@@ -54,7 +58,7 @@ type App struct {
 	configFile string
 	config     atomic.Value
 	newStore   func() store.Store
-	//Jobs       *jobs.JobServer
+	Jobs       interfaces.JobServerInterface
 
 	sessionManager auth_manager.AuthManager
 	Uploader       interfaces.UploadRecordingsFilesInterface
@@ -66,6 +70,11 @@ type App struct {
 	upTime time.Time
 
 	thumbnailSettings model.ThumbnailSettings
+	convertLimit      chan struct{}
+	mediaPlaybacks    *mediaPlaybacks
+	inFlight          inFlight
+	health            healthState
+	metrics           *metrics
 
 	ctx              context.Context
 	otelShutdownFunc otelsdk.ShutdownFunc
@@ -83,10 +92,11 @@ func New(options ...string) (outApp *App, outErr error) {
 		InternalSrv: &Server{
 			RootRouter: internalRootRouter,
 		},
-		fileBackendCache: utils.NewLru(model.BackendCacheSize),
-		sttProfilesCache: utils.NewLru(model.SttCacheSize),
-		jobCallback:      utils.NewLru(model.JobCacheSize),
-		ctx:              context.Background(),
+		fileBackendCache:  utils.NewLru(model.BackendCacheSize),
+		mediaBackendCache: utils.NewLru(model.BackendCacheSize),
+		sttProfilesCache:  utils.NewLru(model.SttCacheSize),
+		jobCallback:       utils.NewLru(model.JobCacheSize),
+		ctx:               context.Background(),
 	}
 	app.Srv.Router = app.Srv.RootRouter.PathPrefix("/").Subrouter()
 	app.InternalSrv.Router = app.InternalSrv.RootRouter.PathPrefix("/").Subrouter()
@@ -109,6 +119,9 @@ func New(options ...string) (outApp *App, outErr error) {
 	config := app.Config()
 
 	app.thumbnailSettings = config.Thumbnail
+	if config.Convert.MaxConcurrent > 0 {
+		app.convertLimit = make(chan struct{}, config.Convert.MaxConcurrent)
+	}
 
 	if utils.T == nil {
 		if err := utils.TranslationsPreInit(app.Config().TranslationsDirectory); err != nil {
@@ -131,22 +144,29 @@ func New(options ...string) (outApp *App, outErr error) {
 		logConfig.FileLevel = config.Log.Lvl
 	}
 
+	// OpenTelemetry is always set up: trace export is enabled by the OTEL_* variables, and the trace
+	// context is propagated even without our own export
+	otelOpts := []otelsdk.Option{
+		otelsdk.WithResource(resource.NewSchemaless(
+			semconv.ServiceName(model.APP_SERVICE_NAME),
+			semconv.ServiceVersion(model.CurrentVersion),
+			semconv.ServiceInstanceID(*app.id),
+			semconv.ServiceNamespace("webitel"),
+		)),
+	}
 	if config.Log.Otel {
-		// TODO
-		var err error
 		logConfig.EnableExport = true
-		app.otelShutdownFunc, err = otelsdk.Configure(
-			app.ctx,
-			otelsdk.WithResource(resource.NewSchemaless(
-				semconv.ServiceName(model.APP_SERVICE_NAME),
-				semconv.ServiceVersion(model.CurrentVersion),
-				semconv.ServiceInstanceID(*app.id),
-				semconv.ServiceNamespace("webitel"),
-			)),
-		)
+	}
+	if config.Metrics.Prometheus {
+		exporter, err := prometheus.New()
 		if err != nil {
 			return nil, err
 		}
+		otelOpts = append(otelOpts, otelsdk.WithMetricOptions(sdkmetric.WithReader(exporter)))
+	}
+	var err error
+	if app.otelShutdownFunc, err = otelsdk.Configure(app.ctx, otelOpts...); err != nil {
+		return nil, err
 	}
 	app.Log = wlog.NewLogger(logConfig)
 
@@ -163,6 +183,10 @@ func New(options ...string) (outApp *App, outErr error) {
 		app.preSigned = preSign
 	}
 
+	if app.metrics, err = newMetrics(app); err != nil {
+		return nil, err
+	}
+
 	if err := app.initLocalFileStores(); err != nil {
 		return nil, err
 	}
@@ -197,6 +221,8 @@ func New(options ...string) (outApp *App, outErr error) {
 
 	app.initUploader()
 	app.initSynchronizer()
+	app.initJobServer()
+	app.startMediaPlaybacks()
 	return app, outErr
 }
 
@@ -205,29 +231,30 @@ func (app *App) initLocalFileStores() engine.AppError {
 	mediaSettings := app.Config().MediaFileStoreSettings
 	fileSettings := app.Config().DefaultFileStore
 
-	if app.FileCache, appErr = utils.NewBackendStore(&model.FileBackendProfile{
+	if app.FileCache, appErr = app.newBackendStore(&model.FileBackendProfile{
 		Name:       "Internal file cache",
 		Type:       model.FileDriverLocal,
 		Properties: model.StringInterface{"directory": model.CacheDir, "path_pattern": ""},
-	}); appErr != nil {
+	}, "cache"); appErr != nil {
 		return appErr
 	}
 
-	if app.MediaFileStore, appErr = utils.NewBackendStore(&model.FileBackendProfile{
+	if app.MediaFileStore, appErr = app.newBackendStore(&model.FileBackendProfile{
 		Name:       "Media store",
 		Type:       model.FileDriverLocal,
 		Properties: model.StringInterface{"directory": mediaSettings.Directory, "path_pattern": mediaSettings.PathPattern},
-	}); appErr != nil {
+	}, "media"); appErr != nil {
 		return appErr
 	}
+	app.mediaReadCache = utils.NewReadCache(mediaSettings.CacheDirectory, mediaSettings.MaxCacheSize)
 
 	if fileSettings != nil {
-		if app.DefaultFileStore, appErr = utils.NewBackendStore(&model.FileBackendProfile{
+		if app.DefaultFileStore, appErr = app.newBackendStore(&model.FileBackendProfile{
 			Name:       "Default record file store",
 			Type:       model.StorageBackendTypeFromString(fileSettings.Type),
 			ExpireDay:  fileSettings.ExpireDay,
 			Properties: fileSettings.Props,
-		}); appErr != nil {
+		}, "default"); appErr != nil {
 			return appErr
 		}
 	}
@@ -249,6 +276,8 @@ func (app *App) Shutdown() {
 		app.InternalSrv.Server.Close()
 	}
 
+	app.stopMediaPlaybacks()
+
 	if app.cluster != nil {
 		app.cluster.Stop()
 	}
@@ -283,6 +312,12 @@ func (a *App) initSynchronizer() {
 	}
 }
 
+func (a *App) initJobServer() {
+	if jobServerInterface != nil {
+		a.Jobs = jobServerInterface(a)
+	}
+}
+
 var uploadRecordingsFilesInterface func(*App) interfaces.UploadRecordingsFilesInterface
 
 func RegisterUploader(f func(*App) interfaces.UploadRecordingsFilesInterface) {
@@ -294,3 +329,9 @@ var synchronizerFilesInterface func(*App) interfaces.SynchronizerFilesInterface
 func RegisterSynchronizer(f func(*App) interfaces.SynchronizerFilesInterface) {
 	synchronizerFilesInterface = f
 }
+
+var jobServerInterface func(*App) interfaces.JobServerInterface
+
+func RegisterJobServer(f func(*App) interfaces.JobServerInterface) {
+	jobServerInterface = f
+}
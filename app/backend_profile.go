@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/webitel/engine/auth_manager"
@@ -151,7 +152,7 @@ func (app *App) GetFileBackendStore(id *int, syncTime *int64) (store utils.FileB
 		if err != nil {
 			return nil, err
 		}
-		return utils.NewBackendStore(profile)
+		return app.newBackendStore(profile, "")
 	})
 
 	if err != nil {
@@ -177,9 +178,33 @@ func (app *App) SetRemoveFileJobs() engine.AppError {
 	return app.Store.SyncFile().SetRemoveJobs(app.DefaultFileStore.ExpireDay())
 }
 
-func (app *App) FetchFileJobs(limit int) ([]*model.SyncJob, engine.AppError) {
-	return app.Store.SyncFile().FetchJobs(limit)
+func (app *App) FetchFileJobs(action string, limit int) ([]*model.SyncJob, engine.AppError) {
+	return app.Store.SyncFile().FetchJobs(action, app.GetInstanceId(), limit, app.Config().FairShare.FairShare())
+}
+
+// ReleaseFileJob returns a taken job to the queue if it was not executed before shutdown
+func (app *App) ReleaseFileJob(jobId int64) engine.AppError {
+	return app.Store.SyncFile().Release(jobId)
+}
+
+// ReleaseInstanceFileJobs returns to the queue the jobs left active by the previous run of the instance
+func (app *App) ReleaseInstanceFileJobs(ctx context.Context) (int64, engine.AppError) {
+	return app.Store.SyncFile().ReleaseInstance(ctx, app.GetInstanceId())
+}
+
+// HeartbeatFileJobs marks the active jobs of the instance as alive, see ReclaimStaleFileJobs
+func (app *App) HeartbeatFileJobs(ctx context.Context) engine.AppError {
+	return app.Store.SyncFile().Heartbeat(ctx, app.GetInstanceId())
+}
+
+// ReclaimStaleFileJobs returns to the queue active jobs of instances that have not updated them for ReclaimAfterMinutes; 0 - disabled
+func (app *App) ReclaimStaleFileJobs(ctx context.Context) (int64, engine.AppError) {
+	if app.Config().FileJobs.ReclaimAfterMinutes <= 0 {
+		return 0, nil
+	}
+
+	return app.Store.SyncFile().ReclaimStale(ctx, app.Config().FileJobs.ReclaimAfterMinutes)
 }
 func (app *App) RemoveFileJobErrors() engine.AppError {
-	return app.Store.SyncFile().RemoveErrors()
+	return app.Store.SyncFile().RemoveErrors(app.Config().FileJobs.ErrorRetentionHours)
 }
@@ -0,0 +1,188 @@
+package app
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"time"
+
+	engine "github.com/webitel/engine/model"
+	"github.com/webitel/storage/model"
+	"github.com/webitel/storage/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// contextBackend storage whose operations continue the request trace
+type contextBackend interface {
+	WriteContext(ctx context.Context, src io.Reader, file utils.File) (int64, engine.AppError)
+	ReaderContext(ctx context.Context, file utils.File, offset int64) (io.ReadCloser, engine.AppError)
+}
+
+// instrumentedBackend records metrics and spans of storage operations
+type instrumentedBackend struct {
+	utils.FileBackend
+	metrics *metrics
+	backend attribute.KeyValue
+	profile attribute.KeyValue
+}
+
+// newBackendStore creates a profile storage with metrics and tracing; label - storage label without the profile id
+func (app *App) newBackendStore(profile *model.FileBackendProfile, label string) (utils.FileBackend, engine.AppError) {
+	backend, err := utils.NewBackendStore(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	if profile.Id > 0 {
+		label = strconv.FormatInt(profile.Id, 10)
+	}
+
+	return &instrumentedBackend{
+		FileBackend: backend,
+		metrics:     app.metrics,
+		backend:     attrBackend.String(profile.Type.String()),
+		profile:     attrProfile.String(label),
+	}, nil
+}
+
+// FileReader opens a storage file in the request context
+func (app *App) FileReader(ctx context.Context, backend utils.FileBackend, file utils.File, offset int64) (io.ReadCloser, engine.AppError) {
+	if b, ok := backend.(contextBackend); ok {
+		return b.ReaderContext(ctx, file, offset)
+	}
+
+	return backend.Reader(file, offset)
+}
+
+// WriteFile writes a file to the storage in the request context
+func (app *App) WriteFile(ctx context.Context, backend utils.FileBackend, src io.Reader, file utils.File) (int64, engine.AppError) {
+	if b, ok := backend.(contextBackend); ok {
+		return b.WriteContext(ctx, src, file)
+	}
+
+	return backend.Write(src, file)
+}
+
+func (b *instrumentedBackend) startSpan(ctx context.Context, operation string, file utils.File) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{b.backend, b.profile}
+	if file != nil {
+		attrs = append(attrs, attribute.String("file.store_name", file.GetStoreName()))
+	}
+
+	return tracer.Start(ctx, "FileBackend."+operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+func (b *instrumentedBackend) Write(src io.Reader, file utils.File) (int64, engine.AppError) {
+	return b.WriteContext(context.Background(), src, file)
+}
+
+func (b *instrumentedBackend) WriteContext(ctx context.Context, src io.Reader, file utils.File) (int64, engine.AppError) {
+	ctx, span := b.startSpan(ctx, "Write", file)
+	defer span.End()
+
+	start := time.Now()
+	n, err := b.FileBackend.Write(src, file)
+	if err != nil && err.GetId() != utils.ErrFileWriteExistsId && !model.IsFilePolicyError(err) {
+		b.error(ctx, "write")
+		spanError(span, err)
+	}
+	span.SetAttributes(attribute.Int64("file.size", n))
+
+	attrs := metric.WithAttributes(b.backend, attrChannel.String(fileChannel(file.GetChannel())))
+	b.metrics.uploadBytes.Add(ctx, n, attrs)
+	b.metrics.uploadDuration.Record(ctx, time.Since(start).Seconds(), attrs)
+
+	return n, err
+}
+
+func (b *instrumentedBackend) Reader(file utils.File, offset int64) (io.ReadCloser, engine.AppError) {
+	return b.ReaderContext(context.Background(), file, offset)
+}
+
+// ReaderContext the span lasts until the reader is closed, so it covers the whole file transfer
+func (b *instrumentedBackend) ReaderContext(ctx context.Context, file utils.File, offset int64) (io.ReadCloser, engine.AppError) {
+	ctx, span := b.startSpan(ctx, "Read", file)
+	span.SetAttributes(attribute.Int64("file.offset", offset))
+
+	r, err := b.FileBackend.Reader(file, offset)
+	if err != nil {
+		b.error(ctx, "read")
+		spanError(span, err)
+		span.End()
+		return nil, err
+	}
+
+	return &instrumentedReader{
+		ReadCloser: r,
+		ctx:        ctx,
+		backend:    b,
+		span:       span,
+		channel:    fileChannel(file.GetChannel()),
+		start:      time.Now(),
+	}, nil
+}
+
+func (b *instrumentedBackend) Remove(file utils.File) engine.AppError {
+	ctx, span := b.startSpan(context.Background(), "Remove", file)
+	defer span.End()
+
+	err := b.FileBackend.Remove(file)
+	if err != nil {
+		b.error(ctx, "remove")
+		spanError(span, err)
+	}
+
+	return err
+}
+
+func (b *instrumentedBackend) TestConnection() engine.AppError {
+	_, span := b.startSpan(context.Background(), "TestConnection", nil)
+	defer span.End()
+
+	err := b.FileBackend.TestConnection()
+	if err != nil {
+		spanError(span, err)
+	}
+
+	return err
+}
+
+func (b *instrumentedBackend) error(ctx context.Context, operation string) {
+	b.metrics.backendErrors.Add(ctx, 1, metric.WithAttributes(b.backend, b.profile, attrOperation.String(operation)))
+}
+
+type instrumentedReader struct {
+	io.ReadCloser
+	ctx     context.Context
+	backend *instrumentedBackend
+	span    trace.Span
+	channel string
+	start   time.Time
+	n       int64
+	closed  bool
+}
+
+func (r *instrumentedReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+
+	return n, err
+}
+
+func (r *instrumentedReader) Close() error {
+	if r.closed {
+		return r.ReadCloser.Close()
+	}
+	r.closed = true
+
+	attrs := metric.WithAttributes(r.backend.backend, attrChannel.String(r.channel))
+	r.backend.metrics.downloadBytes.Add(r.ctx, r.n, attrs)
+	r.backend.metrics.downloadDuration.Record(r.ctx, time.Since(r.start).Seconds(), attrs)
+
+	r.span.SetAttributes(attribute.Int64("file.read_bytes", r.n))
+	r.span.End()
+
+	return r.ReadCloser.Close()
+}
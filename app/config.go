@@ -30,6 +30,18 @@ func loadConfig(fileName string) (*model.Config, engine.AppError) {
 	}
 	config.MediaFileStoreSettings.MaxUploadFileSize = maxUploadSizeInByte
 
+	maxImportSizeInByte, err := utils.FromHumanSize(config.MediaFileStoreSettings.MaxImportFileSizeString)
+	if err != nil {
+		panic(err.Error())
+	}
+	config.MediaFileStoreSettings.MaxImportFileSize = maxImportSizeInByte
+
+	maxCacheSizeInByte, err := utils.FromHumanSize(config.MediaFileStoreSettings.MaxCacheSizeString)
+	if err != nil {
+		panic(err.Error())
+	}
+	config.MediaFileStoreSettings.MaxCacheSize = maxCacheSizeInByte
+
 	if config.DefaultFileStore != nil && config.DefaultFileStore.Type != "" {
 		if config.DefaultFileStore.PropsString != "" {
 			err = json.Unmarshal([]byte(config.DefaultFileStore.PropsString), &config.DefaultFileStore.Props)
@@ -0,0 +1,109 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	engine "github.com/webitel/engine/model"
+	"github.com/webitel/storage/model"
+	"github.com/webitel/storage/transcoding"
+	"github.com/webitel/storage/utils"
+)
+
+// convertReader frees a slot of the conversion limit on close
+type convertReader struct {
+	*transcoding.Reader
+	release func()
+	once    sync.Once
+}
+
+func (r *convertReader) Close() error {
+	err := r.Reader.Close()
+	r.once.Do(r.release)
+
+	return err
+}
+
+// ConvertCacheEnabled the conversion result is stored as a file variant
+func (app *App) ConvertCacheEnabled() bool {
+	return app.Config().Convert.Cache
+}
+
+// ConvertedReader converts src to the opts format while reading. The number of concurrent conversions
+// is limited by convert_max_concurrent, 503 is returned when exceeded. src is closed by the caller
+func (app *App) ConvertedReader(mime string, src io.Reader, opts *transcoding.AudioOptions) (io.ReadCloser, engine.AppError) {
+	if err := app.checkConvert(mime, opts); err != nil {
+		return nil, err
+	}
+
+	release, err := app.acquireConvert()
+	if err != nil {
+		return nil, err
+	}
+
+	out, e := transcoding.NewAudioReader(src, *opts)
+	if e != nil {
+		release()
+		return nil, engine.NewInternalError("app.convert.start", e.Error())
+	}
+
+	return &convertReader{Reader: out, release: release}, nil
+}
+
+// GetConvertedVariant returns the stored conversion result of the file, it is created on the first request.
+// Access to the file is checked by the caller
+func (app *App) GetConvertedVariant(ctx context.Context, file *model.File, backend utils.FileBackend, opts *transcoding.AudioOptions) (*model.FileVariant, engine.AppError) {
+	if err := app.checkConvert(file.MimeType, opts); err != nil {
+		return nil, err
+	}
+
+	key := "convert_" + opts.Codec
+	if opts.SampleRate > 0 {
+		key += "_" + strconv.Itoa(opts.SampleRate)
+	}
+	key += opts.Extension()
+
+	return app.fileVariant(ctx, file, backend, key, func() (*model.FileVariant, engine.AppError) {
+		src, err := backend.Reader(file, 0)
+		if err != nil {
+			return nil, err
+		}
+		defer src.Close()
+
+		out, err := app.ConvertedReader(file.MimeType, src, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		return app.storeFileVariant(ctx, file, backend, key, opts.MimeType(), out)
+	})
+}
+
+func (app *App) checkConvert(mime string, opts *transcoding.AudioOptions) engine.AppError {
+	if e := opts.IsValid(); e != nil {
+		return engine.NewBadRequestError("app.convert.valid.options", e.Error())
+	}
+
+	if !utils.IsSupportMediaProbe(mime) {
+		return engine.NewBadRequestError("app.convert.mime_type", "not supported mime type "+mime)
+	}
+
+	return nil
+}
+
+func (app *App) acquireConvert() (func(), engine.AppError) {
+	if app.convertLimit == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case app.convertLimit <- struct{}{}:
+		return func() { <-app.convertLimit }, nil
+	default:
+		return nil, engine.NewCustomCodeError("app.convert.limit", fmt.Sprintf("too many conversions, limit %d", cap(app.convertLimit)), http.StatusServiceUnavailable)
+	}
+}
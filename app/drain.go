@@ -0,0 +1,85 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	engine "github.com/webitel/engine/model"
+	"github.com/webitel/wlog"
+)
+
+// inFlight uploads and prepared TTS that must complete before the server stops
+type inFlight struct {
+	mx       sync.Mutex
+	draining bool
+	active   int
+	idle     chan struct{}
+}
+
+// BeginWork registers work that must complete before shutdown; no new work is accepted while stopping
+func (app *App) BeginWork() (func(), engine.AppError) {
+	w := &app.inFlight
+	w.mx.Lock()
+	defer w.mx.Unlock()
+
+	if w.draining {
+		return nil, engine.NewCustomCodeError("app.drain.shutting_down", "server is shutting down", http.StatusServiceUnavailable)
+	}
+	w.active++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			w.mx.Lock()
+			w.active--
+			if w.active == 0 && w.idle != nil {
+				close(w.idle)
+				w.idle = nil
+			}
+			w.mx.Unlock()
+		})
+	}, nil
+}
+
+func (app *App) IsDraining() bool {
+	app.inFlight.mx.Lock()
+	defer app.inFlight.mx.Unlock()
+
+	return app.inFlight.draining
+}
+
+// Drain stops accepting uploads and waits for the active ones until ctx is done;
+// suspended safe uploads waiting for the client to resume fail
+func (app *App) Drain(ctx context.Context) bool {
+	w := &app.inFlight
+	w.mx.Lock()
+	w.draining = true
+	active := w.active
+	idle := make(chan struct{})
+	if active == 0 {
+		close(idle)
+	} else {
+		w.idle = idle
+	}
+	w.mx.Unlock()
+
+	wlog.Info(fmt.Sprintf("drain %d active uploads", active))
+	app.stopGrpcHealth()
+	stopSleepingSafeUploads()
+
+	start := time.Now()
+	select {
+	case <-idle:
+		wlog.Info(fmt.Sprintf("drained active uploads, duration %s", time.Since(start)))
+		return true
+	case <-ctx.Done():
+		w.mx.Lock()
+		active = w.active
+		w.mx.Unlock()
+		wlog.Warn(fmt.Sprintf("drain timeout, %d uploads are not finished", active))
+		return false
+	}
+}
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	engine "github.com/webitel/engine/model"
+	"github.com/webitel/storage/model"
+	"github.com/webitel/storage/transcoding"
+	"github.com/webitel/storage/utils"
+	"github.com/webitel/wlog"
+)
+
+// GetChannelVariant returns one channel of the audio file as mono, it is extracted from the original on the first request.
+// Access to the file is checked by the caller
+func (app *App) GetChannelVariant(ctx context.Context, file *model.File, backend utils.FileBackend, v *model.ChannelVariant) (*model.FileVariant, engine.AppError) {
+	if !strings.HasPrefix(file.MimeType, "audio/") {
+		return nil, engine.NewBadRequestError("app.file_variant.channel.mime_type", "not supported mime type "+file.MimeType)
+	}
+
+	channels := 0
+	if file.Channels != nil {
+		channels = *file.Channels
+	}
+
+	if err := v.IsValid(channels); err != nil {
+		return nil, err
+	}
+
+	opts := transcoding.AudioOptions{Codec: transcoding.AudioCodecOf(file.MimeType)}
+	key := v.Key(opts.Extension())
+
+	return app.fileVariant(ctx, file, backend, key, func() (*model.FileVariant, engine.AppError) {
+		src, err := app.FileReader(ctx, backend, file, 0)
+		if err != nil {
+			return nil, err
+		}
+		defer src.Close()
+
+		out, e := transcoding.NewExtractChannelReader(src, opts, v.Channel)
+		if e != nil {
+			return nil, engine.NewBadRequestError("app.file_variant.channel.create", e.Error())
+		}
+
+		return app.storeFileVariant(ctx, file, backend, key, opts.MimeType(), out)
+	})
+}
+
+// MergeFileChannels merges two mono recordings of the same call (same uuid) into stereo and uploads the result
+// as a new file to the storage of the left recording
+func (app *App) MergeFileChannels(ctx context.Context, domainId int64, m *model.MergeFileChannels) (*model.File, engine.AppError) {
+	if err := m.IsValid(); err != nil {
+		return nil, err
+	}
+
+	left, backend, err := app.GetFileWithProfile(domainId, m.Left)
+	if err != nil {
+		return nil, err
+	}
+
+	right, rightBackend, err := app.GetFileWithProfile(domainId, m.Right)
+	if err != nil {
+		return nil, err
+	}
+
+	if left.Uuid != right.Uuid {
+		return nil, engine.NewBadRequestError("app.merge_channels.uuid", "files of different uuid")
+	}
+
+	for _, f := range []*model.File{left, right} {
+		if !strings.HasPrefix(f.MimeType, "audio/") {
+			return nil, engine.NewBadRequestError("app.merge_channels.mime_type", "not supported mime type "+f.MimeType)
+		}
+	}
+
+	opts := transcoding.AudioOptions{Codec: m.Codec}
+	if opts.Codec == "" {
+		opts.Codec = transcoding.AudioCodecOf(left.MimeType)
+	}
+	if e := opts.IsValid(); e != nil {
+		return nil, engine.NewBadRequestError("app.merge_channels.valid.codec", e.Error())
+	}
+
+	leftSrc, err := app.FileReader(ctx, backend, left, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer leftSrc.Close()
+
+	rightSrc, err := app.FileReader(ctx, rightBackend, right, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer rightSrc.Close()
+
+	out, e := transcoding.NewMergeChannelsReader(leftSrc, rightSrc, opts)
+	if e != nil {
+		return nil, engine.NewInternalError("app.merge_channels.start", e.Error())
+	}
+
+	name := left.GetViewName()
+	name = opts.FileName(strings.TrimSuffix(name, path.Ext(name)) + "_stereo")
+	file := &model.JobUploadFile{
+		BaseFile: model.BaseFile{
+			Name:     model.NewId() + "_" + name,
+			ViewName: &name,
+			MimeType: opts.MimeType(),
+			Channel:  left.Channel,
+		},
+		DomainId: left.DomainId,
+		Uuid:     left.Uuid,
+	}
+
+	reader, err := app.FilePolicyForUpload(ctx, file.DomainId, &file.BaseFile, out)
+	if err != nil {
+		out.Close()
+		return nil, err
+	}
+
+	err = app.upload(ctx, reader, left.ProfileId, backend, file)
+	if e = out.Close(); e != nil && err == nil {
+		err = engine.NewInternalError("app.merge_channels.app_error", e.Error())
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	wlog.Debug(fmt.Sprintf("merged files %d and %d into stereo file %d", left.Id, right.Id, file.Id))
+
+	res, _, err := app.GetFileWithProfile(domainId, file.Id)
+
+	return res, err
+}
@@ -0,0 +1,66 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	engine "github.com/webitel/engine/model"
+	"github.com/webitel/storage/model"
+	"github.com/webitel/wlog"
+)
+
+// SearchFileJobs synchronizer jobs for the domain files
+func (app *App) SearchFileJobs(ctx context.Context, domainId int64, search *model.SearchFileJob) ([]*model.FileJob, bool, engine.AppError) {
+	jobs, err := app.Store.SyncFile().Search(ctx, domainId, search)
+	if err != nil {
+		return nil, false, err
+	}
+	search.RemoveLastElemIfNeed(&jobs)
+
+	return jobs, search.EndOfList(), nil
+}
+
+func (app *App) GetFileJob(ctx context.Context, domainId int64, id int64) (*model.FileJob, engine.AppError) {
+	return app.Store.SyncFile().Get(ctx, domainId, id)
+}
+
+// RetryFileJobs returns failed jobs to the queue
+func (app *App) RetryFileJobs(ctx context.Context, domainId int64, bulk *model.FileJobsBulk) (int64, engine.AppError) {
+	if err := bulk.IsValid(); err != nil {
+		return 0, err
+	}
+
+	cnt, err := app.Store.SyncFile().Retry(ctx, domainId, bulk.Ids)
+	if err != nil {
+		return 0, err
+	}
+
+	wlog.Debug(fmt.Sprintf("domain %d, retry %d file jobs", domainId, cnt))
+
+	return cnt, nil
+}
+
+// CancelFileJobs removes queued and failed jobs; active jobs are completed by the synchronizer
+func (app *App) CancelFileJobs(ctx context.Context, domainId int64, bulk *model.FileJobsBulk) (int64, engine.AppError) {
+	if err := bulk.IsValid(); err != nil {
+		return 0, err
+	}
+
+	cnt, err := app.Store.SyncFile().Cancel(ctx, domainId, bulk.Ids)
+	if err != nil {
+		return 0, err
+	}
+
+	wlog.Debug(fmt.Sprintf("domain %d, canceled %d file jobs", domainId, cnt))
+
+	return cnt, nil
+}
+
+func (app *App) FileJobsQueue(ctx context.Context, domainId int64) ([]*model.FileJobQueue, engine.AppError) {
+	return app.Store.SyncFile().Queue(ctx, domainId)
+}
+
+// DomainsJobsLag processing delay of uploads and file jobs per domain
+func (app *App) DomainsJobsLag(ctx context.Context) ([]*model.DomainJobsLag, engine.AppError) {
+	return app.Store.UploadJob().DomainsLag(ctx)
+}
@@ -27,6 +27,8 @@ func (app *App) ChangePositionFilePolicy(ctx context.Context, domainId int64, fr
 	return app.Store.FilePolicies().ChangePosition(ctx, domainId, fromId, toId)
 }
 
+// UpdateFilePolicy replaces the fields of the gRPC contract; extension lists, attachment types, CSP,
+// strip_metadata and transcoding are changed with PatchFilePolicy (PATCH /file_policies/{id})
 func (app *App) UpdateFilePolicy(ctx context.Context, domainId int64, id int32, policy *model.FilePolicy) (*model.FilePolicy, engine.AppError) {
 	oldPolicy, err := app.GetFilePolicy(ctx, domainId, id)
 	if err != nil {
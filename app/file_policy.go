@@ -1,14 +1,16 @@
 package app
 
 import (
+	"bytes"
 	"context"
 	"fmt"
-	"github.com/h2non/filetype"
 	"github.com/juju/ratelimit"
 	engine "github.com/webitel/engine/model"
 	"github.com/webitel/storage/model"
 	"github.com/webitel/storage/utils"
 	"github.com/webitel/wlog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/sync/singleflight"
 	"io"
 	"strings"
@@ -20,6 +22,7 @@ var (
 )
 
 type PolicyReader struct {
+	ctx        context.Context
 	name       string
 	r          io.ReadCloser // underlying reader
 	bytesCount int64
@@ -27,11 +30,27 @@ type PolicyReader struct {
 	f          *model.BaseFile
 	maxSize    int64
 	mimeTyme   string
+	policy     *FilePolicy
+	hub        *PoliciesHub
+	head       []byte // first bytes of the file read for the content check
+	checked    bool
+	metrics    *metrics
+	// throttle time spent waiting for the rate limiter, the span is recorded on close
+	throttleStart time.Time
+	throttleEnd   time.Time
+	throttled     time.Duration
+	throttleWaits int64
 }
 
 type FilePolicy struct {
-	name string
-	mime []string
+	name     string
+	mime     []string
+	allowExt map[string]struct{}
+	denyExt  map[string]struct{}
+
+	attachmentMime []string
+	csp            string
+	stripMetadata  bool
 
 	speedDownload int64
 	speedUpload   int64
@@ -58,16 +77,93 @@ type DomainFilePolicy struct {
 	policies utils.ObjectCache
 }
 
-func (app *App) FilePolicyForDownload(domainId int64, file *model.BaseFile, src io.ReadCloser) (io.ReadCloser, engine.AppError) {
+func (app *App) FilePolicyForDownload(ctx context.Context, domainId int64, file *model.BaseFile, src io.ReadCloser) (io.ReadCloser, engine.AppError) {
 	//TODO for old files
 	if file.Channel == nil {
 		return src, nil
 	}
-	return app.filePolicies.policyReaderForDownload(domainId, file, src)
+	return app.filePolicies.policyReaderForDownload(ctx, domainId, file, src)
+}
+
+func (app *App) FilePolicyForUpload(ctx context.Context, domainId int64, file *model.BaseFile, src io.ReadCloser) (io.ReadCloser, engine.AppError) {
+	return app.filePolicies.policyReaderForUpload(ctx, domainId, file, src)
 }
 
-func (app *App) FilePolicyForUpload(domainId int64, file *model.BaseFile, src io.ReadCloser) (io.ReadCloser, engine.AppError) {
-	return app.filePolicies.policyReaderForUpload(domainId, file, src)
+// ReadUploadHead reads the beginning of the upload, so the file policy has checked the content and replaced
+// a generic declared type with the detected one before the type selects metadata stripping, thumbnails or probing.
+// The returned reader yields the whole file
+func ReadUploadHead(src io.Reader) (io.Reader, engine.AppError) {
+	head := make([]byte, utils.ContentSniffLen)
+	n, err := io.ReadFull(src, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		switch err.(type) {
+		case engine.AppError:
+			return nil, err.(engine.AppError)
+		default:
+			return nil, engine.NewInternalError("app.upload.read_head.app_error", err.Error())
+		}
+	}
+
+	return io.MultiReader(bytes.NewReader(head[:n]), src), nil
+}
+
+// FileContentSecurity returns hardening headers for serving a file. HTML/SVG are always served as attachments and
+// markup/text types get the sandboxing CSP; the channel policy can add attachment types and set the CSP for its types
+func (app *App) FileContentSecurity(domainId int64, channel *string, mime string) model.ContentSecurity {
+	sec := model.ContentSecurity{
+		Attachment: utils.IsMarkupMimeType(mime),
+	}
+	if utils.IsTextMimeType(mime) {
+		sec.Policy = model.DefaultContentSecurityPolicy
+	}
+
+	if channel == nil {
+		return sec
+	}
+
+	h, err := app.cachedPolicyHub(domainId)
+	if err != nil {
+		app.Log.Error(err.Error(), wlog.Err(err))
+		return sec
+	}
+
+	policy, err := h.Policy(channel, mime)
+	if err != nil {
+		return sec
+	}
+
+	if policy.csp != "" {
+		sec.Policy = policy.csp
+	}
+
+	for _, m := range policy.attachmentMime {
+		if MatchPattern(m, mime) {
+			sec.Attachment = true
+			break
+		}
+	}
+
+	return sec
+}
+
+// StripImageMetadata whether image metadata must be removed according to the channel policy
+func (app *App) StripImageMetadata(domainId int64, channel *string, mime string) bool {
+	if channel == nil || !utils.IsSupportStripMetadata(mime) {
+		return false
+	}
+
+	h, err := app.cachedPolicyHub(domainId)
+	if err != nil {
+		app.Log.Error(err.Error(), wlog.Err(err))
+		return false
+	}
+
+	policy, err := h.Policy(channel, mime)
+	if err != nil {
+		return false
+	}
+
+	return policy.stripMetadata
 }
 
 func (app *App) policiesHub(domainId int64) (*PoliciesHub, engine.AppError) {
@@ -100,6 +196,14 @@ func (app *App) newPoliciesHub(domainId int64, policies []model.FilePolicy) *Pol
 			maxUploadSize: v.MaxUploadSize,        // bytes
 			mime:          v.MimeTypes,
 			retentionDays: int(v.RetentionDays),
+			allowExt:      extensionsSet(v.AllowExtensions),
+			denyExt:       extensionsSet(v.DenyExtensions),
+
+			attachmentMime: v.AttachmentMimeTypes,
+			stripMetadata:  v.StripMetadata,
+		}
+		if v.ContentSecurityPolicy != nil {
+			p.csp = *v.ContentSecurityPolicy
 		}
 
 		h.appendPolicy(v.Channels, &p)
@@ -142,7 +246,7 @@ func (app *App) cachedPolicyHub(domainId int64) (*PoliciesHub, engine.AppError)
 	return h.(*PoliciesHub), nil
 }
 
-func (ph *DomainFilePolicy) policyReaderForDownload(domainId int64, file *model.BaseFile, src io.ReadCloser) (io.ReadCloser, engine.AppError) {
+func (ph *DomainFilePolicy) policyReaderForDownload(ctx context.Context, domainId int64, file *model.BaseFile, src io.ReadCloser) (io.ReadCloser, engine.AppError) {
 	var policy *FilePolicy
 	v, err := ph.app.cachedPolicyHub(domainId)
 	if err != nil {
@@ -150,6 +254,7 @@ func (ph *DomainFilePolicy) policyReaderForDownload(domainId int64, file *model.
 	}
 	policy, err = v.Policy(file.Channel, file.MimeType)
 	if err != nil {
+		ph.app.metrics.policyRejected(file, err)
 		return nil, err
 	}
 
@@ -158,10 +263,13 @@ func (ph *DomainFilePolicy) policyReaderForDownload(domainId int64, file *model.
 	}
 
 	r := &PolicyReader{
+		ctx:      ctx,
 		r:        src,
 		f:        file,
 		mimeTyme: file.MimeType,
 		name:     policy.name,
+		checked:  true,
+		metrics:  ph.app.metrics,
 	}
 
 	if policy.speedDownload > 0 {
@@ -171,7 +279,7 @@ func (ph *DomainFilePolicy) policyReaderForDownload(domainId int64, file *model.
 	return r, nil
 }
 
-func (ph *DomainFilePolicy) policyReaderForUpload(domainId int64, file *model.BaseFile, src io.ReadCloser) (io.ReadCloser, engine.AppError) {
+func (ph *DomainFilePolicy) policyReaderForUpload(ctx context.Context, domainId int64, file *model.BaseFile, src io.ReadCloser) (io.ReadCloser, engine.AppError) {
 	var policy *FilePolicy
 	v, err := ph.app.cachedPolicyHub(domainId)
 	if err != nil {
@@ -179,35 +287,43 @@ func (ph *DomainFilePolicy) policyReaderForUpload(domainId int64, file *model.Ba
 	}
 	policy, err = v.Policy(file.Channel, file.MimeType)
 	if err != nil {
+		ph.app.metrics.policyRejected(file, err)
 		return nil, err
 	}
 
-	if policy == FilePolicyAllowAll {
-		return src, nil
-	}
-
 	r := &PolicyReader{
+		ctx:     ctx,
 		r:       src,
 		f:       file,
-		maxSize: policy.maxUploadSize,
-		name:    policy.name,
+		hub:     v,
+		metrics: ph.app.metrics,
 	}
 
 	if file.Channel == nil || *file.Channel != model.UploadFileChannelMedia {
-		// TODO check all channel ?
+		// unknown content is allowed for all channels except media
 		r.mimeTyme = file.MimeType
 	}
 
+	r.setUploadPolicy(policy)
+
+	return r, nil
+}
+
+// setUploadPolicy applies the limits of the policy that governs the upload
+func (r *PolicyReader) setUploadPolicy(policy *FilePolicy) {
+	r.policy = policy
+	r.name = policy.name
+	r.maxSize = policy.maxUploadSize
+
 	if policy.retentionDays > 0 {
 		t := time.Now().AddDate(0, 0, policy.retentionDays)
-		file.RetentionUntil = &t
+		r.f.RetentionUntil = &t
 	}
 
+	r.bucket = nil
 	if policy.speedUpload > 0 {
 		r.bucket = ratelimit.NewBucketWithRate(float64(policy.speedUpload), policy.speedUpload)
 	}
-
-	return r, nil
 }
 
 func (ph *PoliciesHub) appendPolicy(channels []string, policy *FilePolicy) {
@@ -242,7 +358,19 @@ func (ph *PoliciesHub) Policy(channel *string, mime string) (*FilePolicy, engine
 }
 
 func (r *PolicyReader) Read(buf []byte) (n int, err error) {
-	n, err = r.r.Read(buf)
+	if !r.checked {
+		if err = r.checkContent(); err != nil {
+			r.metrics.policyRejected(r.f, err)
+			return 0, err
+		}
+	}
+
+	if len(r.head) > 0 {
+		n = copy(buf, r.head)
+		r.head = r.head[n:]
+	} else {
+		n, err = r.r.Read(buf)
+	}
 	if n <= 0 {
 		return
 	}
@@ -250,49 +378,151 @@ func (r *PolicyReader) Read(buf []byte) (n int, err error) {
 
 	if r.maxSize > 0 && r.bytesCount > r.maxSize {
 		err = model.PolicyErrorMaxLimit
+		r.metrics.policyRejected(r.f, err)
 		return
 	}
 
-	if r.mimeTyme == "" {
-		err = r.testMimeType(buf)
-		if err != nil {
-			return n, err
-		}
-		r.mimeTyme = r.f.MimeType
-	}
-
 	if r.bucket != nil {
-		r.bucket.Wait(int64(n))
+		r.wait(int64(n))
 	}
 
 	return
 }
 
+// wait waits for the rate limiter and accumulates the waiting time
+func (r *PolicyReader) wait(n int64) {
+	d := r.bucket.Take(n)
+	if d <= 0 {
+		return
+	}
+
+	if r.throttleWaits == 0 {
+		r.throttleStart = time.Now()
+	}
+	time.Sleep(d)
+	r.throttleEnd = time.Now()
+	r.throttled += d
+	r.throttleWaits++
+}
+
 func (r *PolicyReader) Close() (err error) {
+	if r.throttleWaits > 0 {
+		r.traceThrottle()
+	}
 	return r.r.Close()
 }
 
-func (r *PolicyReader) testMimeType(bytes []byte) error {
-	kind, err := filetype.Match(bytes)
-	if err != nil {
+// traceThrottle span from the first to the last limiter wait with the total waiting time
+func (r *PolicyReader) traceThrottle() {
+	_, span := tracer.Start(r.ctx, "PolicyReader.throttle",
+		trace.WithTimestamp(r.throttleStart),
+		trace.WithAttributes(
+			attribute.String("policy.name", r.name),
+			attribute.Int64("policy.throttle_waits", r.throttleWaits),
+			attribute.Int64("policy.throttled_ms", r.throttled.Milliseconds()),
+			attribute.Int64("policy.bytes", r.bytesCount),
+		),
+	)
+	span.End(trace.WithTimestamp(r.throttleEnd))
+	r.throttleWaits = 0
+}
+
+// checkContent reads the beginning of the file for the check, the bytes read are returned by the next Read calls
+func (r *PolicyReader) checkContent() error {
+	r.checked = true
+	head := make([]byte, utils.ContentSniffLen)
+	n, err := io.ReadFull(r.r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
 		return err
 	}
+	r.head = head[:n]
 
-	if kind == filetype.Unknown {
-		// TODO
-		return model.PolicyErrorExtUnknown
+	declared := r.f.MimeType
+	if appErr := r.policy.checkContent(r.f, r.head, r.mimeTyme == ""); appErr != nil {
+		return appErr
+	}
+
+	if r.f.MimeType != declared {
+		// the policy was chosen by the generic declared type, the detected type may be governed by another one
+		policy, appErr := r.hub.Policy(r.f.Channel, r.f.MimeType)
+		if appErr != nil {
+			return appErr
+		}
+
+		if policy != r.policy {
+			if appErr = policy.checkContent(r.f, r.head, false); appErr != nil {
+				return appErr
+			}
+			r.f.RetentionUntil = nil
+			r.setUploadPolicy(policy)
+		}
+	}
+	r.mimeTyme = r.f.MimeType
+
+	return nil
+}
+
+// checkContent checks the file extension and content. Executable code, scripts and macros
+// are blocked regardless of the declared Content-Type; strict rejects unknown content
+func (p *FilePolicy) checkContent(file *model.BaseFile, head []byte, strict bool) engine.AppError {
+	if err := p.checkExtension(utils.FileExtension(file.GetViewName())); err != nil {
+		return err
 	}
 
-	if strings.HasPrefix(r.f.MimeType, kind.MIME.Value) || (r.f.MimeType == "audio/wav" && kind.Extension == "wav") {
+	ct := utils.DetectContentType(head)
+	if ct.Danger != "" || (utils.IsMarkupMimeType(file.MimeType) && utils.HasActiveContent(head)) {
+		return model.PolicyErrorDangerous
+	}
+
+	if ct.MimeType == "" {
+		if strict {
+			return model.PolicyErrorExtUnknown
+		}
 		return nil
 	}
 
-	if r.f.MimeType != kind.MIME.Value {
+	if utils.IsGenericMimeType(file.MimeType) {
+		file.MimeType = ct.MimeType
+		return nil
+	}
+
+	if !utils.MimeCompatible(file.MimeType, ct) {
 		return model.PolicyErrorExtSuspicious
 	}
 
-	// File mime type is not in the allowed list
-	return model.PolicyErrorExtNotAllowed
+	return nil
+}
+
+func (p *FilePolicy) checkExtension(ext string) engine.AppError {
+	if _, ok := p.denyExt[ext]; ok {
+		return model.PolicyErrorExtDenied
+	}
+
+	if len(p.allowExt) != 0 {
+		if _, ok := p.allowExt[ext]; !ok {
+			return model.PolicyErrorExtNotAllowed
+		}
+		return nil
+	}
+
+	if utils.IsDangerousExtension(ext) {
+		return model.PolicyErrorExtDenied
+	}
+
+	return nil
+}
+
+func extensionsSet(list []string) map[string]struct{} {
+	if len(list) == 0 {
+		return nil
+	}
+
+	res := make(map[string]struct{}, len(list))
+	for _, v := range list {
+		res[strings.ToLower(strings.TrimPrefix(strings.TrimSpace(v), "."))] = struct{}{}
+	}
+
+	return res
 }
 
 // MatchPattern перевіряє, чи відповідає рядок заданому патерну
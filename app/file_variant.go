@@ -0,0 +1,249 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	engine "github.com/webitel/engine/model"
+	"github.com/webitel/storage/model"
+	"github.com/webitel/storage/transcoding"
+	"github.com/webitel/storage/utils"
+	"github.com/webitel/wlog"
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	fileVariantGroup singleflight.Group
+)
+
+// GetImageVariant returns an image variant, it is created from the original on the first request
+// and stored in the storage of the parent file
+func (app *App) GetImageVariant(ctx context.Context, domainId, fileId int64, v *model.ImageVariant) (*model.FileVariant, utils.FileBackend, engine.AppError) {
+	file, backend, err := app.GetFileWithProfile(domainId, fileId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !utils.IsSupportImageVariant(file.MimeType) {
+		return nil, nil, engine.NewBadRequestError("app.file_variant.image.mime_type", "not supported mime type "+file.MimeType)
+	}
+
+	v.SetDefaults(file.MimeType)
+	if err = v.IsValid(app.imageVariantMaxSize(ctx, domainId)); err != nil {
+		return nil, nil, err
+	}
+
+	variant, err := app.fileVariant(ctx, file, backend, v.Key(), func() (*model.FileVariant, engine.AppError) {
+		return app.createImageVariant(ctx, file, backend, v)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return variant, backend, nil
+}
+
+// GetWaveformVariant returns the peaks of the audio file, they are computed from the original on the first request.
+// Access to the file is checked by the caller
+func (app *App) GetWaveformVariant(ctx context.Context, file *model.File, backend utils.FileBackend, v *model.WaveformVariant) (*model.FileVariant, engine.AppError) {
+	if !utils.IsSupportWaveform(file.MimeType) {
+		return nil, engine.NewBadRequestError("app.file_variant.waveform.mime_type", "not supported mime type "+file.MimeType)
+	}
+
+	v.SetDefaults()
+	if err := v.IsValid(); err != nil {
+		return nil, err
+	}
+
+	return app.fileVariant(ctx, file, backend, v.Key(), func() (*model.FileVariant, engine.AppError) {
+		return app.createWaveformVariant(ctx, file, backend, v)
+	})
+}
+
+// GetClipVariant returns a clip of the audio or video file, it is cut from the original on the first request.
+// Access to the file is checked by the caller
+func (app *App) GetClipVariant(ctx context.Context, file *model.File, backend utils.FileBackend, v *model.ClipVariant) (*model.FileVariant, engine.AppError) {
+	if !transcoding.IsSupportClip(file.MimeType) {
+		return nil, engine.NewBadRequestError("app.file_variant.clip.mime_type", "not supported mime type "+file.MimeType)
+	}
+
+	duration := file.MediaDuration()
+	if err := v.IsValid(duration); err != nil {
+		return nil, err
+	}
+
+	key := v.Key(duration)
+
+	return app.fileVariant(ctx, file, backend, key, func() (*model.FileVariant, engine.AppError) {
+		return app.createClipVariant(ctx, file, backend, v, key)
+	})
+}
+
+// RemoveFileVariants removes the derived files together with the parent
+func (app *App) RemoveFileVariants(ctx context.Context, backend utils.FileBackend, fileId int64) engine.AppError {
+	list, err := app.Store.FileVariant().GetAllByFileId(ctx, fileId)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range list {
+		if err = backend.Remove(v); err != nil && err.GetStatusCode() != http.StatusNotFound {
+			app.Log.Error(fmt.Sprintf("file %d, variant \"%s\" remove error: %s", fileId, v.Key, err.Error()), wlog.Err(err))
+		}
+	}
+
+	if len(list) == 0 {
+		return nil
+	}
+
+	return app.Store.FileVariant().DeleteByFileId(ctx, fileId)
+}
+
+// fileVariant looks up a stored derived file or creates it; concurrent requests for the same variant
+// are executed once
+func (app *App) fileVariant(ctx context.Context, file *model.File, backend utils.FileBackend, key string,
+	create func() (*model.FileVariant, engine.AppError)) (*model.FileVariant, engine.AppError) {
+
+	variant, err := app.Store.FileVariant().Get(ctx, file.Id, key)
+	if err == nil {
+		return variant, nil
+	} else if err.GetStatusCode() != http.StatusNotFound {
+		return nil, err
+	}
+
+	res, e, _ := fileVariantGroup.Do(fmt.Sprintf("%d-%s", file.Id, key), func() (interface{}, error) {
+		v, err := create()
+		if err != nil {
+			return nil, err
+		}
+
+		return v, nil
+	})
+
+	if e != nil {
+		switch e.(type) {
+		case engine.AppError:
+			return nil, e.(engine.AppError)
+		default:
+			return nil, engine.NewInternalError("app.file_variant.create", e.Error())
+		}
+	}
+
+	return res.(*model.FileVariant), nil
+}
+
+func (app *App) createImageVariant(ctx context.Context, file *model.File, backend utils.FileBackend, v *model.ImageVariant) (*model.FileVariant, engine.AppError) {
+	src, err := app.FileReader(ctx, backend, file, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	out, e := utils.NewImageVariant(src, v)
+	if e != nil {
+		return nil, engine.NewInternalError("app.file_variant.image.create", e.Error())
+	}
+
+	return app.storeFileVariant(ctx, file, backend, v.Key(), v.MimeType(), out)
+}
+
+func (app *App) createWaveformVariant(ctx context.Context, file *model.File, backend utils.FileBackend, v *model.WaveformVariant) (*model.FileVariant, engine.AppError) {
+	src, err := app.FileReader(ctx, backend, file, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	waveform, e := utils.NewWaveform(src, model.WaveformSampleRate, v.SamplesPerPixel)
+	if e != nil {
+		return nil, engine.NewInternalError("app.file_variant.waveform.create", e.Error())
+	}
+
+	data, _ := json.Marshal(waveform)
+
+	return app.storeFileVariant(ctx, file, backend, v.Key(), v.MimeType(), io.NopCloser(bytes.NewReader(data)))
+}
+
+func (app *App) createClipVariant(ctx context.Context, file *model.File, backend utils.FileBackend, v *model.ClipVariant, key string) (*model.FileVariant, engine.AppError) {
+	src, err := app.FileReader(ctx, backend, file, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	out, e := transcoding.NewClipReader(src, file.MimeType, v.StartSec, v.EndSec)
+	if e != nil {
+		return nil, engine.NewInternalError("app.file_variant.clip.create", e.Error())
+	}
+
+	return app.storeFileVariant(ctx, file, backend, key, transcoding.ClipMimeType(file.MimeType), out)
+}
+
+// storeFileVariant writes the src result to the storage and saves the reference to the parent file.
+// Close of src returns the generation error, in that case the written file is removed
+func (app *App) storeFileVariant(ctx context.Context, file *model.File, backend utils.FileBackend, key, mime string, src io.ReadCloser) (*model.FileVariant, engine.AppError) {
+	variant := &model.FileVariant{
+		BaseFile: model.BaseFile{
+			Name:       fmt.Sprintf("variant_%d_%s", file.Id, key),
+			MimeType:   mime,
+			Properties: model.StringInterface{},
+			Instance:   app.GetInstanceId(),
+			Channel:    file.Channel,
+		},
+		FileId:    file.Id,
+		DomainId:  file.DomainId,
+		Uuid:      file.Uuid,
+		Key:       key,
+		CreatedAt: model.GetMillis(),
+	}
+
+	h := sha256.New()
+	size, err := app.WriteFile(ctx, backend, io.TeeReader(src, h), variant)
+	if err != nil && err.GetId() == utils.ErrFileWriteExistsId {
+		// leftover of a previous failed attempt
+		if err = backend.Remove(variant); err == nil {
+			size, err = app.WriteFile(ctx, backend, io.TeeReader(src, h), variant)
+		}
+	}
+
+	if e := src.Close(); e != nil && err == nil {
+		err = engine.NewInternalError("app.file_variant.create", e.Error())
+	}
+
+	if err == nil && size == 0 {
+		err = engine.NewInternalError("app.file_variant.create", "empty result")
+	}
+
+	if err != nil {
+		if size > 0 {
+			backend.Remove(variant)
+		}
+		return nil, err
+	}
+
+	sha := fmt.Sprintf("%x", h.Sum(nil))
+	variant.Size = size
+	variant.SHA256Sum = &sha
+
+	if variant, err = app.Store.FileVariant().Save(ctx, variant); err != nil {
+		return nil, err
+	}
+
+	wlog.Debug(fmt.Sprintf("created variant \"%s\" of file %d in %s, %d bytes", key, file.Id, backend.Name(), size))
+
+	return variant, nil
+}
+
+func (app *App) imageVariantMaxSize(ctx context.Context, domainId int64) int {
+	v, _ := app.GetCachedSystemSetting(ctx, domainId, model.SysNameImageVariantMaxSize)
+	if max := v.Int(); max != nil && *max > 0 {
+		return *max
+	}
+
+	return app.thumbnailSettings.VariantMaxSize
+}
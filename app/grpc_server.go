@@ -13,15 +13,20 @@ import (
 	"github.com/webitel/engine/utils"
 	"github.com/webitel/storage/model"
 	"github.com/webitel/wlog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
 type GrpcServer struct {
-	srv *grpc.Server
-	lis net.Listener
+	srv    *grpc.Server
+	lis    net.Listener
+	health *health.Server
 }
 
 func (grpc *GrpcServer) GetPublicInterface() (string, int) {
@@ -38,10 +43,13 @@ func unaryInterceptor(ctx context.Context,
 	info *grpc.UnaryServerInfo,
 	handler grpc.UnaryHandler) (interface{}, error) {
 	start := time.Now()
+	ctx, span := startGrpcSpan(ctx, info.FullMethod)
+	defer span.End()
 
 	h, err := handler(ctx, req)
 
 	if err != nil {
+		endGrpcSpanError(span, err)
 		wlog.Error(fmt.Sprintf("method %s duration %s, error: %v", info.FullMethod, time.Since(start), err.Error()))
 
 		switch err.(type) {
@@ -67,10 +75,13 @@ func unaryInterceptor(ctx context.Context,
 
 func streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
 	start := time.Now()
+	ctx, span := startGrpcSpan(ss.Context(), info.FullMethod)
+	defer span.End()
 
-	err := handler(srv, ss)
+	err := handler(srv, &tracedStream{ServerStream: ss, ctx: ctx})
 
 	if err != nil {
+		endGrpcSpanError(span, err)
 		wlog.Error(fmt.Sprintf("method %s duration %s, error: %v", info.FullMethod, time.Since(start), err.Error()))
 
 		switch err.(type) {
@@ -87,6 +98,64 @@ func streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamS
 	return err
 }
 
+// tracedStream passes the context with the call span to the handler
+type tracedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *tracedStream) Context() context.Context {
+	return s.ctx
+}
+
+// metadataCarrier reads the trace context from the gRPC request metadata
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	v := metadata.MD(c).Get(key)
+	if len(v) == 0 {
+		return ""
+	}
+	return v[0]
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func startGrpcSpan(ctx context.Context, method string) (context.Context, trace.Span) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+	}
+
+	return tracer.Start(ctx, method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "grpc"),
+			attribute.String("rpc.method", method),
+		),
+	)
+}
+
+func endGrpcSpanError(span trace.Span, err error) {
+	if e, ok := err.(engine.AppError); ok {
+		span.SetAttributes(attribute.Int("rpc.grpc.status_code", int(httpCodeToGrpc(e.GetStatusCode()))))
+		if e.GetStatusCode() < http.StatusInternalServerError {
+			return
+		}
+	}
+
+	spanError(span, err)
+}
+
 func httpCodeToGrpc(c int) codes.Code {
 	switch c {
 	case http.StatusBadRequest:
@@ -99,6 +168,8 @@ func httpCodeToGrpc(c int) codes.Code {
 		return codes.PermissionDenied
 	case http.StatusNotFound:
 		return codes.NotFound
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
 	default:
 		return codes.Internal
 
@@ -125,6 +196,8 @@ func (s *GrpcServer) Server() *grpc.Server {
 }
 
 func (a *App) StartGrpcServer() error {
+	a.registerGrpcHealth()
+
 	go func() {
 		defer wlog.Debug(fmt.Sprintf("[grpc] close server listening"))
 		wlog.Debug(fmt.Sprintf("[grpc] server listening %s", a.GrpcServer.lis.Addr().String()))
@@ -0,0 +1,221 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/webitel/storage/model"
+	"github.com/webitel/storage/utils"
+	"github.com/webitel/wlog"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// healthCacheTime the dependency check result is shared by the probes of all servers and gRPC health
+const healthCacheTime = 5 * time.Second
+
+type healthState struct {
+	mx      sync.Mutex
+	last    *model.Health
+	checked time.Time
+	stop    chan struct{}
+}
+
+// Liveness the process responds; dependencies are not checked so that their failure does not restart the instance
+func (app *App) Liveness() *model.Health {
+	return model.NewHealth(nil)
+}
+
+// Readiness checks the dependencies; the instance is not ready if a critical check failed or the server is stopping.
+// The result is cached for healthCacheTime
+func (app *App) Readiness(ctx context.Context) *model.Health {
+	if app.IsDraining() {
+		return model.NewHealth([]*model.HealthCheck{
+			model.NewHealthCheck("drain", true, errors.New("server is shutting down")),
+		})
+	}
+
+	s := &app.health
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if s.last != nil && time.Since(s.checked) < healthCacheTime {
+		return s.last
+	}
+
+	// the result is shared, so cancelling the request that started the check must not affect it
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), app.Config().Health.GetTimeout())
+	defer cancel()
+
+	s.last = model.NewHealth(app.healthChecks(ctx))
+	s.checked = time.Now()
+
+	if !s.last.Up() {
+		wlog.Warn(fmt.Sprintf("instance is not ready: %s", s.last.ToJson()))
+	}
+
+	return s.last
+}
+
+func (app *App) healthChecks(ctx context.Context) []*model.HealthCheck {
+	var wg sync.WaitGroup
+	var mx sync.Mutex
+	var checks []*model.HealthCheck
+
+	run := func(name string, critical bool, check func(ctx context.Context) error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := healthCheck(ctx, name, critical, check)
+			mx.Lock()
+			checks = append(checks, c)
+			mx.Unlock()
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c := app.Store.Ping(ctx)
+		mx.Lock()
+		checks = append(checks, c...)
+		mx.Unlock()
+	}()
+
+	run("file_cache", true, app.checkFileCacheDisk)
+
+	if app.UseDefaultStore() {
+		run("store_default", true, backendCheck(app.DefaultFileStore))
+	}
+
+	// domain storage profiles do not affect readiness: an unavailable S3 of one domain must not stop all traffic
+	for _, v := range app.fileBackendCache.Values() {
+		backend := v.(utils.FileBackend)
+		run("store_"+backend.Name(), false, backendCheck(backend))
+	}
+
+	run("ffmpeg", false, binaryCheck("ffmpeg"))
+	run("ffprobe", false, binaryCheck("ffprobe"))
+	run("consul", false, app.checkDiscovery)
+
+	wg.Wait()
+
+	return checks
+}
+
+// healthCheck checks without ctx support (TestConnection) are considered failed after the timeout, the goroutine finishes on its own
+func healthCheck(ctx context.Context, name string, critical bool, check func(ctx context.Context) error) *model.HealthCheck {
+	res := make(chan error, 1)
+	go func() {
+		res <- check(ctx)
+	}()
+
+	select {
+	case err := <-res:
+		return model.NewHealthCheck(name, critical, err)
+	case <-ctx.Done():
+		return model.NewHealthCheck(name, critical, ctx.Err())
+	}
+}
+
+// checkFileCacheDisk uploads are written to FileCache first, so they start failing when the disk is full
+func (app *App) checkFileCacheDisk(_ context.Context) error {
+	free, err := utils.DiskFreePercent(model.CacheDir)
+	if err != nil {
+		return err
+	}
+
+	if min := app.Config().Health.FileCacheMinFree; free < float64(min) {
+		return fmt.Errorf("free disk space %.1f%%, minimum %d%%", free, min)
+	}
+
+	return nil
+}
+
+func (app *App) checkDiscovery(_ context.Context) error {
+	if app.cluster == nil || app.cluster.discovery == nil {
+		return errors.New("not registered")
+	}
+
+	list, err := app.cluster.discovery.GetByName(model.APP_SERVICE_NAME)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range list {
+		if v.Id == *app.id {
+			return nil
+		}
+	}
+
+	return errors.New("service " + *app.id + " not found")
+}
+
+// backendCheck check bypassing metrics and tracing, so that periodic probes do not create spans
+func backendCheck(backend utils.FileBackend) func(ctx context.Context) error {
+	if b, ok := backend.(*instrumentedBackend); ok {
+		backend = b.FileBackend
+	}
+
+	return func(_ context.Context) error {
+		if err := backend.TestConnection(); err != nil {
+			return err
+		}
+		return nil
+	}
+}
+
+func binaryCheck(name string) func(ctx context.Context) error {
+	return func(_ context.Context) error {
+		_, err := exec.LookPath(name)
+		return err
+	}
+}
+
+// registerGrpcHealth standard gRPC health: "" - the process is running, APP_SERVICE_NAME - ready to accept requests
+func (app *App) registerGrpcHealth() {
+	hs := health.NewServer()
+	hs.SetServingStatus(model.APP_SERVICE_NAME, grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	grpc_health_v1.RegisterHealthServer(app.GrpcServer.srv, hs)
+	app.GrpcServer.health = hs
+	app.health.stop = make(chan struct{})
+
+	go app.watchGrpcHealth()
+}
+
+func (app *App) watchGrpcHealth() {
+	ticker := time.NewTicker(healthCacheTime)
+	defer ticker.Stop()
+
+	for {
+		status := grpc_health_v1.HealthCheckResponse_NOT_SERVING
+		if app.Readiness(context.Background()).Up() {
+			status = grpc_health_v1.HealthCheckResponse_SERVING
+		}
+		app.GrpcServer.health.SetServingStatus(model.APP_SERVICE_NAME, status)
+
+		select {
+		case <-app.health.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// stopGrpcHealth all services switch to NOT_SERVING, further status changes are ignored
+func (app *App) stopGrpcHealth() {
+	if app.GrpcServer == nil || app.GrpcServer.health == nil {
+		return
+	}
+
+	app.GrpcServer.health.Shutdown()
+	select {
+	case <-app.health.stop:
+	default:
+		close(app.health.stop)
+	}
+}
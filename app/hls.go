@@ -0,0 +1,156 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	engine "github.com/webitel/engine/model"
+	"github.com/webitel/storage/model"
+	"github.com/webitel/storage/transcoding"
+	"github.com/webitel/storage/utils"
+)
+
+const (
+	hlsPlaylistMaxSize = 4 << 20
+	hlsAction          = "hls"
+)
+
+// SignedHLSPlaylist returns the HLS playlist of the file in which links to the variant playlists and segments
+// are replaced with signed relative URLs. name - variant playlist, empty - master playlist.
+// HLS is created on the first request and stored as derived files in the storage of the file
+func (app *App) SignedHLSPlaylist(ctx context.Context, file *model.File, backend utils.FileBackend, name string) ([]byte, engine.AppError) {
+	var variant *model.FileVariant
+	var err engine.AppError
+
+	if name == "" {
+		variant, err = app.getHLS(ctx, file, backend)
+	} else {
+		variant, err = app.GetHLSFile(ctx, file, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := app.FileReader(ctx, backend, variant, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	data, e := io.ReadAll(io.LimitReader(r, hlsPlaylistMaxSize))
+	if e != nil {
+		return nil, engine.NewInternalError("app.hls.playlist.read", e.Error())
+	}
+
+	// links stay valid for the whole playback with a margin for pauses
+	expires := app.Config().PreSignedTimeout + int64(2*file.MediaDuration()*1000)
+	prefix := fmt.Sprintf("%s/%d/", model.AnyFileRouteName, file.Id)
+
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			param := "segment"
+			if strings.HasSuffix(line, ".m3u8") {
+				param = "playlist"
+			}
+
+			uri, err := app.GeneratePreSignedResourceSignatureBulk(file.Id, file.DomainId, model.AnyFileRouteName, hlsAction, "",
+				map[string]string{param: line, "expires": strconv.FormatInt(expires, 10)})
+			if err != nil {
+				return nil, err
+			}
+			line = strings.TrimPrefix(uri, prefix)
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+
+	return out.Bytes(), nil
+}
+
+// GetHLSFile returns a stored HLS variant playlist or segment
+func (app *App) GetHLSFile(ctx context.Context, file *model.File, name string) (*model.FileVariant, engine.AppError) {
+	if !transcoding.IsHLSFile(name) {
+		return nil, engine.NewBadRequestError("app.hls.file.name", "bad hls file name "+name)
+	}
+
+	return app.Store.FileVariant().Get(ctx, file.Id, name)
+}
+
+func (app *App) getHLS(ctx context.Context, file *model.File, backend utils.FileBackend) (*model.FileVariant, engine.AppError) {
+	if !utils.IsSupportMediaProbe(file.MimeType) {
+		return nil, engine.NewBadRequestError("app.hls.mime_type", "not supported mime type "+file.MimeType)
+	}
+
+	return app.fileVariant(ctx, file, backend, transcoding.HLSMasterPlaylist, func() (*model.FileVariant, engine.AppError) {
+		return app.createHLS(ctx, file, backend)
+	})
+}
+
+// createHLS segments the file into a temporary directory and stores the result; the master playlist is stored last,
+// so HLS is created again after an error
+func (app *App) createHLS(ctx context.Context, file *model.File, backend utils.FileBackend) (*model.FileVariant, engine.AppError) {
+	dir, e := os.MkdirTemp("", fmt.Sprintf("hls_%d_", file.Id))
+	if e != nil {
+		return nil, engine.NewInternalError("app.hls.create", e.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	src, err := app.FileReader(ctx, backend, file, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	video := strings.HasPrefix(file.MimeType, "video/")
+	// channels are known only for files with an audio stream, audio files always have them
+	audio := !video || (file.Channels != nil && *file.Channels > 0)
+
+	if e = transcoding.Run(src, transcoding.HLSArgs(dir, video, audio)); e != nil {
+		return nil, engine.NewInternalError("app.hls.create", e.Error())
+	}
+
+	entries, e := os.ReadDir(dir)
+	if e != nil {
+		return nil, engine.NewInternalError("app.hls.create", e.Error())
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, v := range entries {
+		if name := v.Name(); name != transcoding.HLSMasterPlaylist && transcoding.IsHLSFile(name) {
+			names = append(names, name)
+		}
+	}
+	// segments before playlists
+	sort.Slice(names, func(i, j int) bool {
+		if a, b := strings.HasSuffix(names[i], ".m3u8"), strings.HasSuffix(names[j], ".m3u8"); a != b {
+			return b
+		}
+		return names[i] < names[j]
+	})
+	names = append(names, transcoding.HLSMasterPlaylist)
+
+	var master *model.FileVariant
+	for _, name := range names {
+		f, e := os.Open(filepath.Join(dir, name))
+		if e != nil {
+			return nil, engine.NewInternalError("app.hls.create", e.Error())
+		}
+
+		if master, err = app.storeFileVariant(ctx, file, backend, name, transcoding.HLSMimeType(name), f); err != nil {
+			return nil, err
+		}
+	}
+
+	return master, nil
+}
@@ -0,0 +1,80 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	engine "github.com/webitel/engine/model"
+	"github.com/webitel/storage/model"
+	"github.com/webitel/wlog"
+)
+
+func (app *App) SearchJobs(ctx context.Context, search *model.SearchJob) ([]*model.Job, bool, engine.AppError) {
+	jobs, err := app.Store.Job().Search(ctx, search)
+	if err != nil {
+		return nil, false, err
+	}
+	search.RemoveLastElemIfNeed(&jobs)
+
+	return jobs, search.EndOfList(), nil
+}
+
+func (app *App) GetJob(id string) (*model.Job, engine.AppError) {
+	result := <-app.Store.Job().Get(id)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+
+	return result.Data.(*model.Job), nil
+}
+
+// CreateJob queues a job for immediate execution, the job type must be registered in the job server
+func (app *App) CreateJob(jobType string, data map[string]string) (*model.Job, engine.AppError) {
+	if app.Jobs == nil || !app.Jobs.HasJobType(jobType) {
+		return nil, engine.NewBadRequestError("app.job.create.type", "not supported job type "+jobType)
+	}
+
+	job := model.NewJob(jobType, data)
+	if err := job.IsValid(); err != nil {
+		return nil, err
+	}
+
+	job, err := app.Store.Job().Save(job)
+	if err != nil {
+		return nil, err
+	}
+
+	wlog.Debug(fmt.Sprintf("created job %s [%s]", job.Id, job.Type))
+
+	return job, nil
+}
+
+// CancelJob a queued job is cancelled at once, a running job is asked to be cancelled by its worker
+func (app *App) CancelJob(id string) (*model.Job, engine.AppError) {
+	job, err := app.GetJob(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if job.Status == model.JOB_STATUS_PENDING {
+		result := <-app.Store.Job().UpdateStatusOptimistically(id, model.JOB_STATUS_PENDING, model.JOB_STATUS_CANCELED)
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		if result.Data.(bool) {
+			return app.GetJob(id)
+		}
+	}
+
+	result := <-app.Store.Job().UpdateStatusOptimistically(id, model.JOB_STATUS_IN_PROGRESS, model.JOB_STATUS_CANCEL_REQUESTED)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+
+	if !result.Data.(bool) {
+		return nil, engine.NewCustomCodeError("app.job.cancel.status", fmt.Sprintf("job %s is not active", id), http.StatusConflict)
+	}
+
+	return app.GetJob(id)
+}
@@ -1,45 +1,104 @@
 package app
 
 import (
+	"context"
 	"io"
 
 	engine "github.com/webitel/engine/model"
 	"github.com/webitel/storage/model"
+	"github.com/webitel/storage/utils"
 )
 
 func (app *App) SaveMediaFile(src io.ReadCloser, mediaFile *model.MediaFile) (*model.MediaFile, engine.AppError) {
-	var size int64
-	var err engine.AppError
+	done, err := app.BeginWork()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
 
 	mediaFile.Channel = model.NewString(model.UploadFileChannelMedia)
+	normalizeMediaLibrary(mediaFile)
 
 	if err = mediaFile.IsValid(); err != nil {
 		return nil, err
 	}
 
-	src, err = app.FilePolicyForUpload(mediaFile.DomainId, &mediaFile.BaseFile, src)
+	src, err = app.FilePolicyForUpload(context.Background(), mediaFile.DomainId, &mediaFile.BaseFile, src)
 	if err != nil {
 		return nil, err
 	}
 
-	size, err = app.MediaFileStore.Write(src, mediaFile)
+	if mediaFile.Properties == nil {
+		mediaFile.Properties = model.StringInterface{}
+	}
+
+	backend, err := app.writeMediaContent(src, mediaFile)
 	if err != nil {
 		return nil, err
 	}
-	mediaFile.Size = size
 	mediaFile.Instance = app.GetInstanceId()
 
-	if mediaFile, err = app.Store.MediaFile().Create(mediaFile); err != nil {
+	var saved *model.MediaFile
+	if saved, err = app.Store.MediaFile().Create(mediaFile); err != nil {
 		if err.GetId() != "store.sql_media_file.save.saving.duplicate" {
-			app.MediaFileStore.Remove(mediaFile)
+			backend.Remove(mediaFile)
 		}
+		app.removeMediaOriginal(backend, mediaFile)
+		return nil, err
+	} else {
+		return saved, nil
+	}
+}
+
+// writeMediaContent writes the content to the domain storage, audio is normalized according to the settings
+func (app *App) writeMediaContent(src io.Reader, mediaFile *model.MediaFile) (utils.FileBackend, engine.AppError) {
+	var size int64
+
+	backend, err := app.mediaWriteBackend(mediaFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if src, err = ReadUploadHead(src); err != nil {
 		return nil, err
+	}
+
+	normalize, err := app.mediaNormalizeName(mediaFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if normalize {
+		size, err = app.writeNormalizedMediaFile(backend, src, mediaFile)
 	} else {
-		return mediaFile, nil
+		size, err = app.writeMediaFile(backend, src, mediaFile)
 	}
+	if err != nil {
+		return nil, err
+	}
+	mediaFile.Size = size
+
+	return backend, nil
+}
+
+// normalizeMediaLibrary folder path without extra "/" and lower case tags
+func normalizeMediaLibrary(mediaFile *model.MediaFile) {
+	if mediaFile.Folder != nil {
+		if folder := model.NormalizeMediaFolder(*mediaFile.Folder); folder != "" {
+			mediaFile.Folder = &folder
+		} else {
+			mediaFile.Folder = nil
+		}
+	}
+	mediaFile.Tags = model.NormalizeMediaTags(mediaFile.Tags)
 }
 
 func (app *App) GetMediaFilePage(domainId int64, search *model.SearchMediaFile) ([]*model.MediaFile, bool, engine.AppError) {
+	if search.Folder != nil {
+		search.Folder = model.NewString(model.NormalizeMediaFolder(*search.Folder))
+	}
+	search.Tags = model.NormalizeMediaTags(search.Tags)
+
 	files, err := app.Store.MediaFile().GetAllPage(domainId, search)
 	if err != nil {
 		return nil, false, err
@@ -57,13 +116,33 @@ func (app *App) GetMediaFile(domainId int64, id int) (mf *model.MediaFile, err e
 	return
 }
 
-func (app *App) DeleteMediaFile(domainId int64, id int) (*model.MediaFile, engine.AppError) {
+// GetMediaFolders folders of the domain media library
+func (app *App) GetMediaFolders(ctx context.Context, domainId int64) ([]*model.MediaFolder, engine.AppError) {
+	return app.Store.MediaFile().GetFolders(ctx, domainId)
+}
+
+// BulkUpdateMediaFiles moves media files to a folder and adds or removes tags
+func (app *App) BulkUpdateMediaFiles(ctx context.Context, domainId int64, bulk *model.MediaFilesBulk, updatedBy *model.Lookup) (int64, engine.AppError) {
+	bulk.Normalize()
+	if err := bulk.IsValid(); err != nil {
+		return 0, err
+	}
+
+	return app.Store.MediaFile().BulkUpdate(ctx, domainId, bulk, updatedBy)
+}
+
+// DeleteMediaFile removes the media file; a file referenced by other services is removed only with force
+func (app *App) DeleteMediaFile(domainId int64, id int, force bool) (*model.MediaFile, engine.AppError) {
 	file, err := app.Store.MediaFile().Get(domainId, id)
 	if err != nil {
 		return nil, err
 	}
 
-	if err = app.MediaFileStore.Remove(file); err != nil {
+	if file.ReferenceCount > 0 && !force {
+		return nil, mediaFileReferencedError(file)
+	}
+
+	if err = app.removeMediaContent(file); err != nil {
 		return nil, err
 	}
 
@@ -89,7 +168,16 @@ func (app *App) RemoveMediaFileByName(name, domain string) (file *model.MediaFil
 		return
 	}
 
-	err = app.MediaFileStore.Remove(file)
+	refs, err := app.Store.MediaFile().GetReferences(context.Background(), file.DomainId, file.Id)
+	if err != nil {
+		return
+	}
+	if len(refs) > 0 {
+		file.ReferenceCount = int64(len(refs))
+		return nil, mediaFileReferencedError(file)
+	}
+
+	err = app.removeMediaContent(file)
 	if err != nil {
 		return
 	}
@@ -0,0 +1,187 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	engine "github.com/webitel/engine/model"
+	"github.com/webitel/storage/model"
+	"github.com/webitel/storage/utils"
+	"github.com/webitel/wlog"
+)
+
+// MediaFileBackend storage of the media file: a local directory, or a storage profile with a local read cache
+func (app *App) MediaFileBackend(file *model.MediaFile) (utils.FileBackend, engine.AppError) {
+	if file.ProfileId == nil {
+		return app.MediaFileStore, nil
+	}
+
+	return app.mediaProfileBackend(file.DomainId, *file.ProfileId)
+}
+
+// mediaProfileBackend wraps the profile storage with a cache; the profile cache is recreated when the profile settings change
+func (app *App) mediaProfileBackend(domainId int64, profileId int) (utils.FileBackend, engine.AppError) {
+	backend, err := app.GetFileBackendStoreById(domainId, profileId)
+	if err != nil {
+		return nil, err
+	}
+
+	if c, ok := app.mediaBackendCache.Get(profileId); ok {
+		if cached := c.(*utils.CachedFileBackend); cached.Backend() == backend {
+			return cached, nil
+		}
+	}
+
+	cached := utils.NewCachedFileBackend(backend, app.mediaReadCache)
+	app.mediaBackendCache.Add(profileId, cached)
+
+	return cached, nil
+}
+
+// purgeMediaCache removes the local cached copies of the file content once another version becomes active
+func (app *App) purgeMediaCache(file *model.MediaFile) {
+	backend, err := app.MediaFileBackend(file)
+	if err != nil {
+		return
+	}
+
+	if cached, ok := backend.(*utils.CachedFileBackend); ok {
+		cached.Purge(file)
+	}
+}
+
+// mediaWriteBackend storage for new media files of the domain according to media_backend_profile
+func (app *App) mediaWriteBackend(mediaFile *model.MediaFile) (utils.FileBackend, engine.AppError) {
+	v, _ := app.GetCachedSystemSetting(context.Background(), mediaFile.DomainId, model.SysNameMediaBackendProfile)
+	if v.Int() == nil || *v.Int() == 0 {
+		mediaFile.ProfileId = nil
+		return app.MediaFileStore, nil
+	}
+
+	backend, err := app.mediaProfileBackend(mediaFile.DomainId, *v.Int())
+	if err != nil {
+		return nil, err
+	}
+	mediaFile.ProfileId = model.NewInt(*v.Int())
+
+	return backend, nil
+}
+
+// MigrateMediaFiles moves a batch of the domain media files to the storage profile; a file is switched
+// to the new storage only after a successful write, then it is removed from the old one
+func (app *App) MigrateMediaFiles(ctx context.Context, domainId int64, migration *model.MediaMigration) (*model.MediaMigrationResult, engine.AppError) {
+	if err := migration.IsValid(); err != nil {
+		return nil, err
+	}
+
+	limit := migration.Limit
+	if limit == 0 {
+		limit = model.MediaMigrationDefaultLimit
+	}
+
+	dst, err := app.mediaProfileBackend(domainId, migration.ProfileId)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := app.Store.MediaFile().GetForMigration(ctx, domainId, migration.ProfileId, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &model.MediaMigrationResult{}
+	for _, f := range files {
+		if err = app.migrateMediaFile(ctx, f, dst, migration.ProfileId); err != nil {
+			app.Log.Error(fmt.Sprintf("media file %d, migrate to profile %d error: %s", f.Id, migration.ProfileId, err.Error()), wlog.Err(err))
+			res.Failed++
+		} else {
+			res.Migrated++
+		}
+	}
+
+	wlog.Debug(fmt.Sprintf("domain %d, migrated %d media files to profile %d, failed %d", domainId, res.Migrated, migration.ProfileId, res.Failed))
+
+	return res, nil
+}
+
+func (app *App) migrateMediaFile(ctx context.Context, file *model.MediaFile, dst utils.FileBackend, profileId int) engine.AppError {
+	src, err := app.MediaFileBackend(file)
+	if err != nil {
+		return err
+	}
+
+	moved, err := copyMediaFile(src, dst, file)
+	if err != nil {
+		return err
+	}
+
+	var original, movedOriginal *model.MediaFile
+	if original = mediaOriginal(file); original != nil {
+		if movedOriginal, err = copyMediaFile(src, dst, original); err != nil {
+			dst.Remove(moved)
+			return err
+		}
+
+		props := map[string]interface{}{}
+		if v, ok := file.Properties[model.MediaFilePropertyOriginal].(map[string]interface{}); ok {
+			for k, p := range v {
+				props[k] = p
+			}
+		}
+		props["properties"] = movedOriginal.Properties
+		moved.Properties[model.MediaFilePropertyOriginal] = props
+	}
+
+	if err = app.Store.MediaFile().SetProfile(ctx, file.Id, model.NewInt(profileId), moved.Properties); err != nil {
+		dst.Remove(moved)
+		if movedOriginal != nil {
+			dst.Remove(movedOriginal)
+		}
+		return err
+	}
+
+	if err = src.Remove(file); err != nil {
+		app.Log.Error(fmt.Sprintf("media file %d, remove after migration error: %s", file.Id, err.Error()), wlog.Err(err))
+	}
+	if original != nil {
+		if err = src.Remove(original); err != nil {
+			app.Log.Error(fmt.Sprintf("media file %d, remove original after migration error: %s", file.Id, err.Error()), wlog.Err(err))
+		}
+	}
+
+	return nil
+}
+
+// copyMediaFile writes a copy of the file to dst, the properties of the copy contain the new location
+func copyMediaFile(src, dst utils.FileBackend, file *model.MediaFile) (*model.MediaFile, engine.AppError) {
+	r, err := src.Reader(file, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	moved := *file
+	moved.Properties = model.StringInterface{}
+	for k, v := range file.Properties {
+		moved.Properties[k] = v
+	}
+
+	if _, err = dst.Write(r, &moved); err != nil {
+		if err.GetId() != utils.ErrFileWriteExistsId {
+			return nil, err
+		}
+		// leftover of a previous failed attempt
+		if err = dst.Remove(&moved); err != nil {
+			return nil, err
+		}
+		if r, err = src.Reader(file, 0); err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		if _, err = dst.Write(r, &moved); err != nil {
+			return nil, err
+		}
+	}
+
+	return &moved, nil
+}
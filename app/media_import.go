@@ -0,0 +1,224 @@
+package app
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"strings"
+
+	engine "github.com/webitel/engine/model"
+	"github.com/webitel/storage/model"
+	"github.com/webitel/storage/utils"
+	"github.com/webitel/wlog"
+)
+
+type mediaImportEntry struct {
+	zip  *zip.File
+	file *model.MediaFile
+	item *model.MediaImportItem
+}
+
+// ImportMediaFiles creates media files from a ZIP archive. All entries are checked before the first file is created;
+// in atomic mode an error in any entry cancels the import and the created files are removed
+func (app *App) ImportMediaFiles(ctx context.Context, archive io.ReaderAt, size int64, imp *model.MediaImport, record model.DomainRecord) (*model.MediaImportResult, engine.AppError) {
+	zr, e := zip.NewReader(archive, size)
+	if e != nil {
+		return nil, engine.NewBadRequestError("app.media.import.zip", e.Error())
+	}
+
+	manifest, err := readMediaImportManifest(zr)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := app.mediaImportEntries(zr, manifest, imp, record)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &model.MediaImportResult{
+		Items: make([]*model.MediaImportItem, 0, len(entries)),
+	}
+	for _, v := range entries {
+		res.Items = append(res.Items, v.item)
+		if v.item.Error != "" {
+			res.Failed++
+		}
+	}
+
+	if imp.Atomic && res.Failed > 0 {
+		return res, nil
+	}
+
+	created := make([]*model.MediaFile, 0, len(entries))
+	for _, v := range entries {
+		if v.item.Error != "" {
+			continue
+		}
+
+		f, err := app.importMediaEntry(v)
+		if err != nil {
+			v.item.Error = err.Error()
+			res.Failed++
+			if imp.Atomic {
+				app.rollbackMediaImport(record.DomainId, created)
+				res.Created = 0
+				res.RolledBack = true
+				return res, nil
+			}
+			continue
+		}
+
+		v.item.Id = f.Id
+		created = append(created, f)
+		res.Created++
+	}
+
+	wlog.Debug(fmt.Sprintf("domain %d, imported %d media files, failed %d", record.DomainId, res.Created, res.Failed))
+
+	return res, nil
+}
+
+// mediaImportEntries checks the archive entries: size, type, name uniqueness
+func (app *App) mediaImportEntries(zr *zip.Reader, manifest *model.MediaImportManifest, imp *model.MediaImport, record model.DomainRecord) ([]*mediaImportEntry, engine.AppError) {
+	described := make(map[string]*model.MediaImportManifestFile)
+	if manifest != nil {
+		for _, v := range manifest.Files {
+			described[v.File] = v
+		}
+	}
+
+	maxSize := app.MaxUploadFileSize()
+	names := make(map[string]struct{})
+	entries := make([]*mediaImportEntry, 0, len(zr.File))
+	found := make(map[string]struct{})
+
+	for _, zf := range zr.File {
+		if zf.Name == model.MediaImportManifestName || !model.IsMediaImportEntry(zf.Name) {
+			continue
+		}
+
+		if len(entries) == model.MediaImportMaxEntries {
+			return nil, engine.NewBadRequestError("app.media.import.max_entries", fmt.Sprintf("max %d files", model.MediaImportMaxEntries))
+		}
+
+		found[zf.Name] = struct{}{}
+		desc := described[zf.Name]
+
+		file := &model.MediaFile{
+			DomainRecord: record,
+			Folder:       imp.Folder,
+			Tags:         imp.Tags,
+			Normalize:    imp.Normalize,
+		}
+		file.Properties = model.StringInterface{}
+		file.Name = path.Base(zf.Name)
+		file.MimeType = mime.TypeByExtension(path.Ext(zf.Name))
+
+		if desc != nil {
+			if desc.Name != "" {
+				file.Name = desc.Name
+			}
+			if desc.MimeType != "" {
+				file.MimeType = desc.MimeType
+			}
+			if desc.Folder != nil {
+				file.Folder = desc.Folder
+			}
+			if desc.Tags != nil {
+				file.Tags = desc.Tags
+			}
+		}
+		if i := strings.IndexByte(file.MimeType, ';'); i > 0 {
+			file.MimeType = strings.TrimSpace(file.MimeType[:i])
+		}
+		normalizeMediaLibrary(file)
+		// the stored name, so duplicates are found after the normalized files get .wav
+		app.mediaNormalizeName(file)
+
+		v := &mediaImportEntry{
+			zip:  zf,
+			file: file,
+			item: &model.MediaImportItem{
+				File: zf.Name,
+				Name: file.Name,
+			},
+		}
+		entries = append(entries, v)
+
+		switch {
+		case maxSize > 0 && zf.UncompressedSize64 > uint64(maxSize):
+			v.item.Error = fmt.Sprintf("file size %s exceeds %s", utils.BytesSize(float64(zf.UncompressedSize64)), utils.BytesSize(float64(maxSize)))
+		case !model.IsMediaMimeType(file.MimeType):
+			v.item.Error = "not supported mime type " + file.MimeType
+		default:
+			if e := file.IsValid(); e != nil {
+				v.item.Error = e.Error()
+			} else if _, ok := names[file.Name]; ok {
+				v.item.Error = "duplicate name " + file.Name
+			}
+		}
+		names[file.Name] = struct{}{}
+	}
+
+	if manifest != nil {
+		for _, v := range manifest.Files {
+			if _, ok := found[v.File]; !ok {
+				entries = append(entries, &mediaImportEntry{
+					item: &model.MediaImportItem{
+						File:  v.File,
+						Name:  v.Name,
+						Error: "file not found in the archive",
+					},
+				})
+			}
+		}
+	}
+
+	return entries, nil
+}
+
+func (app *App) importMediaEntry(v *mediaImportEntry) (*model.MediaFile, engine.AppError) {
+	r, e := v.zip.Open()
+	if e != nil {
+		return nil, engine.NewBadRequestError("app.media.import.zip", e.Error())
+	}
+	defer r.Close()
+
+	return app.SaveMediaFile(r, v.file)
+}
+
+func (app *App) rollbackMediaImport(domainId int64, files []*model.MediaFile) {
+	for _, f := range files {
+		if _, err := app.DeleteMediaFile(domainId, int(f.Id), true); err != nil {
+			app.Log.Error(fmt.Sprintf("media import rollback, file %d error: %s", f.Id, err.Error()), wlog.Err(err))
+		}
+	}
+}
+
+func readMediaImportManifest(zr *zip.Reader) (*model.MediaImportManifest, engine.AppError) {
+	for _, zf := range zr.File {
+		if zf.Name != model.MediaImportManifestName {
+			continue
+		}
+
+		r, e := zf.Open()
+		if e != nil {
+			return nil, engine.NewBadRequestError("app.media.import.manifest", e.Error())
+		}
+		defer r.Close()
+
+		var manifest model.MediaImportManifest
+		if e = json.NewDecoder(r).Decode(&manifest); e != nil {
+			return nil, engine.NewBadRequestError("app.media.import.manifest", e.Error())
+		}
+
+		return &manifest, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,99 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	engine "github.com/webitel/engine/model"
+	"github.com/webitel/storage/model"
+	"github.com/webitel/storage/utils"
+	"github.com/webitel/wlog"
+)
+
+// NewMediaProbe returns ffprobe that receives a copy of the audio or video file being written,
+// nil - if disabled or the type is not supported
+func (app *App) NewMediaProbe(ctx context.Context, mime string) *utils.MediaProbe {
+	if !app.Config().MediaMetadata || !utils.IsSupportMediaProbe(mime) {
+		return nil
+	}
+
+	probe, err := utils.NewMediaProbe(ctx)
+	if err != nil {
+		app.Log.Warn(fmt.Sprintf("media probe error: %s", err.Error()))
+		return nil
+	}
+
+	return probe
+}
+
+// ApplyMediaProbe adds the ffprobe result to the file properties, an error does not stop the upload
+func (app *App) ApplyMediaProbe(probe *utils.MediaProbe, props model.StringInterface) {
+	meta, err := probe.Close()
+	if err != nil {
+		app.Log.Warn(fmt.Sprintf("media probe error: %s", err.Error()))
+		return
+	}
+
+	for k, v := range meta.Properties() {
+		props[k] = v
+	}
+}
+
+// CreateMediaMetadataJobs queues synchronizer jobs that extract metadata of stored audio and video files
+func (app *App) CreateMediaMetadataJobs(ctx context.Context, domainId int64, job *model.MediaMetadataJob) (int64, engine.AppError) {
+	if err := job.IsValid(); err != nil {
+		return 0, err
+	}
+
+	cnt, err := app.Store.SyncFile().CreateMetadataJobs(ctx, domainId, job)
+	if err != nil {
+		return 0, err
+	}
+
+	wlog.Debug(fmt.Sprintf("domain %d, created %d media metadata jobs", domainId, cnt))
+
+	return cnt, nil
+}
+
+func (app *App) MediaMetadataJobsProgress(ctx context.Context, domainId int64) (*model.FileJobsProgress, engine.AppError) {
+	return app.Store.SyncFile().JobsProgress(ctx, domainId, model.SyncJobMetadata)
+}
+
+// ExtractFileMetadata reads the file metadata from the storage and saves it in the file properties
+func (app *App) ExtractFileMetadata(ctx context.Context, domainId, fileId int64) (*model.MediaMetadata, engine.AppError) {
+	file, backend, err := app.GetFileWithProfile(domainId, fileId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !utils.IsSupportMediaProbe(file.MimeType) {
+		return nil, engine.NewBadRequestError("app.media_metadata.extract.mime_type", "not supported mime type "+file.MimeType)
+	}
+
+	src, err := backend.Reader(file, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	probe, e := utils.NewMediaProbe(ctx)
+	if e != nil {
+		return nil, engine.NewInternalError("app.media_metadata.extract.app_error", e.Error())
+	}
+
+	_, e = io.Copy(probe, src)
+	meta, pe := probe.Close()
+	if e == nil {
+		e = pe
+	}
+	if e != nil {
+		return nil, engine.NewInternalError("app.media_metadata.extract.app_error", e.Error())
+	}
+
+	if err = app.Store.File().MergeProperties(ctx, file.Id, meta.Properties()); err != nil {
+		return nil, err
+	}
+
+	return meta, nil
+}
@@ -0,0 +1,241 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	engine "github.com/webitel/engine/model"
+	"github.com/webitel/storage/model"
+	"github.com/webitel/storage/transcoding"
+	"github.com/webitel/storage/utils"
+	"github.com/webitel/wlog"
+)
+
+// mediaNormalize audio processing on upload: the request parameter, or the domain media_normalize setting
+func (app *App) mediaNormalize(mediaFile *model.MediaFile) bool {
+	if !strings.HasPrefix(mediaFile.MimeType, "audio/") {
+		return false
+	}
+
+	if mediaFile.Normalize != nil {
+		return *mediaFile.Normalize
+	}
+
+	v, _ := app.GetCachedSystemSetting(context.Background(), mediaFile.DomainId, model.SysNameMediaNormalize)
+	if b := v.Bool(); b != nil {
+		return *b
+	}
+
+	return false
+}
+
+// mediaNormalizeName normalized audio is stored as WAV and players pick the format by the extension, so a new file
+// gets the .wav extension. The name of an existing file does not change: its new version is normalized only if
+// the name already ends in .wav, an explicit normalize of another name is rejected
+func (app *App) mediaNormalizeName(mediaFile *model.MediaFile) (bool, engine.AppError) {
+	if !app.mediaNormalize(mediaFile) {
+		return false, nil
+	}
+
+	ext := filepath.Ext(mediaFile.Name)
+	if strings.EqualFold(ext, ".wav") {
+		return true, nil
+	}
+
+	if mediaFile.Id == 0 {
+		mediaFile.Name = strings.TrimSuffix(mediaFile.Name, ext) + ".wav"
+		return true, nil
+	}
+
+	if mediaFile.Normalize != nil {
+		return false, engine.NewBadRequestError("app.media.normalize.name", "normalized file is WAV, name must end in .wav, name="+mediaFile.Name)
+	}
+
+	return false, nil
+}
+
+// mediaNormalizeSettings loudness target (LUFS) and sample rate of the domain
+func (app *App) mediaNormalizeSettings(domainId int64) (loudness int, sampleRate int) {
+	loudness = transcoding.DefaultLoudnessTarget
+	sampleRate = transcoding.TelephonySampleRates[0]
+
+	ctx := context.Background()
+	if v, _ := app.GetCachedSystemSetting(ctx, domainId, model.SysNameMediaLoudnessTarget); v.Int() != nil {
+		loudness = *v.Int()
+	}
+
+	if v, _ := app.GetCachedSystemSetting(ctx, domainId, model.SysNameMediaSampleRate); v.Int() != nil &&
+		slices.Contains(transcoding.TelephonySampleRates, *v.Int()) {
+		sampleRate = *v.Int()
+	}
+
+	return
+}
+
+// writeMediaFile writes the file unchanged, for audio the telephony format compatibility is saved
+func (app *App) writeMediaFile(backend utils.FileBackend, src io.Reader, mediaFile *model.MediaFile) (int64, engine.AppError) {
+	probe := app.NewMediaProbe(context.Background(), mediaFile.MimeType)
+	if probe != nil {
+		src = io.TeeReader(src, probe)
+	}
+
+	size, err := backend.Write(src, mediaFile)
+	if probe == nil {
+		return size, err
+	}
+
+	meta, e := probe.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	if e == nil {
+		mediaFile.Properties[model.MediaFilePropertyConformance] = model.NewMediaConformance(meta).Properties()
+		setMediaDuration(mediaFile, meta)
+	} else {
+		wlog.Debug(fmt.Sprintf("media file \"%s\" probe error: %s", mediaFile.Name, e.Error()))
+	}
+
+	return size, nil
+}
+
+// writeNormalizedMediaFile stores the original alongside and writes a normalized mono WAV under the file name
+func (app *App) writeNormalizedMediaFile(backend utils.FileBackend, src io.Reader, mediaFile *model.MediaFile) (int64, engine.AppError) {
+	loudness, sampleRate := app.mediaNormalizeSettings(mediaFile.DomainId)
+
+	original := *mediaFile
+	original.Name = model.NewId() + "_original_" + mediaFile.Name
+	original.Properties = model.StringInterface{}
+
+	probe := app.NewMediaProbe(context.Background(), mediaFile.MimeType)
+	if probe != nil {
+		src = io.TeeReader(src, probe)
+	}
+
+	size, err := backend.Write(src, &original)
+	if probe != nil {
+		// normalization does not change the duration
+		if meta, e := probe.Close(); e == nil {
+			setMediaDuration(mediaFile, meta)
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	original.Size = size
+
+	dir, e := os.MkdirTemp("", "media_")
+	if e != nil {
+		backend.Remove(&original)
+		return 0, engine.NewInternalError("app.media.normalize.app_error", e.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "out.wav")
+	if err = app.normalizeMediaFile(backend, &original, loudness, sampleRate, out); err != nil {
+		backend.Remove(&original)
+		return 0, err
+	}
+
+	f, e := os.Open(out)
+	if e != nil {
+		backend.Remove(&original)
+		return 0, engine.NewInternalError("app.media.normalize.app_error", e.Error())
+	}
+	defer f.Close()
+
+	mediaFile.MimeType = "audio/wav"
+	if size, err = backend.Write(f, mediaFile); err != nil {
+		backend.Remove(&original)
+		return 0, err
+	}
+
+	mediaFile.Properties[model.MediaFilePropertyOriginal] = map[string]interface{}{
+		"name":       original.Name,
+		"mime_type":  original.MimeType,
+		"size":       original.Size,
+		"properties": original.Properties,
+	}
+	mediaFile.Properties[model.MediaFilePropertyConformance] = (&model.MediaConformance{
+		Conformant:     true,
+		Codec:          "pcm_s16le",
+		SampleRate:     sampleRate,
+		Channels:       1,
+		LoudnessTarget: &loudness,
+		NormalizedAt:   model.GetMillis(),
+	}).Properties()
+
+	return size, nil
+}
+
+// setMediaDuration duration for the media file search
+func setMediaDuration(mediaFile *model.MediaFile, meta *model.MediaMetadata) {
+	if meta.Duration > 0 {
+		mediaFile.Properties[model.FilePropertyDuration] = meta.Duration
+	}
+}
+
+func (app *App) normalizeMediaFile(backend utils.FileBackend, original *model.MediaFile, loudness, sampleRate int, out string) engine.AppError {
+	args, e := transcoding.NormalizeArgs(loudness, sampleRate, out)
+	if e != nil {
+		return engine.NewBadRequestError("app.media.normalize.valid", e.Error())
+	}
+
+	r, err := backend.Reader(original, 0)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if e = transcoding.Run(r, args); e != nil {
+		return engine.NewBadRequestError("app.media.normalize.app_error", e.Error())
+	}
+
+	return nil
+}
+
+// mediaOriginal original of the normalized file; its storage location is kept in the original properties,
+// files without them use the location of the file itself
+func mediaOriginal(mediaFile *model.MediaFile) *model.MediaFile {
+	v, ok := mediaFile.Properties[model.MediaFilePropertyOriginal].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	name, _ := v["name"].(string)
+	if name == "" {
+		return nil
+	}
+
+	original := *mediaFile
+	original.Name = name
+	original.Properties = model.StringInterface{}
+	if props, ok := v["properties"].(map[string]interface{}); ok {
+		for k, p := range props {
+			original.Properties[k] = p
+		}
+	} else {
+		for k, p := range mediaFile.Properties {
+			original.Properties[k] = p
+		}
+	}
+
+	return &original
+}
+
+// removeMediaOriginal removes the original of the normalized file
+func (app *App) removeMediaOriginal(backend utils.FileBackend, mediaFile *model.MediaFile) {
+	original := mediaOriginal(mediaFile)
+	if original == nil {
+		return
+	}
+
+	if err := backend.Remove(original); err != nil {
+		app.Log.Error(fmt.Sprintf("media file \"%s\", remove original error: %s", mediaFile.Name, err.Error()), wlog.Err(err))
+	}
+}
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	engine "github.com/webitel/engine/model"
+	"github.com/webitel/storage/model"
+	"github.com/webitel/wlog"
+)
+
+const mediaPlaybackFlushInterval = 10 * time.Second
+
+// mediaPlaybacks media file playback counter; written to the database in batches so as not to slow down playback
+type mediaPlaybacks struct {
+	sync.Mutex
+	files map[int64]*model.MediaPlayback
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+func (app *App) startMediaPlaybacks() {
+	app.mediaPlaybacks = &mediaPlaybacks{
+		files: make(map[int64]*model.MediaPlayback),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+
+	go func(p *mediaPlaybacks) {
+		defer close(p.done)
+		ticker := time.NewTicker(mediaPlaybackFlushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				app.flushMediaPlaybacks()
+			case <-p.stop:
+				app.flushMediaPlaybacks()
+				return
+			}
+		}
+	}(app.mediaPlaybacks)
+}
+
+func (app *App) stopMediaPlaybacks() {
+	if app.mediaPlaybacks == nil {
+		return
+	}
+
+	close(app.mediaPlaybacks.stop)
+	<-app.mediaPlaybacks.done
+}
+
+// RegisterMediaPlayback counts a playback of the media file
+func (app *App) RegisterMediaPlayback(id int64) {
+	p := app.mediaPlaybacks
+	if p == nil {
+		return
+	}
+
+	p.Lock()
+	v, ok := p.files[id]
+	if !ok {
+		v = &model.MediaPlayback{Id: id}
+		p.files[id] = v
+	}
+	v.Count++
+	v.LastPlayedAt = model.GetMillis()
+	p.Unlock()
+}
+
+func (app *App) flushMediaPlaybacks() {
+	p := app.mediaPlaybacks
+	p.Lock()
+	if len(p.files) == 0 {
+		p.Unlock()
+		return
+	}
+
+	playbacks := make([]*model.MediaPlayback, 0, len(p.files))
+	for _, v := range p.files {
+		playbacks = append(playbacks, v)
+	}
+	p.files = make(map[int64]*model.MediaPlayback)
+	p.Unlock()
+
+	if err := app.Store.MediaFile().AddPlaybacks(context.Background(), playbacks); err != nil {
+		app.Log.Error(fmt.Sprintf("save %d media playbacks error: %s", len(playbacks), err.Error()), wlog.Err(err))
+	}
+}
+
+// GetMediaFileUsage playback count and references of other services to the media file
+func (app *App) GetMediaFileUsage(ctx context.Context, domainId int64, id int) (*model.MediaFileUsage, engine.AppError) {
+	file, err := app.Store.MediaFile().Get(domainId, id)
+	if err != nil {
+		return nil, err
+	}
+
+	refs, err := app.Store.MediaFile().GetReferences(ctx, domainId, file.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.MediaFileUsage{
+		PlayCount:    file.PlayCount,
+		LastPlayedAt: file.LastPlayedAt,
+		References:   refs,
+	}, nil
+}
+
+// AddMediaFileReference registers a usage of the media file by an object of another service (routing scheme, queue)
+func (app *App) AddMediaFileReference(ctx context.Context, domainId int64, ref *model.MediaFileReference) engine.AppError {
+	if err := ref.IsValid(); err != nil {
+		return err
+	}
+	ref.CreatedAt = model.GetMillis()
+
+	return app.Store.MediaFile().AddReference(ctx, domainId, ref)
+}
+
+func (app *App) RemoveMediaFileReference(ctx context.Context, domainId int64, ref *model.MediaFileReference) engine.AppError {
+	if err := ref.IsValid(); err != nil {
+		return err
+	}
+
+	return app.Store.MediaFile().RemoveReference(ctx, domainId, ref)
+}
+
+func mediaFileReferencedError(file *model.MediaFile) engine.AppError {
+	return engine.NewCustomCodeError("app.media.delete.referenced",
+		fmt.Sprintf("media file %d is used by %d objects, use force to delete", file.Id, file.ReferenceCount), http.StatusConflict)
+}
@@ -0,0 +1,177 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	engine "github.com/webitel/engine/model"
+	"github.com/webitel/storage/model"
+	"github.com/webitel/wlog"
+)
+
+// UploadMediaFileVersion writes a new content version of the media file and makes it active; the file id and name
+// do not change, previous versions stay in the storage to revert to
+func (app *App) UploadMediaFileVersion(ctx context.Context, domainId int64, id int, src io.ReadCloser, upload *model.MediaFile) (*model.MediaFile, engine.AppError) {
+	done, err := app.BeginWork()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	file, err := app.Store.MediaFile().Get(domainId, id)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := app.mediaFileVersions(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+
+	next := *file
+	next.Version = versions[0].Version + 1
+	next.MimeType = upload.MimeType
+	next.Normalize = upload.Normalize
+	next.Properties = model.StringInterface{}
+	next.Channel = model.NewString(model.UploadFileChannelMedia)
+	next.UpdatedAt = model.GetMillis()
+	next.UpdatedBy = upload.UpdatedBy
+
+	if err = next.IsValid(); err != nil {
+		return nil, err
+	}
+
+	src, err = app.FilePolicyForUpload(ctx, domainId, &next.BaseFile, src)
+	if err != nil {
+		return nil, err
+	}
+
+	backend, err := app.writeMediaContent(src, &next)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = app.Store.MediaFile().CreateVersion(ctx, model.NewMediaFileVersion(&next)); err != nil {
+		backend.Remove(&next)
+		app.removeMediaOriginal(backend, &next)
+		return nil, err
+	}
+
+	if err = app.Store.MediaFile().SetActiveVersion(ctx, domainId, file.Id, next.Version, next.UpdatedAt, next.UpdatedBy); err != nil {
+		return nil, err
+	}
+	app.purgeMediaCache(file)
+
+	wlog.Debug(fmt.Sprintf("media file %d, uploaded version %d", file.Id, next.Version))
+
+	return app.GetMediaFile(domainId, id)
+}
+
+// GetMediaFileVersions version history of the media file, newest first
+func (app *App) GetMediaFileVersions(ctx context.Context, domainId int64, id int) ([]*model.MediaFileVersion, engine.AppError) {
+	file, err := app.Store.MediaFile().Get(domainId, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return app.mediaFileVersions(ctx, file)
+}
+
+// ActivateMediaFileVersion reverts the media file to a stored version
+func (app *App) ActivateMediaFileVersion(ctx context.Context, domainId int64, id int, version int, updatedBy *model.Lookup) (*model.MediaFile, engine.AppError) {
+	file, err := app.Store.MediaFile().Get(domainId, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if file.GetContentVersion() == version {
+		return app.GetMediaFile(domainId, id)
+	}
+
+	if _, err = app.mediaFileVersions(ctx, file); err != nil {
+		return nil, err
+	}
+
+	if err = app.Store.MediaFile().SetActiveVersion(ctx, domainId, file.Id, version, model.GetMillis(), updatedBy); err != nil {
+		return nil, err
+	}
+	app.purgeMediaCache(file)
+
+	wlog.Debug(fmt.Sprintf("media file %d, activated version %d", file.Id, version))
+
+	return app.GetMediaFile(domainId, id)
+}
+
+// GetMediaFileVersion media file with the content of the given version
+func (app *App) GetMediaFileVersion(ctx context.Context, domainId int64, id int, version int) (*model.MediaFile, engine.AppError) {
+	file, err := app.GetMediaFile(domainId, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if file.GetContentVersion() == version {
+		return file, nil
+	}
+
+	v, err := app.Store.MediaFile().GetVersion(ctx, domainId, file.Id, version)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.MediaFile(file), nil
+}
+
+// mediaFileVersions for files uploaded before versions existed, stores the current content as the first version
+func (app *App) mediaFileVersions(ctx context.Context, file *model.MediaFile) ([]*model.MediaFileVersion, engine.AppError) {
+	versions, err := app.Store.MediaFile().GetVersions(ctx, file.DomainId, file.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(versions) != 0 {
+		return versions, nil
+	}
+
+	v, err := app.Store.MediaFile().CreateVersion(ctx, model.NewMediaFileVersion(file))
+	if err != nil {
+		return nil, err
+	}
+	v.Active = true
+
+	return []*model.MediaFileVersion{v}, nil
+}
+
+// removeMediaContent removes the content of all versions of the media file
+func (app *App) removeMediaContent(file *model.MediaFile) engine.AppError {
+	versions, err := app.Store.MediaFile().GetVersions(context.Background(), file.DomainId, file.Id)
+	if err != nil {
+		return err
+	}
+
+	files := []*model.MediaFile{file}
+	for _, v := range versions {
+		if v.Version != file.GetContentVersion() {
+			files = append(files, v.MediaFile(file))
+		}
+	}
+
+	for i, f := range files {
+		backend, err := app.MediaFileBackend(f)
+		if err == nil {
+			err = backend.Remove(f)
+		}
+
+		if err != nil {
+			// the content of the active version always
+			if i == 0 {
+				return err
+			}
+			app.Log.Error(fmt.Sprintf("media file %d, remove version %d error: %s", file.Id, f.Version, err.Error()), wlog.Err(err))
+			continue
+		}
+		app.removeMediaOriginal(backend, f)
+	}
+
+	return nil
+}
@@ -0,0 +1,262 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	engine "github.com/webitel/engine/model"
+	"github.com/webitel/storage/model"
+	"github.com/webitel/storage/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	meterName = "github.com/webitel/storage"
+	// metricsQueueTimeout time for the queue size query when collecting metrics
+	metricsQueueTimeout = 5 * time.Second
+)
+
+var (
+	attrChannel   = attribute.Key("channel")
+	attrBackend   = attribute.Key("backend")
+	attrProfile   = attribute.Key("profile")
+	attrOperation = attribute.Key("operation")
+	attrAction    = attribute.Key("action")
+	attrState     = attribute.Key("state")
+	attrReason    = attribute.Key("reason")
+	attrProvider  = attribute.Key("provider")
+	attrCache     = attribute.Key("cache")
+
+	// policyRejectReasons reason of the file policy rejection for the metric
+	policyRejectReasons = map[engine.AppError]string{
+		model.PolicyErrorMaxLimit:      "max_size",
+		model.PolicyErrorExtUnknown:    "ext_unknown",
+		model.PolicyErrorExtSuspicious: "ext_suspicious",
+		model.PolicyErrorExtNotAllowed: "ext_not_allowed",
+		model.PolicyErrorForbidden:     "forbidden",
+		model.PolicyErrorChannel:       "channel",
+		model.PolicyErrorExtDenied:     "ext_denied",
+		model.PolicyErrorDangerous:     "dangerous",
+	}
+)
+
+// metrics OTel instruments of the service; recorded if export is configured (OTEL_METRICS_EXPORTER or metrics_prometheus)
+type metrics struct {
+	uploadBytes      metric.Int64Counter
+	uploadDuration   metric.Float64Histogram
+	downloadBytes    metric.Int64Counter
+	downloadDuration metric.Float64Histogram
+	backendErrors    metric.Int64Counter
+	uploadAttempts   metric.Int64Histogram
+	fileJobs         metric.Int64Counter
+	fileJobDuration  metric.Float64Histogram
+	policyRejections metric.Int64Counter
+	ttsDuration      metric.Float64Histogram
+	ttsErrors        metric.Int64Counter
+	sttDuration      metric.Float64Histogram
+	sttErrors        metric.Int64Counter
+}
+
+func newMetrics(app *App) (*metrics, error) {
+	var err error
+	meter := otel.Meter(meterName)
+	m := &metrics{}
+
+	if m.uploadBytes, err = meter.Int64Counter("storage.upload.bytes",
+		metric.WithUnit("By"), metric.WithDescription("Bytes written to file backends")); err != nil {
+		return nil, err
+	}
+	if m.uploadDuration, err = meter.Float64Histogram("storage.upload.duration",
+		metric.WithUnit("s"), metric.WithDescription("Duration of file writes to file backends")); err != nil {
+		return nil, err
+	}
+	if m.downloadBytes, err = meter.Int64Counter("storage.download.bytes",
+		metric.WithUnit("By"), metric.WithDescription("Bytes read from file backends")); err != nil {
+		return nil, err
+	}
+	if m.downloadDuration, err = meter.Float64Histogram("storage.download.duration",
+		metric.WithUnit("s"), metric.WithDescription("Duration of file reads from file backends, until the reader is closed")); err != nil {
+		return nil, err
+	}
+	if m.backendErrors, err = meter.Int64Counter("storage.backend.errors",
+		metric.WithDescription("Errors of file backend operations")); err != nil {
+		return nil, err
+	}
+	if m.uploadAttempts, err = meter.Int64Histogram("storage.upload_job.attempts",
+		metric.WithDescription("Attempt number of upload jobs taken by the uploader")); err != nil {
+		return nil, err
+	}
+	if m.fileJobs, err = meter.Int64Counter("storage.file_job.processed",
+		metric.WithDescription("Synchronizer jobs processed")); err != nil {
+		return nil, err
+	}
+	if m.fileJobDuration, err = meter.Float64Histogram("storage.file_job.duration",
+		metric.WithUnit("s"), metric.WithDescription("Duration of synchronizer jobs")); err != nil {
+		return nil, err
+	}
+	if m.policyRejections, err = meter.Int64Counter("storage.policy.rejections",
+		metric.WithDescription("Files rejected by file policies")); err != nil {
+		return nil, err
+	}
+	if m.ttsDuration, err = meter.Float64Histogram("storage.tts.duration",
+		metric.WithUnit("s"), metric.WithDescription("Duration of TTS provider requests")); err != nil {
+		return nil, err
+	}
+	if m.ttsErrors, err = meter.Int64Counter("storage.tts.errors",
+		metric.WithDescription("Failed TTS provider requests")); err != nil {
+		return nil, err
+	}
+	if m.sttDuration, err = meter.Float64Histogram("storage.stt.duration",
+		metric.WithUnit("s"), metric.WithDescription("Duration of STT transcriptions")); err != nil {
+		return nil, err
+	}
+	if m.sttErrors, err = meter.Int64Counter("storage.stt.errors",
+		metric.WithDescription("Failed STT transcriptions")); err != nil {
+		return nil, err
+	}
+
+	if err = app.observeQueues(meter); err != nil {
+		return nil, err
+	}
+
+	if err = app.observeCaches(meter); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// observeQueues size of the upload and file job queues, the database is queried when metrics are collected
+func (app *App) observeQueues(meter metric.Meter) error {
+	uploads, err := meter.Int64ObservableGauge("storage.upload_job.queue",
+		metric.WithDescription("Upload jobs of all instances by state"))
+	if err != nil {
+		return err
+	}
+
+	fileJobs, err := meter.Int64ObservableGauge("storage.file_job.queue",
+		metric.WithDescription("Synchronizer jobs by action and state"))
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		if app.Store == nil {
+			return nil
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, metricsQueueTimeout)
+		defer cancel()
+
+		if q, err := app.Store.UploadJob().Queue(ctx); err == nil {
+			o.ObserveInt64(uploads, q.Pending, metric.WithAttributes(attrState.String("pending")))
+			o.ObserveInt64(uploads, q.Active, metric.WithAttributes(attrState.String("active")))
+			o.ObserveInt64(uploads, q.Retrying, metric.WithAttributes(attrState.String("retrying")))
+		}
+
+		if queue, err := app.Store.SyncFile().Queue(ctx, 0); err == nil {
+			for _, q := range queue {
+				o.ObserveInt64(fileJobs, q.Pending, metric.WithAttributes(attrAction.String(q.Action), attrState.String("pending")))
+				o.ObserveInt64(fileJobs, q.Active, metric.WithAttributes(attrAction.String(q.Action), attrState.String("active")))
+				o.ObserveInt64(fileJobs, q.Failed, metric.WithAttributes(attrAction.String(q.Action), attrState.String("failed")))
+			}
+		}
+
+		return nil
+	}, uploads, fileJobs)
+
+	return err
+}
+
+// observeCaches hits and misses of the LRU caches
+func (app *App) observeCaches(meter metric.Meter) error {
+	caches := map[string]*utils.Cache{
+		"file_backend":    app.fileBackendCache,
+		"media_backend":   app.mediaBackendCache,
+		"stt_profile":     app.sttProfilesCache,
+		"system_settings": systemCache,
+		"safe_upload":     safeUploadProcess,
+	}
+	if policies, ok := app.filePolicies.policies.(*utils.Cache); ok {
+		caches["file_policy"] = policies
+	}
+
+	hits, err := meter.Int64ObservableCounter("storage.cache.hits",
+		metric.WithDescription("LRU cache lookups that found the key"))
+	if err != nil {
+		return err
+	}
+
+	misses, err := meter.Int64ObservableCounter("storage.cache.misses",
+		metric.WithDescription("LRU cache lookups that did not find the key"))
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for name, c := range caches {
+			h, m := c.Stats()
+			o.ObserveInt64(hits, h, metric.WithAttributes(attrCache.String(name)))
+			o.ObserveInt64(misses, m, metric.WithAttributes(attrCache.String(name)))
+		}
+
+		return nil
+	}, hits, misses)
+
+	return err
+}
+
+// ObserveUploadJob attempt number of the upload job taken for processing
+func (app *App) ObserveUploadJob(job *model.JobUploadFileWithProfile) {
+	app.metrics.uploadAttempts.Record(context.Background(), int64(job.Attempts))
+}
+
+// ObserveFileJob completed synchronizer job
+func (app *App) ObserveFileJob(action string, d time.Duration) {
+	attrs := metric.WithAttributes(attrAction.String(action))
+	app.metrics.fileJobs.Add(context.Background(), 1, attrs)
+	app.metrics.fileJobDuration.Record(context.Background(), d.Seconds(), attrs)
+}
+
+func (m *metrics) policyRejected(file *model.BaseFile, err error) {
+	appErr, ok := err.(engine.AppError)
+	if !ok || !model.IsFilePolicyError(appErr) {
+		return
+	}
+
+	reason, ok := policyRejectReasons[appErr]
+	if !ok {
+		reason = "unknown"
+	}
+
+	m.policyRejections.Add(context.Background(), 1, metric.WithAttributes(
+		attrReason.String(reason),
+		attrChannel.String(fileChannel(file.Channel)),
+	))
+}
+
+func (m *metrics) tts(provider string, start time.Time, err engine.AppError) {
+	attrs := metric.WithAttributes(attrProvider.String(provider))
+	m.ttsDuration.Record(context.Background(), time.Since(start).Seconds(), attrs)
+	if err != nil {
+		m.ttsErrors.Add(context.Background(), 1, attrs)
+	}
+}
+
+func (m *metrics) stt(provider string, start time.Time, err error) {
+	attrs := metric.WithAttributes(attrProvider.String(provider))
+	m.sttDuration.Record(context.Background(), time.Since(start).Seconds(), attrs)
+	if err != nil {
+		m.sttErrors.Add(context.Background(), 1, attrs)
+	}
+}
+
+func fileChannel(channel *string) string {
+	if channel == nil || *channel == "" {
+		return model.UploadFileChannelUnknown
+	}
+
+	return *channel
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/webitel/storage/model"
 	"github.com/webitel/storage/utils"
 	"github.com/webitel/wlog"
+	"go.opentelemetry.io/otel/trace"
 	"io"
 	"strconv"
 	"sync"
@@ -26,6 +27,7 @@ const (
 
 type SafeUpload struct {
 	id              string
+	ctx             context.Context
 	state           SafeUploadState
 	app             *App
 	reader          io.Reader
@@ -37,6 +39,7 @@ type SafeUpload struct {
 	err             chan error // todo
 	uploaded        chan struct{}
 	size            int
+	done            func()
 	mx              sync.RWMutex
 	Progress        bool
 }
@@ -93,11 +96,12 @@ func (s *SafeUpload) Write(src []byte) error {
 
 func (s *SafeUpload) run() {
 	wlog.Debug(fmt.Sprintf("start safe upload id=%s, name=%s", s.id, s.request.Name))
+	defer s.done()
 	var err engine.AppError
 	if s.profileId != nil {
-		err = s.app.SyncUploadToProfile(s.reader, *s.profileId, s.request)
+		err = s.app.uploadToProfile(s.ctx, s.reader, *s.profileId, s.request)
 	} else {
-		err = s.app.SyncUpload(s.reader, s.request)
+		err = s.app.uploadDefault(s.ctx, s.reader, s.request)
 	}
 
 	s.setState(SafeUploadStateFinished)
@@ -177,18 +181,24 @@ func getSafeUploadProcess(id string) (*SafeUpload, bool) {
 	return s.(*SafeUpload), true
 }
 
-func (app *App) NewSafeUpload(profileId *int, req *model.JobUploadFile) (*SafeUpload, error) {
-	return newSafeUpload(app, profileId, req)
+func (app *App) NewSafeUpload(ctx context.Context, profileId *int, req *model.JobUploadFile) (*SafeUpload, error) {
+	return newSafeUpload(ctx, app, profileId, req)
 }
 
-func newSafeUpload(app *App, profileId *int, req *model.JobUploadFile) (*SafeUpload, error) {
-	var err engine.AppError
+// newSafeUpload the upload outlives the request (waits to be resumed), so only the trace is taken from ctx
+func newSafeUpload(ctx context.Context, app *App, profileId *int, req *model.JobUploadFile) (*SafeUpload, error) {
+	done, err := app.BeginWork()
+	if err != nil {
+		return nil, err
+	}
+
 	r, w := io.Pipe()
 	if profileId != nil {
 		req.Name = fmt.Sprintf("%s_%s", model.NewId()[0:7], req.Name)
 	}
 
 	s := &SafeUpload{
+		ctx:       trace.ContextWithSpanContext(context.Background(), trace.SpanContextFromContext(ctx)),
 		app:       app,
 		state:     SafeUploadStateActive,
 		profileId: profileId,
@@ -197,9 +207,11 @@ func newSafeUpload(app *App, profileId *int, req *model.JobUploadFile) (*SafeUpl
 		uploaded:  make(chan struct{}),
 		request:   req,
 		writer:    w,
+		done:      done,
 	}
-	s.reader, err = app.FilePolicyForUpload(req.DomainId, &req.BaseFile, r)
+	s.reader, err = app.FilePolicyForUpload(s.ctx, req.DomainId, &req.BaseFile, r)
 	if err != nil {
+		done()
 		return nil, err
 	}
 
@@ -208,6 +220,20 @@ func newSafeUpload(app *App, profileId *int, req *model.JobUploadFile) (*SafeUpl
 	return s, nil
 }
 
+// stopSleepingSafeUploads the client cannot resume the upload once the server stops, so MaxSafeUploadSleep is not awaited
+func stopSleepingSafeUploads() {
+	for _, k := range safeUploadProcess.Keys() {
+		su, ok := getSafeUploadProcess(k.(string))
+		if !ok || su.State() != SafeUploadStateSleep {
+			continue
+		}
+
+		su.cancelSleep()
+		su.SetError(errors.New("server is shutting down"))
+		wlog.Debug(fmt.Sprintf("stop sleeping upload id=%s, name=%s, size=%d", su.id, su.request.Name, su.Size()))
+	}
+}
+
 func schedule(what func(), delay time.Duration) chan struct{} {
 	stop := make(chan struct{})
 
@@ -16,6 +16,8 @@ import (
 
 	"github.com/webitel/storage/model"
 	"github.com/webitel/wlog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -125,18 +127,28 @@ func (app *App) TranscriptFile(fileId int64, options model.TranscriptOptions) (*
 	//app.jobCallback.Add(fileId, cn)
 	//defer app.jobCallback.Remove(fileId)
 
-	if transcript, e := stt.Transcript(ctx, fileId, app.publicUri(fileUri), p.GetLocale(options.Locale)); e != nil {
+	ctx, span := tracer.Start(ctx, "App.TranscriptFile", trace.WithAttributes(
+		attrProvider.String(p.Provider),
+		attribute.Int64("file.id", fileId),
+	))
+	start := time.Now()
+	transcript, e := stt.Transcript(ctx, fileId, app.publicUri(fileUri), p.GetLocale(options.Locale))
+	app.metrics.stt(p.Provider, start, e)
+	if e != nil {
+		spanError(span, e)
+		span.End()
 		return nil, engine.NewInternalError("app.stt.transcript.err", e.Error())
-	} else {
-		transcript.File = model.Lookup{
-			Id: int(fileId),
-		}
-		transcript.Profile = &model.Lookup{
-			Id: int(p.Id),
-		}
+	}
+	span.End()
 
-		return app.Store.TranscriptFile().Store(&transcript)
+	transcript.File = model.Lookup{
+		Id: int(fileId),
 	}
+	transcript.Profile = &model.Lookup{
+		Id: int(p.Id),
+	}
+
+	return app.Store.TranscriptFile().Store(&transcript)
 }
 
 func (app *App) CreateTranscriptFilesJob(domainId int64, options *model.TranscriptOptions) ([]*model.FileTranscriptJob, engine.AppError) {
@@ -0,0 +1,115 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	engine "github.com/webitel/engine/model"
+	"github.com/webitel/storage/model"
+	"github.com/webitel/storage/utils"
+	"github.com/webitel/wlog"
+)
+
+// CreateThumbnailJobs queues synchronizer jobs that create thumbnails for stored files matching the filter,
+// returns the number of created jobs
+func (app *App) CreateThumbnailJobs(ctx context.Context, domainId int64, job *model.ThumbnailJob) (int64, engine.AppError) {
+	if err := job.IsValid(); err != nil {
+		return 0, err
+	}
+
+	if job.Scale == "" {
+		job.Scale = app.thumbnailSettings.DefaultScale
+	}
+
+	cnt, err := app.Store.SyncFile().CreateThumbnailJobs(ctx, domainId, job)
+	if err != nil {
+		return 0, err
+	}
+
+	wlog.Debug(fmt.Sprintf("domain %d, created %d thumbnail jobs", domainId, cnt))
+
+	return cnt, nil
+}
+
+// ThumbnailJobsProgress returns the state of the domain thumbnail queue
+func (app *App) ThumbnailJobsProgress(ctx context.Context, domainId int64) (*model.FileJobsProgress, engine.AppError) {
+	return app.Store.SyncFile().JobsProgress(ctx, domainId, model.SyncJobThumbnail)
+}
+
+// GenerateFileThumbnail creates a thumbnail from the file in the storage and saves it in files.thumbnail
+func (app *App) GenerateFileThumbnail(ctx context.Context, domainId, fileId int64, scale string) (*model.Thumbnail, engine.AppError) {
+	file, backend, err := app.GetFileWithProfile(domainId, fileId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !utils.IsSupportThumbnail(file.MimeType) {
+		return nil, engine.NewBadRequestError("app.thumbnail.generate.mime_type", "not supported mime type "+file.MimeType)
+	}
+
+	if scale == "" {
+		scale = app.thumbnailSettings.DefaultScale
+	}
+	if scale == "" {
+		scale = utils.ThumbnailScale
+	}
+
+	src, err := app.FileReader(ctx, backend, file, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	out, e := utils.NewThumbnailReader(src, file.MimeType, scale)
+	if e != nil {
+		return nil, engine.NewInternalError("app.thumbnail.generate.app_error", e.Error())
+	}
+
+	// the store name is unique, so a regenerated thumbnail never collides with the previous object
+	viewName := "thumbnail_" + file.Name + ".png"
+	name := "thumbnail_" + model.NewId() + "_" + file.Name + ".png"
+	f, err := app.syncUpload(ctx, backend, out, &model.JobUploadFile{
+		BaseFile: model.BaseFile{
+			Name:     name,
+			ViewName: &viewName,
+			MimeType: "image/png",
+			Channel:  file.Channel,
+		},
+		DomainId: file.DomainId,
+		Uuid:     file.Uuid,
+	}, nil)
+
+	if e = out.Close(); e != nil && err == nil {
+		err = engine.NewInternalError("app.thumbnail.generate.app_error", e.Error())
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	thumbnail := &model.Thumbnail{BaseFile: f.BaseFile, Scale: "scale=" + scale}
+	if err = app.Store.File().SetThumbnail(ctx, file.Id, thumbnail); err != nil {
+		app.removeThumbnail(backend, file, &f.BaseFile)
+		return nil, err
+	}
+
+	if file.Thumbnail != nil {
+		app.removeThumbnail(backend, file, &file.Thumbnail.BaseFile)
+	}
+
+	return thumbnail, nil
+}
+
+// removeThumbnail removes the thumbnail object of the file from the backend; a failure only leaves an orphan object
+func (app *App) removeThumbnail(backend utils.FileBackend, file *model.File, thumbnail *model.BaseFile) {
+	err := backend.Remove(&model.File{
+		BaseFile:  *thumbnail,
+		DomainId:  file.DomainId,
+		Uuid:      file.Uuid,
+		ProfileId: file.ProfileId,
+	})
+	if err != nil && err.GetStatusCode() != http.StatusNotFound {
+		app.Log.Error(fmt.Sprintf("file %d, remove thumbnail %s error: %s", file.Id, thumbnail.Name, err.Error()), wlog.Err(err))
+	}
+}
@@ -1,7 +1,183 @@
 package app
 
-type Transcoding interface {
-	Decode(string)
-	Close()
-	Channels() [][]float32
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	engine "github.com/webitel/engine/model"
+	"github.com/webitel/storage/model"
+	"github.com/webitel/storage/transcoding"
+	"github.com/webitel/storage/utils"
+	"github.com/webitel/wlog"
+)
+
+const (
+	// allowed duration difference between the original and the result, seconds
+	transcodeDurationDelta = 1.0
+)
+
+// SetTranscodeFileJobs creates transcode jobs for files matching policies with transcode_codec
+func (app *App) SetTranscodeFileJobs() engine.AppError {
+	return app.Store.SyncFile().SetTranscodeJobs(100)
+}
+
+// TranscodeFile transcodes an audio file in the storage. The original is removed only after the result is verified
+// and the file record (name, mime_type, size, sha256sum) is updated in one query
+func (app *App) TranscodeFile(ctx context.Context, domainId, fileId int64, opts transcoding.AudioOptions) engine.AppError {
+	if e := opts.IsValid(); e != nil {
+		return engine.NewBadRequestError("app.transcode.valid.options", e.Error())
+	}
+
+	file, backend, err := app.GetFileWithProfile(domainId, fileId)
+	if err != nil {
+		return err
+	}
+
+	if !strings.HasPrefix(file.MimeType, "audio/") {
+		return engine.NewBadRequestError("app.transcode.mime_type", "not supported mime type "+file.MimeType)
+	}
+
+	if file.MimeType == opts.MimeType() {
+		return nil
+	}
+
+	dst, err := app.writeTranscoded(file, backend, opts)
+	if err != nil {
+		return err
+	}
+
+	if err = app.verifyTranscoded(file, backend, dst); err != nil {
+		backend.Remove(dst)
+		return err
+	}
+
+	dst.Properties[model.FilePropertyTranscoded] = map[string]interface{}{
+		"mime_type": file.MimeType,
+		"size":      file.Size,
+		"sha256sum": file.SHA256Sum,
+		"at":        model.GetMillis(),
+	}
+
+	if err = app.Store.File().ReplaceContent(ctx, file.Id, file.Name, dst); err != nil {
+		backend.Remove(dst)
+		return err
+	}
+
+	if err = backend.Remove(file); err != nil {
+		app.Log.Error(fmt.Sprintf("file %d, remove original \"%s\" error: %s", file.Id, file.Name, err.Error()), wlog.Err(err))
+	}
+
+	wlog.Debug(fmt.Sprintf("transcoded file %d to %s, %d -> %d bytes", file.Id, opts.Codec, file.Size, dst.Size))
+
+	return nil
+}
+
+func (app *App) writeTranscoded(file *model.File, backend utils.FileBackend, opts transcoding.AudioOptions) (*model.File, engine.AppError) {
+	src, err := backend.Reader(file, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	out, e := transcoding.NewAudioReader(src, opts)
+	if e != nil {
+		return nil, engine.NewInternalError("app.transcode.start", e.Error())
+	}
+
+	dst := &model.File{
+		Id:        file.Id,
+		DomainId:  file.DomainId,
+		Uuid:      file.Uuid,
+		ProfileId: file.ProfileId,
+		CreatedAt: file.CreatedAt,
+		BaseFile: model.BaseFile{
+			Name:       opts.FileName(file.Name),
+			MimeType:   opts.MimeType(),
+			Properties: model.StringInterface{},
+			Instance:   app.GetInstanceId(),
+			Channel:    file.Channel,
+		},
+	}
+	if dst.Name == file.Name {
+		dst.Name = opts.FileName(file.Name + "_" + opts.Codec)
+	}
+	if file.ViewName != nil {
+		dst.ViewName = model.NewString(opts.FileName(*file.ViewName))
+	}
+	for k, v := range file.Properties {
+		dst.Properties[k] = v
+	}
+
+	h := sha256.New()
+	size, err := backend.Write(io.TeeReader(out, h), dst)
+	if err != nil && err.GetId() == utils.ErrFileWriteExistsId {
+		// leftover of a previous failed attempt
+		if err = backend.Remove(dst); err == nil {
+			size, err = backend.Write(io.TeeReader(out, h), dst)
+		}
+	}
+
+	if e = out.Close(); e != nil && err == nil {
+		err = engine.NewInternalError("app.transcode.app_error", e.Error())
+	}
+
+	if err == nil && size == 0 {
+		err = engine.NewInternalError("app.transcode.app_error", "empty result")
+	}
+
+	if err != nil {
+		if size > 0 {
+			backend.Remove(dst)
+		}
+		return nil, err
+	}
+
+	sha := fmt.Sprintf("%x", h.Sum(nil))
+	dst.Size = size
+	dst.SHA256Sum = &sha
+
+	return dst, nil
+}
+
+// verifyTranscoded reads the written result and compares its duration with the original
+func (app *App) verifyTranscoded(file *model.File, backend utils.FileBackend, dst *model.File) engine.AppError {
+	r, err := backend.Reader(dst, 0)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	probe, e := utils.NewMediaProbe(context.Background())
+	if e != nil {
+		return engine.NewInternalError("app.transcode.verify", e.Error())
+	}
+
+	_, e = io.Copy(probe, r)
+	meta, pe := probe.Close()
+	if e == nil {
+		e = pe
+	}
+	if e != nil {
+		return engine.NewInternalError("app.transcode.verify", e.Error())
+	}
+
+	if meta.Duration <= 0 {
+		return engine.NewInternalError("app.transcode.verify", "unknown duration of the result")
+	}
+
+	if v, ok := file.Properties[model.FilePropertyDuration].(float64); ok && v > 0 {
+		if math.Abs(v-meta.Duration) > math.Max(transcodeDurationDelta, v*0.01) {
+			return engine.NewInternalError("app.transcode.verify", fmt.Sprintf("duration %v, expected %v", meta.Duration, v))
+		}
+	}
+
+	for k, v := range meta.Properties() {
+		dst.Properties[k] = v
+	}
+
+	return nil
 }
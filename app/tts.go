@@ -1,11 +1,14 @@
 package app
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"strings"
+	"time"
 
 	"github.com/webitel/wlog"
+	"go.opentelemetry.io/otel/trace"
 
 	engine "github.com/webitel/engine/model"
 	"github.com/webitel/storage/model"
@@ -23,7 +26,7 @@ const (
 	TtsElevenLabs = "ElevenLabs"
 )
 
-type ttsFunction func(tts2.TTSParams) (io.ReadCloser, *string, *int, error)
+type ttsFunction func(context.Context, tts2.TTSParams) (io.ReadCloser, *string, *int, error)
 
 var (
 	ttsEngine = map[string]ttsFunction{
@@ -36,7 +39,7 @@ var (
 	}
 )
 
-func (a *App) TTS(provider string, params tts2.TTSParams) (out io.ReadCloser, t *string, size *int, err engine.AppError) {
+func (a *App) TTS(ctx context.Context, provider string, params tts2.TTSParams) (out io.ReadCloser, t *string, size *int, err engine.AppError) {
 	var ttsErr error
 
 	if params.ProfileId > 0 && len(params.Key) == 0 {
@@ -62,7 +65,17 @@ func (a *App) TTS(provider string, params tts2.TTSParams) (out io.ReadCloser, t
 	}
 	provider = strings.ToLower(provider)
 	if fn, ok := ttsEngine[provider]; ok {
-		out, t, size, ttsErr = fn(params)
+		var span trace.Span
+		ctx, span = tracer.Start(ctx, "App.TTS", trace.WithAttributes(attrProvider.String(provider)))
+		start := time.Now()
+		defer func() {
+			if err != nil {
+				spanError(span, err)
+			}
+			span.End()
+			a.metrics.tts(provider, start, err)
+		}()
+		out, t, size, ttsErr = fn(ctx, params)
 		if ttsErr != nil {
 			switch ttsErr.(type) {
 			case engine.AppError:
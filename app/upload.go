@@ -1,18 +1,27 @@
 package app
 
 import (
+	"context"
 	"crypto/sha256"
 	"fmt"
 	engine "github.com/webitel/engine/model"
 	"github.com/webitel/storage/model"
 	"github.com/webitel/storage/utils"
 	"github.com/webitel/wlog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"io"
 )
 
 // AddUploadJobFile додає файл до черги завантаження
-func (app *App) AddUploadJobFile(src io.Reader, file *model.JobUploadFile) engine.AppError {
-	size, err := app.FileCache.Write(src, file)
+func (app *App) AddUploadJobFile(ctx context.Context, src io.Reader, file *model.JobUploadFile) engine.AppError {
+	done, err := app.BeginWork()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	size, err := app.WriteFile(ctx, app.FileCache, src, file)
 	if err != nil {
 		return err
 	}
@@ -34,33 +43,71 @@ func (app *App) AddUploadJobFile(src io.Reader, file *model.JobUploadFile) engin
 }
 
 // SyncUpload синхронно завантажує файл за замовчуванням
-func (app *App) SyncUpload(src io.Reader, file *model.JobUploadFile) engine.AppError {
+func (app *App) SyncUpload(ctx context.Context, src io.Reader, file *model.JobUploadFile) engine.AppError {
+	done, err := app.BeginWork()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return app.uploadDefault(ctx, src, file)
+}
+
+// SyncUploadToProfile uploads the file to the user profile synchronously
+func (app *App) SyncUploadToProfile(ctx context.Context, src io.Reader, profileId int, file *model.JobUploadFile) engine.AppError {
+	done, err := app.BeginWork()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return app.uploadToProfile(ctx, src, profileId, file)
+}
+
+func (app *App) uploadDefault(ctx context.Context, src io.Reader, file *model.JobUploadFile) engine.AppError {
 	if !app.UseDefaultStore() {
 		return engine.NewInternalError("SyncUpload", "default store error")
 	}
 
-	return app.upload(src, nil, app.DefaultFileStore, file)
+	return app.upload(ctx, src, nil, app.DefaultFileStore, file)
 }
 
-// SyncUploadToProfile синхронно завантажує файл у профіль користувача
-func (app *App) SyncUploadToProfile(src io.Reader, profileId int, file *model.JobUploadFile) engine.AppError {
+func (app *App) uploadToProfile(ctx context.Context, src io.Reader, profileId int, file *model.JobUploadFile) engine.AppError {
 	store, err := app.GetFileBackendStoreById(file.DomainId, profileId)
 	if err != nil {
 		return err
 	}
 
-	return app.upload(src, &profileId, store, file)
+	return app.upload(ctx, src, &profileId, store, file)
 }
 
 // upload - основний метод завантаження файлу з підтримкою мініатюр
-func (app *App) upload(src io.Reader, profileId *int, store utils.FileBackend, file *model.JobUploadFile) engine.AppError {
+func (app *App) upload(ctx context.Context, src io.Reader, profileId *int, store utils.FileBackend, file *model.JobUploadFile) (err engine.AppError) {
+	ctx, span := tracer.Start(ctx, "App.upload", trace.WithAttributes(uploadFileAttributes(file)...))
+	defer func() {
+		if err != nil {
+			spanError(span, err)
+		}
+		span.End()
+	}()
+
 	var reader io.Reader
 	var thumbnail *utils.Thumbnail
 	var ch chan engine.AppError
-	var err engine.AppError
+	var stripper *utils.MetadataStripper
+
+	if src, err = ReadUploadHead(src); err != nil {
+		return err
+	}
+
+	if app.StripImageMetadata(file.DomainId, file.Channel, file.MimeType) {
+		stripper = utils.NewMetadataStripper(file.MimeType, src)
+		defer stripper.Close()
+		src = stripper
+	}
 
 	if file.GenerateThumbnail {
-		reader, thumbnail, ch, err = app.setupThumbnail(src, store, file)
+		reader, thumbnail, ch, err = app.setupThumbnail(ctx, src, store, file)
 		if err != nil {
 			return err
 		}
@@ -73,13 +120,31 @@ func (app *App) upload(src io.Reader, profileId *int, store utils.FileBackend, f
 		reader = src
 	}
 
+	probe := app.NewMediaProbe(ctx, file.MimeType)
+	if probe != nil {
+		reader = io.TeeReader(reader, probe)
+	}
+
 	// Завантаження основного файлу
-	sf, err := app.syncUpload(store, reader, file, profileId)
+	sf, err := app.syncUpload(ctx, store, reader, file, profileId)
 	if err != nil {
+		if probe != nil {
+			probe.Close()
+		}
 		return err
 	}
 	file.Size = sf.Size
 
+	if probe != nil {
+		app.ApplyMediaProbe(probe, sf.Properties)
+	}
+
+	if stripper != nil {
+		if removed := stripper.Removed(); len(removed) != 0 {
+			sf.Properties[model.FilePropertyMetadataStripped] = removed
+		}
+	}
+
 	// Завершення обробки мініатюри, якщо вона існує
 	if ch != nil {
 		if err := <-ch; err != nil {
@@ -98,7 +163,7 @@ func (app *App) upload(src io.Reader, profileId *int, store utils.FileBackend, f
 }
 
 // setupThumbnail налаштовує мініатюру для файлу, якщо це зображення або відео
-func (app *App) setupThumbnail(src io.Reader, store utils.FileBackend, file *model.JobUploadFile) (io.Reader, *utils.Thumbnail, chan engine.AppError, engine.AppError) {
+func (app *App) setupThumbnail(ctx context.Context, src io.Reader, store utils.FileBackend, file *model.JobUploadFile) (io.Reader, *utils.Thumbnail, chan engine.AppError, engine.AppError) {
 	if !utils.IsSupportThumbnail(file.MimeType) {
 		return src, nil, nil, nil
 	}
@@ -117,7 +182,11 @@ func (app *App) setupThumbnail(src io.Reader, store utils.FileBackend, file *mod
 	ch := make(chan engine.AppError)
 
 	go func() {
-		if f, e := app.syncUpload(store, thumbnail.Reader(), &thumbnailFile, nil); e != nil {
+		ctx, span := tracer.Start(ctx, "App.thumbnail", trace.WithAttributes(attribute.String("thumbnail.scale", thumbnail.Scale())))
+		defer span.End()
+
+		if f, e := app.syncUpload(ctx, store, thumbnail.Reader(), &thumbnailFile, nil); e != nil {
+			spanError(span, e)
 			ch <- e
 		} else {
 			thumbnail.UserData = &model.Thumbnail{BaseFile: f.BaseFile, Scale: thumbnail.Scale()}
@@ -129,7 +198,10 @@ func (app *App) setupThumbnail(src io.Reader, store utils.FileBackend, file *mod
 }
 
 // syncUpload здійснює запис файлу до файлового сховища
-func (app *App) syncUpload(store utils.FileBackend, src io.Reader, file *model.JobUploadFile, profileId *int) (*model.File, engine.AppError) {
+func (app *App) syncUpload(ctx context.Context, store utils.FileBackend, src io.Reader, file *model.JobUploadFile, profileId *int) (*model.File, engine.AppError) {
+	ctx, span := tracer.Start(ctx, "App.syncUpload", trace.WithAttributes(uploadFileAttributes(file)...))
+	defer span.End()
+
 	f := &model.File{
 		DomainId:  file.DomainId,
 		Uuid:      file.Uuid,
@@ -151,10 +223,12 @@ func (app *App) syncUpload(store utils.FileBackend, src io.Reader, file *model.J
 	h := sha256.New()
 	tr := io.TeeReader(src, h)
 
-	size, err := store.Write(tr, f)
+	size, err := app.WriteFile(ctx, store, tr, f)
 	if err != nil && err.GetId() != utils.ErrFileWriteExistsId {
+		spanError(span, err)
 		return nil, err
 	}
+	span.SetAttributes(attribute.Int64("file.size", size))
 
 	sha := fmt.Sprintf("%x", h.Sum(nil))
 	file.SHA256Sum = &sha
@@ -174,3 +248,12 @@ func (app *App) storeFile(store utils.FileBackend, file *model.File) (int64, eng
 	wlog.Debug(fmt.Sprintf("Stored %s in %s, %d bytes [SHA256=%v]", file.GetStoreName(), store.Name(), file.Size, file.SHA256Sum))
 	return res.Data.(int64), nil
 }
+
+func uploadFileAttributes(file *model.JobUploadFile) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.Int64("domain_id", file.DomainId),
+		attribute.String("file.uuid", file.Uuid),
+		attribute.String("file.mime_type", file.MimeType),
+		attrChannel.String(fileChannel(file.Channel)),
+	}
+}
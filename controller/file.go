@@ -33,3 +33,60 @@ func (c *Controller) SearchFile(ctx context.Context, session *auth_manager.Sessi
 
 	return c.app.SearchFiles(ctx, session.Domain(0), search)
 }
+
+func (c *Controller) CreateThumbnailJobs(ctx context.Context, session *auth_manager.Session, job *model.ThumbnailJob) (int64, engine.AppError) {
+	permission := session.GetPermission(model.PermissionScopeFiles)
+	if !permission.CanRead() {
+		return 0, c.app.MakePermissionError(session, permission, auth_manager.PERMISSION_ACCESS_READ)
+	}
+
+	if !permission.CanUpdate() {
+		return 0, c.app.MakePermissionError(session, permission, auth_manager.PERMISSION_ACCESS_UPDATE)
+	}
+
+	return c.app.CreateThumbnailJobs(ctx, session.Domain(0), job)
+}
+
+func (c *Controller) ThumbnailJobsProgress(ctx context.Context, session *auth_manager.Session) (*model.FileJobsProgress, engine.AppError) {
+	permission := session.GetPermission(model.PermissionScopeFiles)
+	if !permission.CanRead() {
+		return nil, c.app.MakePermissionError(session, permission, auth_manager.PERMISSION_ACCESS_READ)
+	}
+
+	return c.app.ThumbnailJobsProgress(ctx, session.Domain(0))
+}
+
+func (c *Controller) CreateMediaMetadataJobs(ctx context.Context, session *auth_manager.Session, job *model.MediaMetadataJob) (int64, engine.AppError) {
+	permission := session.GetPermission(model.PermissionScopeFiles)
+	if !permission.CanRead() {
+		return 0, c.app.MakePermissionError(session, permission, auth_manager.PERMISSION_ACCESS_READ)
+	}
+
+	if !permission.CanUpdate() {
+		return 0, c.app.MakePermissionError(session, permission, auth_manager.PERMISSION_ACCESS_UPDATE)
+	}
+
+	return c.app.CreateMediaMetadataJobs(ctx, session.Domain(0), job)
+}
+
+func (c *Controller) MediaMetadataJobsProgress(ctx context.Context, session *auth_manager.Session) (*model.FileJobsProgress, engine.AppError) {
+	permission := session.GetPermission(model.PermissionScopeFiles)
+	if !permission.CanRead() {
+		return nil, c.app.MakePermissionError(session, permission, auth_manager.PERMISSION_ACCESS_READ)
+	}
+
+	return c.app.MediaMetadataJobsProgress(ctx, session.Domain(0))
+}
+
+func (c *Controller) MergeFileChannels(ctx context.Context, session *auth_manager.Session, m *model.MergeFileChannels) (*model.File, engine.AppError) {
+	permission := session.GetPermission(model.PermissionScopeFiles)
+	if !permission.CanRead() {
+		return nil, c.app.MakePermissionError(session, permission, auth_manager.PERMISSION_ACCESS_READ)
+	}
+
+	if !permission.CanCreate() {
+		return nil, c.app.MakePermissionError(session, permission, auth_manager.PERMISSION_ACCESS_CREATE)
+	}
+
+	return c.app.MergeFileChannels(ctx, session.Domain(0), m)
+}
@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/webitel/engine/auth_manager"
+	engine "github.com/webitel/engine/model"
+	"github.com/webitel/storage/model"
+)
+
+func (c *Controller) SearchFileJobs(ctx context.Context, session *auth_manager.Session, search *model.SearchFileJob) ([]*model.FileJob, bool, engine.AppError) {
+	permission := session.GetPermission(model.PermissionScopeFiles)
+	if !permission.CanRead() {
+		return nil, false, c.app.MakePermissionError(session, permission, auth_manager.PERMISSION_ACCESS_READ)
+	}
+
+	return c.app.SearchFileJobs(ctx, session.Domain(0), search)
+}
+
+func (c *Controller) GetFileJob(ctx context.Context, session *auth_manager.Session, id int64) (*model.FileJob, engine.AppError) {
+	permission := session.GetPermission(model.PermissionScopeFiles)
+	if !permission.CanRead() {
+		return nil, c.app.MakePermissionError(session, permission, auth_manager.PERMISSION_ACCESS_READ)
+	}
+
+	return c.app.GetFileJob(ctx, session.Domain(0), id)
+}
+
+func (c *Controller) RetryFileJobs(ctx context.Context, session *auth_manager.Session, bulk *model.FileJobsBulk) (int64, engine.AppError) {
+	permission := session.GetPermission(model.PermissionScopeFiles)
+	if !permission.CanRead() {
+		return 0, c.app.MakePermissionError(session, permission, auth_manager.PERMISSION_ACCESS_READ)
+	}
+
+	if !permission.CanUpdate() {
+		return 0, c.app.MakePermissionError(session, permission, auth_manager.PERMISSION_ACCESS_UPDATE)
+	}
+
+	return c.app.RetryFileJobs(ctx, session.Domain(0), bulk)
+}
+
+func (c *Controller) CancelFileJobs(ctx context.Context, session *auth_manager.Session, bulk *model.FileJobsBulk) (int64, engine.AppError) {
+	permission := session.GetPermission(model.PermissionScopeFiles)
+	if !permission.CanRead() {
+		return 0, c.app.MakePermissionError(session, permission, auth_manager.PERMISSION_ACCESS_READ)
+	}
+
+	if !permission.CanUpdate() {
+		return 0, c.app.MakePermissionError(session, permission, auth_manager.PERMISSION_ACCESS_UPDATE)
+	}
+
+	return c.app.CancelFileJobs(ctx, session.Domain(0), bulk)
+}
+
+func (c *Controller) FileJobsQueue(ctx context.Context, session *auth_manager.Session) ([]*model.FileJobQueue, engine.AppError) {
+	permission := session.GetPermission(model.PermissionScopeFiles)
+	if !permission.CanRead() {
+		return nil, c.app.MakePermissionError(session, permission, auth_manager.PERMISSION_ACCESS_READ)
+	}
+
+	return c.app.FileJobsQueue(ctx, session.Domain(0))
+}
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"context"
 	"io"
 
 	"github.com/webitel/engine/auth_manager"
@@ -53,7 +54,7 @@ func (c *Controller) GetMediaFile(session *auth_manager.Session, domainId int64,
 	return c.app.GetMediaFile(session.Domain(domainId), id)
 }
 
-func (c *Controller) DeleteMediaFile(session *auth_manager.Session, domainId int64, id int) (*model.MediaFile, engine.AppError) {
+func (c *Controller) DeleteMediaFile(session *auth_manager.Session, domainId int64, id int, force bool) (*model.MediaFile, engine.AppError) {
 	permission := session.GetPermission(model.PERMISSION_SCOPE_MEDIA_FILE)
 	if !permission.CanRead() {
 		return nil, c.app.MakePermissionError(session, permission, auth_manager.PERMISSION_ACCESS_READ)
@@ -63,5 +64,138 @@ func (c *Controller) DeleteMediaFile(session *auth_manager.Session, domainId int
 		return nil, c.app.MakePermissionError(session, permission, auth_manager.PERMISSION_ACCESS_DELETE)
 	}
 
-	return c.app.DeleteMediaFile(session.Domain(domainId), id)
+	return c.app.DeleteMediaFile(session.Domain(domainId), id, force)
+}
+
+func (c *Controller) MigrateMediaFiles(ctx context.Context, session *auth_manager.Session, migration *model.MediaMigration) (*model.MediaMigrationResult, engine.AppError) {
+	permission := session.GetPermission(model.PERMISSION_SCOPE_MEDIA_FILE)
+	if !permission.CanRead() {
+		return nil, c.app.MakePermissionError(session, permission, auth_manager.PERMISSION_ACCESS_READ)
+	}
+
+	if !permission.CanUpdate() {
+		return nil, c.app.MakePermissionError(session, permission, auth_manager.PERMISSION_ACCESS_UPDATE)
+	}
+
+	return c.app.MigrateMediaFiles(ctx, session.Domain(0), migration)
+}
+
+func (c *Controller) GetMediaFileVersions(ctx context.Context, session *auth_manager.Session, domainId int64, id int) ([]*model.MediaFileVersion, engine.AppError) {
+	permission := session.GetPermission(model.PERMISSION_SCOPE_MEDIA_FILE)
+	if !permission.CanRead() {
+		return nil, c.app.MakePermissionError(session, permission, auth_manager.PERMISSION_ACCESS_READ)
+	}
+
+	return c.app.GetMediaFileVersions(ctx, session.Domain(domainId), id)
+}
+
+func (c *Controller) GetMediaFileVersion(ctx context.Context, session *auth_manager.Session, domainId int64, id int, version int) (*model.MediaFile, engine.AppError) {
+	permission := session.GetPermission(model.PERMISSION_SCOPE_MEDIA_FILE)
+	if !permission.CanRead() {
+		return nil, c.app.MakePermissionError(session, permission, auth_manager.PERMISSION_ACCESS_READ)
+	}
+
+	return c.app.GetMediaFileVersion(ctx, session.Domain(domainId), id, version)
+}
+
+func (c *Controller) UploadMediaFileVersion(ctx context.Context, session *auth_manager.Session, domainId int64, id int, src io.ReadCloser, upload *model.MediaFile) (*model.MediaFile, engine.AppError) {
+	permission := session.GetPermission(model.PERMISSION_SCOPE_MEDIA_FILE)
+	if !permission.CanRead() {
+		return nil, c.app.MakePermissionError(session, permission, auth_manager.PERMISSION_ACCESS_READ)
+	}
+
+	if !permission.CanUpdate() {
+		return nil, c.app.MakePermissionError(session, permission, auth_manager.PERMISSION_ACCESS_UPDATE)
+	}
+
+	upload.UpdatedBy = &model.Lookup{
+		Id: int(session.UserId),
+	}
+
+	return c.app.UploadMediaFileVersion(ctx, session.Domain(domainId), id, src, upload)
+}
+
+func (c *Controller) ActivateMediaFileVersion(ctx context.Context, session *auth_manager.Session, domainId int64, id int, version int) (*model.MediaFile, engine.AppError) {
+	permission := session.GetPermission(model.PERMISSION_SCOPE_MEDIA_FILE)
+	if !permission.CanRead() {
+		return nil, c.app.MakePermissionError(session, permission, auth_manager.PERMISSION_ACCESS_READ)
+	}
+
+	if !permission.CanUpdate() {
+		return nil, c.app.MakePermissionError(session, permission, auth_manager.PERMISSION_ACCESS_UPDATE)
+	}
+
+	return c.app.ActivateMediaFileVersion(ctx, session.Domain(domainId), id, version, &model.Lookup{
+		Id: int(session.UserId),
+	})
+}
+
+func (c *Controller) GetMediaFolders(ctx context.Context, session *auth_manager.Session) ([]*model.MediaFolder, engine.AppError) {
+	permission := session.GetPermission(model.PERMISSION_SCOPE_MEDIA_FILE)
+	if !permission.CanRead() {
+		return nil, c.app.MakePermissionError(session, permission, auth_manager.PERMISSION_ACCESS_READ)
+	}
+
+	return c.app.GetMediaFolders(ctx, session.Domain(0))
+}
+
+func (c *Controller) BulkUpdateMediaFiles(ctx context.Context, session *auth_manager.Session, bulk *model.MediaFilesBulk) (int64, engine.AppError) {
+	permission := session.GetPermission(model.PERMISSION_SCOPE_MEDIA_FILE)
+	if !permission.CanRead() {
+		return 0, c.app.MakePermissionError(session, permission, auth_manager.PERMISSION_ACCESS_READ)
+	}
+
+	if !permission.CanUpdate() {
+		return 0, c.app.MakePermissionError(session, permission, auth_manager.PERMISSION_ACCESS_UPDATE)
+	}
+
+	return c.app.BulkUpdateMediaFiles(ctx, session.Domain(0), bulk, &model.Lookup{
+		Id: int(session.UserId),
+	})
+}
+
+func (c *Controller) ImportMediaFiles(ctx context.Context, session *auth_manager.Session, archive io.ReaderAt, size int64, imp *model.MediaImport) (*model.MediaImportResult, engine.AppError) {
+	permission := session.GetPermission(model.PERMISSION_SCOPE_MEDIA_FILE)
+	if !permission.CanCreate() {
+		return nil, c.app.MakePermissionError(session, permission, auth_manager.PERMISSION_ACCESS_CREATE)
+	}
+
+	return c.app.ImportMediaFiles(ctx, archive, size, imp, model.DomainRecord{
+		DomainId:  session.Domain(0),
+		CreatedAt: model.GetMillis(),
+		CreatedBy: &model.Lookup{
+			Id: int(session.UserId),
+		},
+		UpdatedAt: model.GetMillis(),
+		UpdatedBy: &model.Lookup{
+			Id: int(session.UserId),
+		},
+	})
+}
+
+func (c *Controller) GetMediaFileUsage(ctx context.Context, session *auth_manager.Session, domainId int64, id int) (*model.MediaFileUsage, engine.AppError) {
+	permission := session.GetPermission(model.PERMISSION_SCOPE_MEDIA_FILE)
+	if !permission.CanRead() {
+		return nil, c.app.MakePermissionError(session, permission, auth_manager.PERMISSION_ACCESS_READ)
+	}
+
+	return c.app.GetMediaFileUsage(ctx, session.Domain(domainId), id)
+}
+
+func (c *Controller) AddMediaFileReference(ctx context.Context, session *auth_manager.Session, domainId int64, ref *model.MediaFileReference) engine.AppError {
+	permission := session.GetPermission(model.PERMISSION_SCOPE_MEDIA_FILE)
+	if !permission.CanRead() {
+		return c.app.MakePermissionError(session, permission, auth_manager.PERMISSION_ACCESS_READ)
+	}
+
+	return c.app.AddMediaFileReference(ctx, session.Domain(domainId), ref)
+}
+
+func (c *Controller) RemoveMediaFileReference(ctx context.Context, session *auth_manager.Session, domainId int64, ref *model.MediaFileReference) engine.AppError {
+	permission := session.GetPermission(model.PERMISSION_SCOPE_MEDIA_FILE)
+	if !permission.CanRead() {
+		return c.app.MakePermissionError(session, permission, auth_manager.PERMISSION_ACCESS_READ)
+	}
+
+	return c.app.RemoveMediaFileReference(ctx, session.Domain(domainId), ref)
 }
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"context"
 	"io"
 
 	"github.com/webitel/engine/auth_manager"
@@ -19,13 +20,13 @@ func (c *Controller) GetFileWithProfile(session *auth_manager.Session, domainId,
 	return c.app.GetFileWithProfile(session.Domain(domainId), id)
 }
 
-func (c *Controller) UploadFileStream(src io.ReadCloser, file *model.JobUploadFile) engine.AppError {
-	return c.app.SyncUpload(src, file)
+func (c *Controller) UploadFileStream(ctx context.Context, src io.ReadCloser, file *model.JobUploadFile) engine.AppError {
+	return c.app.SyncUpload(ctx, src, file)
 }
 
-func (c *Controller) UploadFileStreamToProfile(src io.ReadCloser, profileId int, file *model.JobUploadFile) engine.AppError {
+func (c *Controller) UploadFileStreamToProfile(ctx context.Context, src io.ReadCloser, profileId int, file *model.JobUploadFile) engine.AppError {
 	//c.app.FilePolicy(file.DomainId, &file.BaseFile, src)
-	return c.app.SyncUploadToProfile(src, profileId, file)
+	return c.app.SyncUploadToProfile(ctx, src, profileId, file)
 }
 
 func (c *Controller) GeneratePreSignetResourceSignature(resource, action string, id int64, domainId int64) (string, engine.AppError) {
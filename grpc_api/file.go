@@ -109,9 +109,9 @@ func (api *file) UploadFile(in gogrpc.FileService_UploadFileServer) error {
 
 	if metadata.Metadata.ProfileId != 0 {
 		fileRequest.Name = fmt.Sprintf("%s_%s", model.NewId()[0:7], fileRequest.Name)
-		err = api.ctrl.UploadFileStreamToProfile(pipeReader, int(metadata.Metadata.ProfileId), &fileRequest)
+		err = api.ctrl.UploadFileStreamToProfile(in.Context(), pipeReader, int(metadata.Metadata.ProfileId), &fileRequest)
 	} else {
-		err = api.ctrl.UploadFileStream(pipeReader, &fileRequest)
+		err = api.ctrl.UploadFileStream(in.Context(), pipeReader, &fileRequest)
 	}
 
 	if err != nil {
@@ -237,7 +237,7 @@ func (api *file) DownloadFile(in *storage.DownloadFileRequest, stream gogrpc.Fil
 		f.BaseFile = f.Thumbnail.BaseFile
 	}
 
-	sFile, appErr = backend.Reader(f, in.Offset)
+	sFile, appErr = api.ctrl.App().FileReader(stream.Context(), backend, f, in.Offset)
 	if appErr != nil {
 		return appErr
 	}
@@ -308,7 +308,7 @@ func (api *file) UploadFileUrl(ctx context.Context, in *storage.UploadFileUrlReq
 		fileRequest.MimeType = in.Mime
 	}
 
-	if err = api.ctrl.UploadFileStream(res.Body, &fileRequest); err != nil {
+	if err = api.ctrl.UploadFileStream(ctx, res.Body, &fileRequest); err != nil {
 		return nil, err
 	}
 
@@ -376,7 +376,7 @@ func (api *file) SafeUploadFile(in gogrpc.FileService_SafeUploadFileServer) erro
 		if r.Metadata.ProfileId > 0 {
 			pid = model.NewInt(int(r.Metadata.ProfileId))
 		}
-		su, gErr = api.ctrl.App().NewSafeUpload(pid, &fileRequest)
+		su, gErr = api.ctrl.App().NewSafeUpload(ctx, pid, &fileRequest)
 		if gErr != nil {
 			return gErr
 		}
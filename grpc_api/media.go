@@ -90,7 +90,7 @@ func (api *media) DeleteMediaFile(ctx context.Context, in *storage.DeleteMediaFi
 	}
 	var file *model.MediaFile
 
-	file, err = api.ctrl.DeleteMediaFile(session, in.GetDomainId(), int(in.GetId()))
+	file, err = api.ctrl.DeleteMediaFile(session, in.GetDomainId(), int(in.GetId()), false)
 	if err != nil {
 		return nil, err
 	}
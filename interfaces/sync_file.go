@@ -9,6 +9,13 @@ type JobInterface interface {
 	MakeScheduler() model.Scheduler
 }
 
+// JobServerInterface runs storage.jobs jobs according to the storage.schedulers schedules
+type JobServerInterface interface {
+	Start()
+	Stop()
+	HasJobType(jobType string) bool
+}
+
 type SyncFilesJobInterface interface {
 	MakeWorker() model.Worker
 	MakeScheduler() model.Scheduler
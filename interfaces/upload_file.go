@@ -1,6 +1,9 @@
 package interfaces
 
+import "context"
+
 type UploadRecordingsFilesInterface interface {
 	Start()
-	Stop()
+	// Stop waits for the active work until ctx is done
+	Stop(ctx context.Context)
 }
@@ -0,0 +1,58 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	engine "github.com/webitel/engine/model"
+	"github.com/webitel/storage/app"
+	"github.com/webitel/storage/interfaces"
+	"github.com/webitel/storage/model"
+	"github.com/webitel/wlog"
+)
+
+// dataRetention removes finished jobs older than days (30 days by default) and failed file jobs
+type dataRetention struct {
+	app *app.App
+}
+
+func init() {
+	RegisterJobType(model.JOB_TYPE_DATA_RETENTION, func(a *app.App) interfaces.JobInterface {
+		return &dataRetention{app: a}
+	})
+}
+
+func (d *dataRetention) MakeWorker() model.Worker {
+	return NewWorker(model.JOB_TYPE_DATA_RETENTION, d.app, d.run)
+}
+
+func (d *dataRetention) MakeScheduler() model.Scheduler {
+	return NewScheduler("DataRetention", model.JOB_TYPE_DATA_RETENTION)
+}
+
+func (d *dataRetention) run(ctx context.Context, job *Job) engine.AppError {
+	days := model.JobRetentionDaysDefault
+	if v, ok := job.Data[model.JobDataRetentionDays]; ok {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			days = n
+		}
+	}
+
+	cnt, err := d.app.Store.Job().DeleteFinished(ctx, model.GetMillis()-int64(days)*int64(24*time.Hour/time.Millisecond))
+	if err != nil {
+		return err
+	}
+	wlog.Debug(fmt.Sprintf("job %s, removed %d finished jobs", job.Id, cnt))
+
+	if err = job.SetProgress(50); err != nil {
+		return err
+	}
+
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	return d.app.RemoveFileJobErrors()
+}
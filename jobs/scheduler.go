@@ -0,0 +1,39 @@
+package jobs
+
+import (
+	engine "github.com/webitel/engine/model"
+	"github.com/webitel/storage/model"
+)
+
+// scheduler default scheduler: a new job per schedule, skipped if the previous one is still queued
+type scheduler struct {
+	name    string
+	jobType string
+}
+
+func NewScheduler(name, jobType string) model.Scheduler {
+	return &scheduler{
+		name:    name,
+		jobType: jobType,
+	}
+}
+
+func (s *scheduler) Name() string {
+	return s.name
+}
+
+func (s *scheduler) JobType() string {
+	return s.jobType
+}
+
+func (s *scheduler) Enabled(_ *model.Config) bool {
+	return true
+}
+
+func (s *scheduler) ScheduleJob(_ *model.Config, pendingJobs bool, _ *model.Job) (*model.Job, engine.AppError) {
+	if pendingJobs {
+		return nil, nil
+	}
+
+	return model.NewJob(s.jobType, nil), nil
+}
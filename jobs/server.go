@@ -0,0 +1,206 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/webitel/storage/app"
+	"github.com/webitel/storage/interfaces"
+	"github.com/webitel/storage/model"
+	"github.com/webitel/wlog"
+)
+
+const (
+	watcherPollingInterval = 5 * time.Second
+	schedulerInterval      = 30 * time.Second
+	// maxScheduleSkips after a long service downtime missed runs are not executed, only the last one
+	maxScheduleSkips = 10000
+)
+
+var jobTypes = make(map[string]func(*app.App) interfaces.JobInterface)
+
+// RegisterJobType registers a job type; called from init() of the type file
+func RegisterJobType(jobType string, f func(*app.App) interfaces.JobInterface) {
+	jobTypes[jobType] = f
+}
+
+type JobServer struct {
+	App        *app.App
+	workers    map[string]model.Worker
+	schedulers map[string]model.Scheduler
+	stopSignal chan struct{}
+	wg         sync.WaitGroup
+}
+
+func init() {
+	app.RegisterJobServer(func(a *app.App) interfaces.JobServerInterface {
+		wlog.Debug("Initialize job server")
+		srv := &JobServer{
+			App:        a,
+			workers:    make(map[string]model.Worker),
+			schedulers: make(map[string]model.Scheduler),
+			stopSignal: make(chan struct{}),
+		}
+
+		for t, f := range jobTypes {
+			job := f(a)
+			srv.workers[t] = job.MakeWorker()
+			srv.schedulers[t] = job.MakeScheduler()
+		}
+
+		return srv
+	})
+}
+
+func (srv *JobServer) HasJobType(jobType string) bool {
+	_, ok := srv.workers[jobType]
+	return ok
+}
+
+func (srv *JobServer) Start() {
+	wlog.Debug("Run job server")
+	for _, w := range srv.workers {
+		go w.Run()
+	}
+
+	srv.wg.Add(2)
+	go srv.watch()
+	go srv.schedule()
+}
+
+func (srv *JobServer) Stop() {
+	close(srv.stopSignal)
+	srv.wg.Wait()
+
+	for _, w := range srv.workers {
+		w.Stop()
+	}
+	wlog.Debug("Stopped job server")
+}
+
+// watch passes queued jobs to the workers; a worker busy with another job gets it next time
+func (srv *JobServer) watch() {
+	defer srv.wg.Done()
+
+	for {
+		select {
+		case <-srv.stopSignal:
+			return
+		case <-time.After(watcherPollingInterval):
+			result := <-srv.App.Store.Job().GetAllByStatusAndLessScheduleTime(model.JOB_STATUS_PENDING, model.GetMillis())
+			if result.Err != nil {
+				wlog.Error(result.Err.Error())
+				continue
+			}
+
+			for _, job := range result.Data.([]*model.Job) {
+				w, ok := srv.workers[job.Type]
+				if !ok {
+					continue
+				}
+
+				select {
+				case w.JobChannel() <- *job:
+				default:
+				}
+			}
+		}
+	}
+}
+
+// schedule creates jobs by the storage.schedulers schedules; several instances create only one job per run time
+func (srv *JobServer) schedule() {
+	defer srv.wg.Done()
+
+	for {
+		select {
+		case <-srv.stopSignal:
+			return
+		case <-time.After(schedulerInterval):
+			for t, s := range srv.schedulers {
+				if !s.Enabled(srv.App.Config()) {
+					continue
+				}
+
+				result := <-srv.App.Store.Schedule().GetAllPageByType(t)
+				if result.Err != nil {
+					wlog.Error(result.Err.Error())
+					continue
+				}
+
+				for _, sc := range result.Data.([]*model.Schedule) {
+					if err := srv.scheduleJob(s, sc); err != nil {
+						wlog.Error(fmt.Sprintf("schedule %d [%s] error: %s", sc.Id, sc.Name, err.Error()))
+					}
+				}
+			}
+		}
+	}
+}
+
+func (srv *JobServer) scheduleJob(s model.Scheduler, sc *model.Schedule) error {
+	ctx := context.Background()
+	last, err := srv.App.Store.Job().GetLastScheduleTime(ctx, sc.Id)
+	if err != nil {
+		return err
+	}
+
+	if last == 0 {
+		last = sc.CreatedAt
+	}
+
+	fireTime := scheduleFireTime(sc, last, model.GetMillis())
+	if fireTime == 0 {
+		return nil
+	}
+
+	pending := <-srv.App.Store.Job().GetCountByStatusAndType(model.JOB_STATUS_PENDING, sc.Type)
+	if pending.Err != nil {
+		return pending.Err
+	}
+
+	lastSuccessful := <-srv.App.Store.Job().GetNewestJobByStatusAndType(model.JOB_STATUS_SUCCESS, sc.Type)
+	if lastSuccessful.Err != nil {
+		return lastSuccessful.Err
+	}
+
+	job, appErr := s.ScheduleJob(srv.App.Config(), pending.Data.(int64) > 0, lastSuccessful.Data.(*model.Job))
+	if appErr != nil {
+		return appErr
+	}
+
+	if job == nil {
+		return nil
+	}
+
+	job.ScheduleId = model.NewInt64(sc.Id)
+	job.ScheduleTime = fireTime
+
+	created, err := srv.App.Store.Job().CreateScheduled(ctx, job)
+	if err != nil {
+		return err
+	}
+
+	if created {
+		wlog.Debug(fmt.Sprintf("schedule %d [%s], created job %s", sc.Id, sc.Name, job.Id))
+	}
+
+	return nil
+}
+
+// scheduleFireTime the last run time of the schedule after last that has already come; 0 - not yet
+func scheduleFireTime(sc *model.Schedule, last, now int64) int64 {
+	var fireTime int64
+
+	for i := 0; i < maxScheduleSkips; i++ {
+		next := sc.NextTime(time.UnixMilli(last))
+		if next == 0 || next > now {
+			break
+		}
+		fireTime, last = next, next
+	}
+
+	return fireTime
+}
@@ -0,0 +1,215 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	engine "github.com/webitel/engine/model"
+	"github.com/webitel/storage/app"
+	"github.com/webitel/storage/model"
+	"github.com/webitel/wlog"
+)
+
+const heartbeatInterval = 5 * time.Second
+
+// RunFunc runs the job; the job must return when ctx is cancelled
+type RunFunc func(ctx context.Context, job *Job) engine.AppError
+
+// Job job being run by a worker
+type Job struct {
+	*model.Job
+	mx       sync.Mutex
+	app      *app.App
+	cancel   context.CancelFunc
+	canceled atomic.Bool
+}
+
+// SetProgress saves the job progress in percent; if cancellation is requested, the job ctx is cancelled
+func (j *Job) SetProgress(progress int64) engine.AppError {
+	j.mx.Lock()
+	defer j.mx.Unlock()
+	j.Progress = progress
+
+	return j.update()
+}
+
+func (j *Job) heartbeat() engine.AppError {
+	j.mx.Lock()
+	defer j.mx.Unlock()
+
+	return j.update()
+}
+
+func (j *Job) update() engine.AppError {
+	result := <-j.app.Store.Job().UpdateOptimistically(j.Job, model.JOB_STATUS_IN_PROGRESS)
+	if result.Err != nil {
+		return result.Err
+	}
+
+	if !result.Data.(bool) {
+		j.canceled.Store(true)
+		j.cancel()
+	}
+
+	return nil
+}
+
+type worker struct {
+	name    string
+	app     *app.App
+	run     RunFunc
+	jobs    chan model.Job
+	stop    chan struct{}
+	stopped chan struct{}
+	// cancel the running job when the server stops
+	cancel atomic.Pointer[context.CancelFunc]
+}
+
+func NewWorker(name string, a *app.App, run RunFunc) model.Worker {
+	return &worker{
+		name:    name,
+		app:     a,
+		run:     run,
+		jobs:    make(chan model.Job),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+}
+
+func (w *worker) Run() {
+	wlog.Debug(fmt.Sprintf("worker %s started", w.name))
+	defer close(w.stopped)
+
+	for {
+		select {
+		case <-w.stop:
+			wlog.Debug(fmt.Sprintf("worker %s stopped", w.name))
+			return
+		case job := <-w.jobs:
+			w.doJob(&job)
+		}
+	}
+}
+
+func (w *worker) Stop() {
+	close(w.stop)
+	if cancel := w.cancel.Load(); cancel != nil {
+		(*cancel)()
+	}
+	<-w.stopped
+}
+
+func (w *worker) JobChannel() chan<- model.Job {
+	return w.jobs
+}
+
+func (w *worker) doJob(job *model.Job) {
+	result := <-w.app.Store.Job().UpdateStatusOptimistically(job.Id, model.JOB_STATUS_PENDING, model.JOB_STATUS_IN_PROGRESS)
+	if result.Err != nil {
+		wlog.Error(fmt.Sprintf("worker %s, claim job %s error: %s", w.name, job.Id, result.Err.Error()))
+		return
+	}
+
+	if !result.Data.(bool) {
+		// the job was taken by another instance or cancelled
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w.cancel.Store(&cancel)
+	defer w.cancel.Store(nil)
+
+	job.Status = model.JOB_STATUS_IN_PROGRESS
+	j := &Job{
+		Job:    job,
+		app:    w.app,
+		cancel: cancel,
+	}
+
+	wlog.Debug(fmt.Sprintf("worker %s, job %s started", w.name, job.Id))
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w.heartbeat(j, done)
+	}()
+
+	err := w.run(ctx, j)
+	close(done)
+	wg.Wait()
+
+	w.finish(ctx, j, err)
+}
+
+// heartbeat updates last_activity_at and checks for a cancellation request
+func (w *worker) heartbeat(j *Job, done chan struct{}) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := j.heartbeat(); err != nil {
+				wlog.Error(fmt.Sprintf("worker %s, job %s heartbeat error: %s", w.name, j.Id, err.Error()))
+			}
+		}
+	}
+}
+
+func (w *worker) finish(ctx context.Context, j *Job, err engine.AppError) {
+	switch {
+	case j.canceled.Load():
+		w.setStatus(j, model.JOB_STATUS_CANCEL_REQUESTED, model.JOB_STATUS_CANCELED)
+		wlog.Debug(fmt.Sprintf("worker %s, job %s canceled", w.name, j.Id))
+		return
+	case ctx.Err() != nil && w.isStopped():
+		// return to the queue, the next server run executes the job
+		w.setStatus(j, model.JOB_STATUS_IN_PROGRESS, model.JOB_STATUS_PENDING)
+		return
+	case err != nil:
+		j.Status = model.JOB_STATUS_ERROR
+		if j.Data == nil {
+			j.Data = make(map[string]string)
+		}
+		j.Data[model.JobDataError] = err.Error()
+		wlog.Error(fmt.Sprintf("worker %s, job %s error: %s", w.name, j.Id, err.Error()))
+	default:
+		j.Status = model.JOB_STATUS_SUCCESS
+		j.Progress = 100
+		wlog.Debug(fmt.Sprintf("worker %s, job %s success", w.name, j.Id))
+	}
+
+	result := <-w.app.Store.Job().UpdateOptimistically(j.Job, model.JOB_STATUS_IN_PROGRESS)
+	if result.Err == nil && !result.Data.(bool) {
+		// cancellation requested after the work finished
+		result = <-w.app.Store.Job().UpdateOptimistically(j.Job, model.JOB_STATUS_CANCEL_REQUESTED)
+	}
+
+	if result.Err != nil {
+		wlog.Error(fmt.Sprintf("worker %s, job %s set status error: %s", w.name, j.Id, result.Err.Error()))
+	}
+}
+
+func (w *worker) setStatus(j *Job, currentStatus, newStatus string) {
+	result := <-w.app.Store.Job().UpdateStatusOptimistically(j.Id, currentStatus, newStatus)
+	if result.Err != nil {
+		wlog.Error(fmt.Sprintf("worker %s, job %s set status error: %s", w.name, j.Id, result.Err.Error()))
+	}
+}
+
+func (w *worker) isStopped() bool {
+	select {
+	case <-w.stop:
+		return true
+	default:
+		return false
+	}
+}
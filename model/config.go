@@ -1,8 +1,10 @@
 package model
 
 import (
-	engine "github.com/webitel/engine/model"
+	"strings"
 	"time"
+
+	engine "github.com/webitel/engine/model"
 )
 
 const (
@@ -71,7 +73,14 @@ type Config struct {
 	ProxyUploadUrl     string            `json:"proxy_upload" flag:"proxy_upload||Proxy upload url" env:"PROXY_UPLOAD"`
 	MaxSafeUploadSleep time.Duration     `json:"safe_upload_max_sleep" flag:"safe_upload_max_sleep|60sec|Maximum upload second sleep process" env:"SAFE_UPLOAD_MAX_SLEEP"`
 	Thumbnail          ThumbnailSettings `json:"thumbnail"`
+	MediaMetadata      bool              `json:"media_metadata" flag:"media_metadata|true|Extract duration and codec of audio and video files" env:"MEDIA_METADATA"`
+	Convert            ConvertSettings   `json:"convert"`
+	FileJobs           FileJobsSettings  `json:"file_jobs"`
+	FairShare          FairShareSettings `json:"fair_share"`
+	Shutdown           ShutdownSettings  `json:"shutdown"`
 	Log                LogSettings       `json:"log"`
+	Metrics            MetricsSettings   `json:"metrics"`
+	Health             HealthSettings    `json:"health"`
 	TtsEndpoint        string            `json:"tts_endpoint" flag:"wbt_tts_endpoint||Offline TTS endpoint" env:"WBT_TTS_ENDPOINT"`
 }
 
@@ -83,9 +92,107 @@ type LogSettings struct {
 	Console bool   `json:"console" flag:"log_console|false|Log console" env:"LOG_CONSOLE"`
 }
 
+type MetricsSettings struct {
+	// Prometheus exposes /metrics on the internal server, OTEL_METRICS_EXPORTER exports metrics independently
+	Prometheus bool `json:"prometheus" flag:"metrics_prometheus|false|Prometheus metrics endpoint on the internal server" env:"METRICS_PROMETHEUS"`
+}
+
 type ThumbnailSettings struct {
 	ForceEnabled bool   `json:"force_enabled" flag:"thumbnail_force_enabled|0|Create thumbnail by default" env:"THUMBNAIL_FORCE_ENABLE"`
 	DefaultScale string `json:"default_scale" flag:"thumbnail_default_scale||Default scale for thumbnail" env:"THUMBNAIL_DEFAULT_SCALE"`
+	// VariantMaxSize max width or height of an image variant, the domain setting image_variant_max_size overrides it
+	VariantMaxSize int `json:"variant_max_size" flag:"image_variant_max_size|2048|Maximum width or height of image variant" env:"IMAGE_VARIANT_MAX_SIZE"`
+	// JobRate max thumbnails per second created by the synchronizer for stored files, 0 - unlimited
+	JobRate int `json:"job_rate" flag:"thumbnail_job_rate|5|Maximum thumbnails per second created for stored files" env:"THUMBNAIL_JOB_RATE"`
+}
+
+type ConvertSettings struct {
+	// MaxConcurrent max simultaneous format conversions of streamed files, 0 - unlimited
+	MaxConcurrent int `json:"max_concurrent" flag:"convert_max_concurrent|4|Maximum simultaneous format conversions on stream" env:"CONVERT_MAX_CONCURRENT"`
+	// Cache stores converted outputs as file variants and serves them with range support
+	Cache bool `json:"cache" flag:"convert_cache|false|Store converted files" env:"CONVERT_CACHE"`
+}
+
+// FileJobsSettings workers of the synchronizer per action, so a burst of one action does not delay the others
+type FileJobsSettings struct {
+	RemoveWorkers    int `json:"remove_workers" flag:"file_jobs_remove_workers|5|Synchronizer workers of remove jobs" env:"FILE_JOBS_REMOVE_WORKERS"`
+	STTWorkers       int `json:"stt_workers" flag:"file_jobs_stt_workers|2|Synchronizer workers of STT jobs" env:"FILE_JOBS_STT_WORKERS"`
+	ThumbnailWorkers int `json:"thumbnail_workers" flag:"file_jobs_thumbnail_workers|2|Synchronizer workers of thumbnail jobs" env:"FILE_JOBS_THUMBNAIL_WORKERS"`
+	MetadataWorkers  int `json:"metadata_workers" flag:"file_jobs_metadata_workers|2|Synchronizer workers of metadata jobs" env:"FILE_JOBS_METADATA_WORKERS"`
+	TranscodeWorkers int `json:"transcode_workers" flag:"file_jobs_transcode_workers|1|Synchronizer workers of transcode jobs" env:"FILE_JOBS_TRANSCODE_WORKERS"`
+	// ErrorRetentionHours failed jobs are kept for the retry
+	ErrorRetentionHours int `json:"error_retention_hours" flag:"file_jobs_error_retention_hours|24|Hours to keep failed synchronizer jobs" env:"FILE_JOBS_ERROR_RETENTION_HOURS"`
+	// ReclaimAfterMinutes active jobs without the instance heartbeat are returned to the queue after the minutes, 0 disables
+	ReclaimAfterMinutes int `json:"reclaim_after_minutes" flag:"file_jobs_reclaim_after_minutes|10|Minutes without heartbeat before active jobs of an instance are returned to the queue" env:"FILE_JOBS_RECLAIM_AFTER_MINUTES"`
+}
+
+// Workers count of workers of the action, at least one
+func (s *FileJobsSettings) Workers(action string) int {
+	var n int
+	switch action {
+	case SyncJobRemove:
+		n = s.RemoveWorkers
+	case SyncJobSTT:
+		n = s.STTWorkers
+	case SyncJobThumbnail:
+		n = s.ThumbnailWorkers
+	case SyncJobMetadata:
+		n = s.MetadataWorkers
+	case SyncJobTranscode:
+		n = s.TranscodeWorkers
+	}
+
+	if n < 1 {
+		n = 1
+	}
+
+	return n
+}
+
+// FairShareSettings fetching of upload and file jobs across domains
+type FairShareSettings struct {
+	// DomainMaxActive max active jobs of one domain across all instances, for file jobs per action, 0 - unlimited
+	DomainMaxActive int `json:"domain_max_active" flag:"jobs_domain_max_active|0|Maximum active upload or file jobs of one domain (0 - unlimited)" env:"JOBS_DOMAIN_MAX_ACTIVE"`
+	// PriorityChannels files of the channels are processed first within the domain
+	PriorityChannels string `json:"priority_channels" flag:"jobs_priority_channels|call|Comma separated file channels processed first" env:"JOBS_PRIORITY_CHANNELS"`
+}
+
+func (s *FairShareSettings) FairShare() *FairShare {
+	fair := &FairShare{
+		DomainMaxActive: s.DomainMaxActive,
+	}
+
+	for _, v := range strings.Split(s.PriorityChannels, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			fair.PriorityChannels = append(fair.PriorityChannels, v)
+		}
+	}
+
+	return fair
+}
+
+type ShutdownSettings struct {
+	// DrainTimeout seconds for the whole shutdown to finish active uploads and jobs, unfinished jobs are returned
+	// to the queue by the reaper. The default leaves time to release jobs within the 30s Kubernetes grace period
+	DrainTimeout int `json:"drain_timeout" flag:"shutdown_drain_timeout|25|Seconds for the whole shutdown to finish active uploads and jobs" env:"SHUTDOWN_DRAIN_TIMEOUT"`
+}
+
+func (s *ShutdownSettings) GetDrainTimeout() time.Duration {
+	return time.Duration(s.DrainTimeout) * time.Second
+}
+
+type HealthSettings struct {
+	// FileCacheMinFree readiness drops when free space of the file cache disk falls below this percent
+	FileCacheMinFree int `json:"file_cache_min_free" flag:"health_file_cache_min_free|10|Minimum free disk space of the file cache, percent" env:"HEALTH_FILE_CACHE_MIN_FREE"`
+	// Timeout seconds for all dependency checks of one readiness request
+	Timeout int `json:"timeout" flag:"health_timeout|5|Seconds to check dependencies on readiness" env:"HEALTH_TIMEOUT"`
+}
+
+func (s *HealthSettings) GetTimeout() time.Duration {
+	if s.Timeout <= 0 {
+		return 5 * time.Second
+	}
+	return time.Duration(s.Timeout) * time.Second
 }
 
 type DiscoverySettings struct {
@@ -103,6 +210,11 @@ type MediaFileStoreSettings struct {
 	PathPattern             string `json:"media_store_pattern" flag:"media_store_pattern|$DOMAIN|Media store pattern" env:"MEDIA_STORE_PATTERN"`
 	MaxUploadFileSizeString string `json:"max_upload_file_size" flag:"max_upload_file_size|20MB|Media file directory" env:"MAX_UPLOAD_FILE_SIZE"`
 	MaxUploadFileSize       int64  `json:"-"`
+	MaxImportFileSizeString string `json:"max_import_file_size" flag:"max_import_file_size|500MB|Max size of the media ZIP import" env:"MAX_IMPORT_FILE_SIZE"`
+	MaxImportFileSize       int64  `json:"-"`
+	CacheDirectory          string `json:"media_cache_directory" flag:"media_cache_directory|./cache/media|Local read cache of media files on backend profiles" env:"MEDIA_CACHE_DIRECTORY"`
+	MaxCacheSizeString      string `json:"media_cache_size" flag:"media_cache_size|1GB|Max size of the local read cache of media files, least recently read files are removed" env:"MEDIA_CACHE_SIZE"`
+	MaxCacheSize            int64  `json:"-"`
 }
 
 type DefaultFileStore struct {
@@ -0,0 +1,18 @@
+package model
+
+// FairShare jobs are fetched round-robin by domain, so a bulk import of one domain does not delay the others;
+// within the domain files of the priority channels go first
+type FairShare struct {
+	// DomainMaxActive max active jobs of one domain across all instances, 0 - unlimited
+	DomainMaxActive  int
+	PriorityChannels []string
+}
+
+// DomainJobsLag pending jobs of the domain and the age of the oldest one, in milliseconds
+type DomainJobsLag struct {
+	DomainId   int64 `json:"domain_id" db:"domain_id"`
+	Uploads    int64 `json:"uploads" db:"uploads"`
+	UploadLag  int64 `json:"upload_lag" db:"upload_lag"`
+	FileJobs   int64 `json:"file_jobs" db:"file_jobs"`
+	FileJobLag int64 `json:"file_job_lag" db:"file_job_lag"`
+}
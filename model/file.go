@@ -6,6 +6,15 @@ import (
 	"time"
 )
 
+const (
+	// FilePropertyMetadataStripped list of metadata kinds removed from the image at upload
+	FilePropertyMetadataStripped = "metadata_stripped"
+	// FilePropertyTranscoded the original mime_type, size and sha256sum of the transcoded file
+	FilePropertyTranscoded = "transcoded"
+	// FilePropertyTranscodeError the last transcoding error, the file is skipped by the transcoding policy
+	FilePropertyTranscodeError = "transcode_error"
+)
+
 type SearchFile struct {
 	ListRequest
 	Ids            []int64
@@ -41,6 +50,15 @@ type File struct {
 	NotExists  *bool      `db:"not_exists" json:"-"`
 	Safe       bool       `db:"-" json:"-"`
 	Thumbnail  *Thumbnail `db:"thumbnail" json:"thumbnail"`
+
+	// media metadata of files_list, the source is Properties
+	Duration   *float64 `db:"duration" json:"duration,omitempty"`
+	Codec      *string  `db:"codec" json:"codec,omitempty"`
+	BitRate    *int64   `db:"bit_rate" json:"bit_rate,omitempty"`
+	Channels   *int     `db:"channels" json:"channels,omitempty"`
+	SampleRate *int     `db:"sample_rate" json:"sample_rate,omitempty"`
+	Width      *int     `db:"width" json:"width,omitempty"`
+	Height     *int     `db:"height" json:"height,omitempty"`
 }
 
 type Thumbnail struct {
@@ -61,6 +79,19 @@ func (f *File) Domain() int64 {
 	return f.DomainId
 }
 
+// MediaDuration duration in seconds from metadata, 0 - unknown
+func (f *File) MediaDuration() float64 {
+	if f.Duration != nil {
+		return *f.Duration
+	}
+
+	if v, ok := f.Properties[FilePropertyDuration].(float64); ok {
+		return v
+	}
+
+	return 0
+}
+
 func (f *BaseFile) GetSize() int64 {
 	return f.Size
 }
@@ -132,6 +163,7 @@ func (f File) DefaultOrder() string {
 func (f File) AllowFields() []string {
 	return []string{"id", "name", "view_name", "size", "mime_type", "reference_id", "profile", "uploaded_at", "updated_by",
 		"sha256sum", "channel", "thumbnail", "retention_until",
+		"duration", "codec", "bit_rate", "channels", "sample_rate", "width", "height",
 	}
 }
 
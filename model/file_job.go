@@ -0,0 +1,58 @@
+package model
+
+import (
+	"fmt"
+
+	engine "github.com/webitel/engine/model"
+)
+
+const (
+	FileJobStatePending = 0
+	FileJobStateActive  = 1
+	FileJobStateError   = 3
+
+	FileJobBulkMaxIds = 1000
+)
+
+// FileJob synchronizer job of the stored file, storage.file_jobs
+type FileJob struct {
+	Id        int64   `json:"id" db:"id"`
+	FileId    int64   `json:"file_id" db:"file_id"`
+	FileName  string  `json:"file_name" db:"file_name"`
+	Action    string  `json:"action" db:"action"`
+	State     int     `json:"state" db:"state"`
+	Error     *string `json:"error,omitempty" db:"error"`
+	CreatedAt *int64  `json:"created_at,omitempty" db:"created_at"`
+	UpdatedAt *int64  `json:"updated_at,omitempty" db:"updated_at"`
+}
+
+type SearchFileJob struct {
+	ListRequest
+	Actions   []string
+	States    []int
+	CreatedAt *FilterBetween
+}
+
+// FileJobQueue jobs of the action by state
+type FileJobQueue struct {
+	Action  string `json:"action" db:"action"`
+	Pending int64  `json:"pending" db:"pending"`
+	Active  int64  `json:"active" db:"active"`
+	Failed  int64  `json:"failed" db:"failed"`
+}
+
+type FileJobsBulk struct {
+	Ids []int64 `json:"ids"`
+}
+
+func (b *FileJobsBulk) IsValid() engine.AppError {
+	if len(b.Ids) == 0 {
+		return engine.NewBadRequestError("model.file_jobs_bulk.is_valid.ids.app_error", "ids is required")
+	}
+
+	if len(b.Ids) > FileJobBulkMaxIds {
+		return engine.NewBadRequestError("model.file_jobs_bulk.is_valid.ids.app_error", fmt.Sprintf("ids=%d, max %d", len(b.Ids), FileJobBulkMaxIds))
+	}
+
+	return nil
+}
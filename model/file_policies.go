@@ -7,6 +7,13 @@ import (
 
 const (
 	filePolicyErrorId = "policy.file.allow"
+
+	TranscodeCodecOpus = "opus"
+	TranscodeCodecMp3  = "mp3"
+
+	// DefaultContentSecurityPolicy for markup and text files; not sent for other types, the sandbox breaks
+	// the built-in PDF viewer
+	DefaultContentSecurityPolicy = "default-src 'none'; img-src 'self' data:; media-src 'self'; style-src 'unsafe-inline'; sandbox"
 )
 
 var (
@@ -16,6 +23,8 @@ var (
 	PolicyErrorExtNotAllowed = engine.NewForbiddenError(filePolicyErrorId, "file extension is not allowed")
 	PolicyErrorForbidden     = engine.NewForbiddenError(filePolicyErrorId, "forbidden")
 	PolicyErrorChannel       = engine.NewForbiddenError(filePolicyErrorId, "not found channel")
+	PolicyErrorExtDenied     = engine.NewForbiddenError(filePolicyErrorId, "file extension is denied")
+	PolicyErrorDangerous     = engine.NewForbiddenError(filePolicyErrorId, "executable, script or macro content is not allowed")
 )
 
 type FilePolicy struct {
@@ -36,6 +45,24 @@ type FilePolicy struct {
 	RetentionDays int32       `json:"retention_days" db:"retention_days"`
 	Position      int32       `json:"position" db:"position"`
 	Max           *time.Time  `json:"max" db:"max"`
+	// AllowExtensions if not empty, only these extensions are accepted
+	AllowExtensions StringArray `json:"allow_extensions" db:"allow_extensions"`
+	DenyExtensions  StringArray `json:"deny_extensions" db:"deny_extensions"`
+	// AttachmentMimeTypes files of these types are always served with Content-Disposition: attachment
+	AttachmentMimeTypes   StringArray `json:"attachment_mime_types" db:"attachment_mime_types"`
+	ContentSecurityPolicy *string     `json:"content_security_policy" db:"content_security_policy"`
+	// StripMetadata remove EXIF/XMP/IPTC from uploaded images
+	StripMetadata bool `json:"strip_metadata" db:"strip_metadata"`
+	// TranscodeCodec audio files are transcoded to this codec (opus, mp3) after TranscodeAfterDays, nil - disabled
+	TranscodeCodec     *string `json:"transcode_codec" db:"transcode_codec"`
+	TranscodeBitRate   int32   `json:"transcode_bit_rate" db:"transcode_bit_rate"` // kbps
+	TranscodeAfterDays int32   `json:"transcode_after_days" db:"transcode_after_days"`
+}
+
+// ContentSecurity headers for serving a stored file
+type ContentSecurity struct {
+	Attachment bool
+	Policy     string
 }
 
 type FilePolicyPath struct {
@@ -51,6 +78,17 @@ type FilePolicyPath struct {
 	SpeedUpload   *int64      `json:"speed_upload" db:"speed_upload"`
 	RetentionDays *int32      `json:"retention_days" db:"retention_days"`
 	MaxUploadSize *int64      `json:"max_upload_size" db:"max_upload_size"`
+
+	AllowExtensions StringArray `json:"allow_extensions" db:"allow_extensions"`
+	DenyExtensions  StringArray `json:"deny_extensions" db:"deny_extensions"`
+
+	AttachmentMimeTypes   StringArray `json:"attachment_mime_types" db:"attachment_mime_types"`
+	ContentSecurityPolicy *string     `json:"content_security_policy" db:"content_security_policy"`
+	StripMetadata         *bool       `json:"strip_metadata" db:"strip_metadata"`
+
+	TranscodeCodec     *string `json:"transcode_codec" db:"transcode_codec"`
+	TranscodeBitRate   *int32  `json:"transcode_bit_rate" db:"transcode_bit_rate"`
+	TranscodeAfterDays *int32  `json:"transcode_after_days" db:"transcode_after_days"`
 }
 
 func (p *FilePolicy) Patch(path *FilePolicyPath) {
@@ -81,6 +119,33 @@ func (p *FilePolicy) Patch(path *FilePolicyPath) {
 	if path.RetentionDays != nil {
 		p.RetentionDays = *path.RetentionDays
 	}
+	if path.AllowExtensions != nil {
+		p.AllowExtensions = path.AllowExtensions
+	}
+	if path.DenyExtensions != nil {
+		p.DenyExtensions = path.DenyExtensions
+	}
+	if path.AttachmentMimeTypes != nil {
+		p.AttachmentMimeTypes = path.AttachmentMimeTypes
+	}
+	if path.ContentSecurityPolicy != nil {
+		p.ContentSecurityPolicy = path.ContentSecurityPolicy
+	}
+	if path.StripMetadata != nil {
+		p.StripMetadata = *path.StripMetadata
+	}
+	if path.TranscodeCodec != nil {
+		p.TranscodeCodec = path.TranscodeCodec
+		if *path.TranscodeCodec == "" {
+			p.TranscodeCodec = nil
+		}
+	}
+	if path.TranscodeBitRate != nil {
+		p.TranscodeBitRate = *path.TranscodeBitRate
+	}
+	if path.TranscodeAfterDays != nil {
+		p.TranscodeAfterDays = *path.TranscodeAfterDays
+	}
 }
 
 type SearchFilePolicy struct {
@@ -95,6 +160,8 @@ func (FilePolicy) DefaultOrder() string {
 func (FilePolicy) AllowFields() []string {
 	return []string{"id", "created_at", "created_by", "updated_at", "updated_by", "position", "max_upload_size",
 		"name", "description", "enabled", "mime_types", "channels", "speed_download", "speed_upload", "retention_days",
+		"allow_extensions", "deny_extensions", "attachment_mime_types", "content_security_policy",
+		"strip_metadata", "transcode_codec", "transcode_bit_rate", "transcode_after_days",
 	}
 }
 
@@ -107,6 +174,21 @@ func (FilePolicy) EntityName() string {
 }
 
 func (c *FilePolicy) IsValid() engine.AppError {
+	if c.TranscodeCodec != nil {
+		switch *c.TranscodeCodec {
+		case TranscodeCodecOpus, TranscodeCodecMp3:
+		default:
+			return engine.NewBadRequestError("model.file_policy.is_valid.transcode_codec.app_error", "transcode_codec="+*c.TranscodeCodec)
+		}
+
+		if c.TranscodeBitRate != 0 && (c.TranscodeBitRate < 6 || c.TranscodeBitRate > 320) {
+			return engine.NewBadRequestError("model.file_policy.is_valid.transcode_bit_rate.app_error", "transcode_bit_rate must be 6-320 kbps")
+		}
+
+		if c.TranscodeAfterDays < 0 {
+			return engine.NewBadRequestError("model.file_policy.is_valid.transcode_after_days.app_error", "transcode_after_days < 0")
+		}
+	}
 
 	return nil
 }
@@ -0,0 +1,12 @@
+package model
+
+// ThumbnailJob selects stored files that need a thumbnail
+type ThumbnailJob struct {
+	FileJobFilter
+	Scale string `json:"scale"`
+}
+
+// ThumbnailJobOptions is stored in the config of the sync job
+type ThumbnailJobOptions struct {
+	Scale string `json:"scale"`
+}
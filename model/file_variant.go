@@ -0,0 +1,201 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+
+	engine "github.com/webitel/engine/model"
+)
+
+const (
+	ImageFitContain = "contain"
+	ImageFitCover   = "cover"
+	ImageFitFill    = "fill"
+
+	ImageFormatJpeg = "jpeg"
+	ImageFormatPng  = "png"
+	ImageFormatWebp = "webp"
+
+	SysNameImageVariantMaxSize = "image_variant_max_size"
+
+	WaveformSampleRate             = 8000
+	WaveformDefaultSamplesPerPixel = 256
+	WaveformMinSamplesPerPixel     = 32
+)
+
+// FileVariant derived file (image variant, etc.), stored on the same backend as the parent file
+type FileVariant struct {
+	BaseFile
+	Id        int64  `db:"id" json:"id"`
+	FileId    int64  `db:"file_id" json:"file_id"`
+	DomainId  int64  `db:"domain_id" json:"domain_id"`
+	Uuid      string `db:"uuid" json:"uuid"`
+	Key       string `db:"key" json:"key"`
+	CreatedAt int64  `db:"created_at" json:"created_at"`
+}
+
+func (f *FileVariant) Domain() int64 {
+	return f.DomainId
+}
+
+func (f *FileVariant) GetStoreName() string {
+	return f.Name
+}
+
+type ImageVariant struct {
+	Width  int
+	Height int
+	Fit    string
+	Format string
+}
+
+// SetDefaults fills empty options, the format of the source is kept when it is supported
+func (v *ImageVariant) SetDefaults(srcMime string) {
+	if v.Fit == "" {
+		v.Fit = ImageFitContain
+	}
+
+	if v.Format == "" {
+		switch srcMime {
+		case "image/png", "image/gif":
+			v.Format = ImageFormatPng
+		case "image/webp":
+			v.Format = ImageFormatWebp
+		default:
+			v.Format = ImageFormatJpeg
+		}
+	}
+
+	v.Format = strings.ToLower(v.Format)
+	if v.Format == "jpg" {
+		v.Format = ImageFormatJpeg
+	}
+}
+
+func (v *ImageVariant) IsValid(maxSize int) engine.AppError {
+	if v.Width <= 0 && v.Height <= 0 {
+		return engine.NewBadRequestError("model.image_variant.is_valid.size.app_error", "w or h is required")
+	}
+
+	if v.Width < 0 || v.Height < 0 || (maxSize > 0 && (v.Width > maxSize || v.Height > maxSize)) {
+		return engine.NewBadRequestError("model.image_variant.is_valid.size.app_error", fmt.Sprintf("w=%d, h=%d, max=%d", v.Width, v.Height, maxSize))
+	}
+
+	switch v.Fit {
+	case ImageFitContain, ImageFitCover, ImageFitFill:
+	default:
+		return engine.NewBadRequestError("model.image_variant.is_valid.fit.app_error", "fit="+v.Fit)
+	}
+
+	if (v.Fit == ImageFitCover || v.Fit == ImageFitFill) && (v.Width == 0 || v.Height == 0) {
+		return engine.NewBadRequestError("model.image_variant.is_valid.fit.app_error", "fit="+v.Fit+" requires w and h")
+	}
+
+	switch v.Format {
+	case ImageFormatJpeg, ImageFormatPng, ImageFormatWebp:
+	default:
+		return engine.NewBadRequestError("model.image_variant.is_valid.format.app_error", "format="+v.Format)
+	}
+
+	return nil
+}
+
+func (v *ImageVariant) Key() string {
+	return fmt.Sprintf("image_w%d_h%d_%s.%s", v.Width, v.Height, v.Fit, v.Format)
+}
+
+func (v *ImageVariant) MimeType() string {
+	return "image/" + v.Format
+}
+
+// WaveformVariant peaks of audio channels, SamplesPerPixel is relative to WaveformSampleRate
+type WaveformVariant struct {
+	SamplesPerPixel int
+}
+
+func (v *WaveformVariant) SetDefaults() {
+	if v.SamplesPerPixel == 0 {
+		v.SamplesPerPixel = WaveformDefaultSamplesPerPixel
+	}
+}
+
+func (v *WaveformVariant) IsValid() engine.AppError {
+	if v.SamplesPerPixel < WaveformMinSamplesPerPixel || v.SamplesPerPixel > WaveformSampleRate {
+		return engine.NewBadRequestError("model.waveform_variant.is_valid.samples_per_pixel.app_error",
+			fmt.Sprintf("samples_per_pixel=%d, allowed %d-%d", v.SamplesPerPixel, WaveformMinSamplesPerPixel, WaveformSampleRate))
+	}
+
+	return nil
+}
+
+func (v *WaveformVariant) Key() string {
+	return fmt.Sprintf("waveform_spp%d.json", v.SamplesPerPixel)
+}
+
+func (v *WaveformVariant) MimeType() string {
+	return "application/json"
+}
+
+// ClipVariant fragment of audio or video, the range can be taken from a transcript phrase
+type ClipVariant struct {
+	TranscriptRange
+}
+
+func (v *ClipVariant) IsValid(duration float64) engine.AppError {
+	if v.StartSec < 0 || v.EndSec <= v.StartSec {
+		return engine.NewBadRequestError("model.clip_variant.is_valid.range.app_error",
+			fmt.Sprintf("start_sec=%v, end_sec=%v", v.StartSec, v.EndSec))
+	}
+
+	if duration > 0 && v.StartSec >= duration {
+		return engine.NewBadRequestError("model.clip_variant.is_valid.start_sec.app_error",
+			fmt.Sprintf("start_sec=%v, duration %v", v.StartSec, duration))
+	}
+
+	return nil
+}
+
+// Key range in milliseconds, the end is limited by the duration so equal clips share one key
+func (v *ClipVariant) Key(duration float64) string {
+	end := v.EndSec
+	if duration > 0 && end > duration {
+		end = duration
+	}
+
+	return fmt.Sprintf("clip_%d_%d", int64(v.StartSec*1000), int64(end*1000))
+}
+
+// ChannelVariant one audio channel as mono, Channel starts from 0 as TranscriptPhrase.Channel
+type ChannelVariant struct {
+	Channel int `json:"channel"`
+}
+
+// IsValid channels of the file, 0 - unknown
+func (v *ChannelVariant) IsValid(channels int) engine.AppError {
+	if v.Channel < 0 || (channels > 0 && v.Channel >= channels) {
+		return engine.NewBadRequestError("model.channel_variant.is_valid.channel.app_error",
+			fmt.Sprintf("channel=%d, file channels %d", v.Channel, channels))
+	}
+
+	return nil
+}
+
+func (v *ChannelVariant) Key(ext string) string {
+	return fmt.Sprintf("channel_%d%s", v.Channel, ext)
+}
+
+// MergeFileChannels stereo recording from two mono legs of one call, left and right are file ids
+type MergeFileChannels struct {
+	Left  int64  `json:"left_id"`
+	Right int64  `json:"right_id"`
+	Codec string `json:"codec"`
+}
+
+func (m *MergeFileChannels) IsValid() engine.AppError {
+	if m.Left == 0 || m.Right == 0 || m.Left == m.Right {
+		return engine.NewBadRequestError("model.merge_file_channels.is_valid.files.app_error",
+			fmt.Sprintf("left_id=%d, right_id=%d", m.Left, m.Right))
+	}
+
+	return nil
+}
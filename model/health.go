@@ -0,0 +1,66 @@
+package model
+
+import "encoding/json"
+
+const (
+	HealthStatusUp   = "up"
+	HealthStatusDown = "down"
+)
+
+// HealthCheck result of one dependency check; a failed critical check makes the instance not ready
+type HealthCheck struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Critical bool   `json:"critical"`
+	Error    string `json:"error,omitempty"`
+}
+
+func NewHealthCheck(name string, critical bool, err error) *HealthCheck {
+	c := &HealthCheck{
+		Name:     name,
+		Status:   HealthStatusUp,
+		Critical: critical,
+	}
+
+	if err != nil {
+		c.Status = HealthStatusDown
+		c.Error = err.Error()
+	}
+
+	return c
+}
+
+func (c *HealthCheck) Up() bool {
+	return c.Status == HealthStatusUp
+}
+
+type Health struct {
+	Status string         `json:"status"`
+	Checks []*HealthCheck `json:"checks,omitempty"`
+}
+
+// NewHealth status is down if any critical check failed
+func NewHealth(checks []*HealthCheck) *Health {
+	h := &Health{
+		Status: HealthStatusUp,
+		Checks: checks,
+	}
+
+	for _, c := range checks {
+		if c.Critical && !c.Up() {
+			h.Status = HealthStatusDown
+			break
+		}
+	}
+
+	return h
+}
+
+func (h *Health) Up() bool {
+	return h.Status == HealthStatusUp
+}
+
+func (h *Health) ToJson() string {
+	b, _ := json.Marshal(h)
+	return string(b)
+}
@@ -19,8 +19,20 @@ const (
 	JOB_STATUS_ERROR            = "error"
 	JOB_STATUS_CANCEL_REQUESTED = "cancel_requested"
 	JOB_STATUS_CANCELED         = "canceled"
+
+	JobDataError = "error"
+	// JobDataRetentionDays data_retention: finished jobs older than the days are removed
+	JobDataRetentionDays = "days"
+
+	JobRetentionDaysDefault = 30
 )
 
+type SearchJob struct {
+	ListRequest
+	Types    []string
+	Statuses []string
+}
+
 type Job struct {
 	Id             string            `db:"id" json:"id"`
 	Type           string            `db:"type" json:"type"`
@@ -46,6 +58,7 @@ func (j *Job) IsValid() engine.AppError {
 
 	switch j.Type {
 	case JOB_TYPE_SYNC_FILES:
+	case JOB_TYPE_DATA_RETENTION:
 	default:
 		return engine.NewBadRequestError("model.job.is_valid.type.app_error", "id="+j.Id)
 	}
@@ -64,6 +77,32 @@ func (j *Job) IsValid() engine.AppError {
 	return nil
 }
 
+func NewJob(jobType string, data map[string]string) *Job {
+	if data == nil {
+		data = make(map[string]string)
+	}
+
+	now := GetMillis()
+
+	return &Job{
+		Id:           NewId(),
+		Type:         jobType,
+		CreateAt:     now,
+		ScheduleTime: now,
+		Status:       JOB_STATUS_PENDING,
+		Data:         data,
+	}
+}
+
+func (j *Job) IsFinished() bool {
+	switch j.Status {
+	case JOB_STATUS_SUCCESS, JOB_STATUS_ERROR, JOB_STATUS_CANCELED:
+		return true
+	}
+
+	return false
+}
+
 func (js *Job) ToJson() string {
 	b, _ := json.Marshal(js)
 	return string(b)
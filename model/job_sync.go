@@ -1,10 +1,21 @@
 package model
 
+import (
+	"encoding/json"
+
+	engine "github.com/webitel/engine/model"
+)
+
 const (
-	SyncJobRemove = "remove"
-	SyncJobSTT    = "STT"
+	SyncJobRemove    = "remove"
+	SyncJobSTT       = "STT"
+	SyncJobThumbnail = "thumbnail"
+	SyncJobMetadata  = "metadata"
+	SyncJobTranscode = "transcode"
 )
 
+var SyncJobActions = []string{SyncJobRemove, SyncJobSTT, SyncJobThumbnail, SyncJobMetadata, SyncJobTranscode}
+
 type SyncJob struct {
 	BaseFile
 	Id               int64  `json:"id" db:"id"`
@@ -16,3 +27,34 @@ type SyncJob struct {
 	Log              []byte `json:"log" db:"log"`
 	Config           []byte `json:"config" db:"config"`
 }
+
+// FileJobFilter selects stored files for a synchronizer action
+type FileJobFilter struct {
+	Channels   []string       `json:"channels"`
+	UploadedAt *FilterBetween `json:"uploaded_at"`
+	// Force processes files that already have the result
+	Force bool `json:"force"`
+}
+
+type FileJobsProgress struct {
+	Pending int64 `json:"pending" db:"pending"`
+	Active  int64 `json:"active" db:"active"`
+	Failed  int64 `json:"failed" db:"failed"`
+}
+
+func (f *FileJobFilter) IsValid() engine.AppError {
+	if f.UploadedAt == nil || f.UploadedAt.From == 0 || f.UploadedAt.To == 0 {
+		return engine.NewBadRequestError("model.file_job_filter.is_valid.uploaded_at.app_error", "uploaded_at.from and uploaded_at.to are required")
+	}
+
+	if f.UploadedAt.From > f.UploadedAt.To {
+		return engine.NewBadRequestError("model.file_job_filter.is_valid.uploaded_at.app_error", "uploaded_at.from > uploaded_at.to")
+	}
+
+	return nil
+}
+
+func (p *FileJobsProgress) ToJson() string {
+	b, _ := json.Marshal(p)
+	return string(b)
+}
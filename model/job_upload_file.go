@@ -38,6 +38,13 @@ type JobUploadFileWithProfile struct {
 	ProfileUpdatedAt *int64 `json:"profile_updated_at" db:"profile_updated_at"`
 }
 
+// UploadJobsQueue upload jobs of all instances by state, retrying - pending jobs after a failed attempt
+type UploadJobsQueue struct {
+	Pending  int64 `json:"pending" db:"pending"`
+	Active   int64 `json:"active" db:"active"`
+	Retrying int64 `json:"retrying" db:"retrying"`
+}
+
 func (self *JobUploadFile) PreSave() {
 	if self.CreatedAt == 0 {
 		self.CreatedAt = GetMillis()
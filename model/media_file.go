@@ -2,19 +2,160 @@ package model
 
 import (
 	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
 
 	engine "github.com/webitel/engine/model"
 )
 
+const (
+	SysNameMediaNormalize      = "media_normalize"
+	SysNameMediaLoudnessTarget = "media_loudness_target"
+	SysNameMediaSampleRate     = "media_sample_rate"
+	// SysNameMediaBackendProfile backend profile for new media files of the domain, local media directory if not set
+	SysNameMediaBackendProfile = "media_backend_profile"
+
+	MediaMigrationDefaultLimit = 100
+	MediaMigrationMaxLimit     = 1000
+
+	MediaBulkMaxIds      = 1000
+	MediaFolderMaxLength = 255
+
+	MediaFilePropertyOriginal    = "original"
+	MediaFilePropertyConformance = "conformance"
+)
+
 type MediaFile struct {
 	BaseFile
 	DomainRecord
 	DomainName string `json:"-" db:"domain_name"`
+	// ProfileId backend profile, nil - local media directory
+	ProfileId *int `json:"profile_id,omitempty" db:"profile_id"`
+	// Version active version of the content
+	Version int `json:"version,omitempty" db:"version"`
+	// Folder slash separated path of the folder, nil - root
+	Folder *string     `json:"folder,omitempty" db:"folder"`
+	Tags   StringArray `json:"tags,omitempty" db:"tags"`
+	// PlayCount playbacks through /sys/media
+	PlayCount      int64  `json:"play_count,omitempty" db:"play_count"`
+	LastPlayedAt   *int64 `json:"last_played_at,omitempty" db:"last_played_at"`
+	ReferenceCount int64  `json:"reference_count,omitempty" db:"reference_count"`
+	// Conformant mono PCM WAV 8/16 kHz, played by FreeSWITCH without resampling
+	Conformant *bool `json:"conformant,omitempty" db:"conformant"`
+	// Normalize overrides the domain setting media_normalize for the upload
+	Normalize *bool `json:"-" db:"-"`
+}
+
+// MediaConformance result of the upload processing, saved to properties
+type MediaConformance struct {
+	Conformant     bool   `json:"conformant"`
+	Codec          string `json:"codec,omitempty"`
+	SampleRate     int    `json:"sample_rate,omitempty"`
+	Channels       int    `json:"channels,omitempty"`
+	LoudnessTarget *int   `json:"loudness_target,omitempty"`
+	NormalizedAt   int64  `json:"normalized_at,omitempty"`
+}
+
+func NewMediaConformance(meta *MediaMetadata) *MediaConformance {
+	return &MediaConformance{
+		Conformant: meta.Codec == "pcm_s16le" && meta.Channels == 1 && (meta.SampleRate == 8000 || meta.SampleRate == 16000),
+		Codec:      meta.Codec,
+		SampleRate: meta.SampleRate,
+		Channels:   meta.Channels,
+	}
+}
+
+func (c *MediaConformance) Properties() map[string]interface{} {
+	var props map[string]interface{}
+	data, _ := json.Marshal(c)
+	json.Unmarshal(data, &props)
+
+	return props
 }
 
 type SearchMediaFile struct {
 	ListRequest
 	Ids []uint32
+	// Folder the folder with subfolders, "" - root only
+	Folder    *string
+	Tags      []string
+	MimeTypes []string
+	Size      *FilterBetween
+	Duration  *FilterBetween // seconds
+	CreatedBy []int64
+}
+
+// MediaFolder folder of the media library with the count of files
+type MediaFolder struct {
+	Name  string `json:"name" db:"name"`
+	Count int64  `json:"count" db:"count"`
+}
+
+// MediaFilesBulk changes the folder and tags of media files
+type MediaFilesBulk struct {
+	Ids []int64 `json:"ids"`
+	// Folder moves the files, "" - to the root
+	Folder     *string  `json:"folder,omitempty"`
+	AddTags    []string `json:"add_tags,omitempty"`
+	RemoveTags []string `json:"remove_tags,omitempty"`
+}
+
+func (b *MediaFilesBulk) IsValid() engine.AppError {
+	if len(b.Ids) == 0 {
+		return engine.NewBadRequestError("model.media_bulk.is_valid.ids.app_error", "ids is required")
+	}
+
+	if len(b.Ids) > MediaBulkMaxIds {
+		return engine.NewBadRequestError("model.media_bulk.is_valid.ids.app_error", fmt.Sprintf("ids=%d, max %d", len(b.Ids), MediaBulkMaxIds))
+	}
+
+	if b.Folder == nil && len(b.AddTags) == 0 && len(b.RemoveTags) == 0 {
+		return engine.NewBadRequestError("model.media_bulk.is_valid.empty.app_error", "nothing to change")
+	}
+
+	if b.Folder != nil && !IsValidMediaFolder(*b.Folder) {
+		return engine.NewBadRequestError("model.media_bulk.is_valid.folder.app_error", "folder="+*b.Folder)
+	}
+
+	return nil
+}
+
+func (b *MediaFilesBulk) Normalize() {
+	if b.Folder != nil {
+		b.Folder = NewString(NormalizeMediaFolder(*b.Folder))
+	}
+	b.AddTags = NormalizeMediaTags(b.AddTags)
+	b.RemoveTags = NormalizeMediaTags(b.RemoveTags)
+}
+
+// NormalizeMediaFolder trims spaces and slashes of the path parts
+func NormalizeMediaFolder(folder string) string {
+	parts := make([]string, 0, 2)
+	for _, p := range strings.Split(folder, "/") {
+		if p = strings.TrimSpace(p); p != "" {
+			parts = append(parts, p)
+		}
+	}
+
+	return strings.Join(parts, "/")
+}
+
+func IsValidMediaFolder(folder string) bool {
+	return len(folder) <= MediaFolderMaxLength
+}
+
+// NormalizeMediaTags lower case tags without duplicates, nil if empty
+func NormalizeMediaTags(tags []string) []string {
+	var res []string
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t != "" && !slices.Contains(res, t) {
+			res = append(res, t)
+		}
+	}
+
+	return res
 }
 
 func (MediaFile) DefaultOrder() string {
@@ -22,11 +163,11 @@ func (MediaFile) DefaultOrder() string {
 }
 
 func (a MediaFile) AllowFields() []string {
-	return []string{"id", "name", "mime_type", "size", "domain_id", "created_at", "created_by", "updated_at", "updated_by"}
+	return []string{"id", "name", "mime_type", "size", "domain_id", "created_at", "created_by", "updated_at", "updated_by", "conformant", "profile_id", "version", "folder", "tags", "play_count", "last_played_at", "reference_count"}
 }
 
 func (a MediaFile) DefaultFields() []string {
-	return []string{"id", "name", "mime_type", "size", "created_at"}
+	return []string{"id", "name", "mime_type", "size", "created_at", "conformant", "folder", "tags"}
 }
 
 func (a MediaFile) EntityName() string {
@@ -52,6 +193,10 @@ func (f *MediaFile) IsValid() engine.AppError {
 		return engine.NewBadRequestError("model.media.is_valid.domain_id.app_error", "name="+f.Name)
 	}
 
+	if f.Folder != nil && !IsValidMediaFolder(*f.Folder) {
+		return engine.NewBadRequestError("model.media.is_valid.folder.app_error", "name="+f.Name)
+	}
+
 	if f.Size == 0 {
 		//FIXME
 		//return NewBadRequestError("model.media.is_valid.size.app_error", "name="+f.Name)
@@ -60,7 +205,7 @@ func (f *MediaFile) IsValid() engine.AppError {
 }
 
 func (self MediaFile) GetStoreName() string {
-	return self.Name
+	return mediaVersionStoreName(self.Name, self.Version)
 }
 
 func (self *MediaFile) ToJson() string {
@@ -71,3 +216,31 @@ func (self *MediaFile) ToJson() string {
 func (self *MediaFile) Domain() int64 {
 	return self.DomainId
 }
+
+// GetVersion changes when the file is uploaded again, used by the read cache
+func (self *MediaFile) GetVersion() int64 {
+	return self.UpdatedAt
+}
+
+// MediaMigration moves media files of the domain to the backend profile
+type MediaMigration struct {
+	ProfileId int `json:"profile_id"`
+	Limit     int `json:"limit"`
+}
+
+type MediaMigrationResult struct {
+	Migrated int `json:"migrated"`
+	Failed   int `json:"failed"`
+}
+
+func (m *MediaMigration) IsValid() engine.AppError {
+	if m.ProfileId == 0 {
+		return engine.NewBadRequestError("model.media_migration.is_valid.profile_id.app_error", "profile_id is required")
+	}
+
+	if m.Limit < 0 || m.Limit > MediaMigrationMaxLimit {
+		return engine.NewBadRequestError("model.media_migration.is_valid.limit.app_error", fmt.Sprintf("limit=%d, max %d", m.Limit, MediaMigrationMaxLimit))
+	}
+
+	return nil
+}
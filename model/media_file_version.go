@@ -0,0 +1,72 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// MediaFileVersion revision of the media file content, the media file row holds the active one
+type MediaFileVersion struct {
+	Id          int64           `json:"id" db:"id"`
+	MediaFileId int64           `json:"media_file_id" db:"media_file_id"`
+	Version     int             `json:"version" db:"version"`
+	MimeType    string          `json:"mime_type" db:"mime_type"`
+	Size        int64           `json:"size" db:"size"`
+	Properties  StringInterface `json:"properties,omitempty" db:"properties"`
+	ProfileId   *int            `json:"profile_id,omitempty" db:"profile_id"`
+	CreatedAt   int64           `json:"created_at" db:"created_at"`
+	CreatedBy   *Lookup         `json:"created_by,omitempty" db:"created_by"`
+	Active      bool            `json:"active" db:"active"`
+}
+
+func (v *MediaFileVersion) ToJson() string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// NewMediaFileVersion revision from the content fields of the media file
+func NewMediaFileVersion(f *MediaFile) *MediaFileVersion {
+	return &MediaFileVersion{
+		MediaFileId: f.Id,
+		Version:     f.GetContentVersion(),
+		MimeType:    f.MimeType,
+		Size:        f.Size,
+		Properties:  f.Properties,
+		ProfileId:   f.ProfileId,
+		CreatedAt:   f.UpdatedAt,
+		CreatedBy:   f.UpdatedBy,
+	}
+}
+
+// MediaFile the media file with the content of the revision
+func (v *MediaFileVersion) MediaFile(f *MediaFile) *MediaFile {
+	file := *f
+	file.Version = v.Version
+	file.MimeType = v.MimeType
+	file.Size = v.Size
+	file.Properties = v.Properties
+	file.ProfileId = v.ProfileId
+	// new cache key of the revision content
+	file.UpdatedAt = v.CreatedAt
+	if file.Properties == nil {
+		file.Properties = StringInterface{}
+	}
+
+	return &file
+}
+
+// GetContentVersion files uploaded before versioning are the first version
+func (f *MediaFile) GetContentVersion() int {
+	if f.Version < 1 {
+		return 1
+	}
+	return f.Version
+}
+
+// mediaVersionStoreName the first version keeps the name, so the content of existing files stays in place
+func mediaVersionStoreName(name string, version int) string {
+	if version <= 1 {
+		return name
+	}
+	return fmt.Sprintf("v%d_%s", version, name)
+}
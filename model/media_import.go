@@ -0,0 +1,63 @@
+package model
+
+import (
+	"strings"
+)
+
+const (
+	MediaImportManifestName = "manifest.json"
+	MediaImportMaxEntries   = 500
+)
+
+// MediaImport options of the ZIP import, applied to the entries without the manifest values
+type MediaImport struct {
+	// Atomic creates nothing if any entry fails
+	Atomic    bool
+	Folder    *string
+	Tags      []string
+	Normalize *bool
+}
+
+// MediaImportManifest optional manifest.json in the root of the archive
+type MediaImportManifest struct {
+	Files []*MediaImportManifestFile `json:"files"`
+}
+
+type MediaImportManifestFile struct {
+	// File path of the entry in the archive
+	File     string   `json:"file"`
+	Name     string   `json:"name,omitempty"`
+	MimeType string   `json:"mime_type,omitempty"`
+	Folder   *string  `json:"folder,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+type MediaImportItem struct {
+	File  string `json:"file"`
+	Name  string `json:"name,omitempty"`
+	Id    int64  `json:"id,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type MediaImportResult struct {
+	Created int `json:"created"`
+	Failed  int `json:"failed"`
+	// RolledBack the atomic import removed the created files
+	RolledBack bool               `json:"rolled_back,omitempty"`
+	Items      []*MediaImportItem `json:"items"`
+}
+
+// IsMediaImportEntry skips directories and service files of archivers
+func IsMediaImportEntry(name string) bool {
+	if strings.HasSuffix(name, "/") || strings.HasPrefix(name, "__MACOSX/") {
+		return false
+	}
+
+	base := name[strings.LastIndex(name, "/")+1:]
+	return base != "" && !strings.HasPrefix(base, ".")
+}
+
+// IsMediaMimeType media files are played by the telephony
+func IsMediaMimeType(mime string) bool {
+	return strings.HasPrefix(mime, "audio/") || strings.HasPrefix(mime, "video/")
+}
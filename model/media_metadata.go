@@ -0,0 +1,55 @@
+package model
+
+const (
+	FilePropertyDuration   = "duration"
+	FilePropertyCodec      = "codec"
+	FilePropertyBitRate    = "bit_rate"
+	FilePropertyChannels   = "channels"
+	FilePropertySampleRate = "sample_rate"
+	FilePropertyWidth      = "width"
+	FilePropertyHeight     = "height"
+)
+
+// MediaMetadata technical information of an audio or video file, stored in the file properties
+type MediaMetadata struct {
+	Duration   float64 `json:"duration,omitempty"` // seconds
+	Codec      string  `json:"codec,omitempty"`
+	BitRate    int64   `json:"bit_rate,omitempty"`
+	Channels   int     `json:"channels,omitempty"`
+	SampleRate int     `json:"sample_rate,omitempty"`
+	Width      int     `json:"width,omitempty"`
+	Height     int     `json:"height,omitempty"`
+}
+
+// MediaMetadataJob selects stored audio and video files for metadata extraction
+type MediaMetadataJob struct {
+	FileJobFilter
+}
+
+// Properties returns only known values
+func (m *MediaMetadata) Properties() StringInterface {
+	props := StringInterface{}
+	if m.Duration > 0 {
+		props[FilePropertyDuration] = m.Duration
+	}
+	if m.Codec != "" {
+		props[FilePropertyCodec] = m.Codec
+	}
+	if m.BitRate > 0 {
+		props[FilePropertyBitRate] = m.BitRate
+	}
+	if m.Channels > 0 {
+		props[FilePropertyChannels] = m.Channels
+	}
+	if m.SampleRate > 0 {
+		props[FilePropertySampleRate] = m.SampleRate
+	}
+	if m.Width > 0 {
+		props[FilePropertyWidth] = m.Width
+	}
+	if m.Height > 0 {
+		props[FilePropertyHeight] = m.Height
+	}
+
+	return props
+}
@@ -0,0 +1,42 @@
+package model
+
+import (
+	engine "github.com/webitel/engine/model"
+)
+
+const (
+	MediaReferenceMaxLength = 128
+)
+
+// MediaFileReference object of other service that uses the media file, e.g. source "flow" and the schema id
+type MediaFileReference struct {
+	MediaFileId int64  `json:"media_file_id" db:"media_file_id"`
+	Source      string `json:"source" db:"source"`
+	ObjectId    string `json:"object_id" db:"object_id"`
+	CreatedAt   int64  `json:"created_at,omitempty" db:"created_at"`
+}
+
+type MediaFileUsage struct {
+	PlayCount    int64                 `json:"play_count"`
+	LastPlayedAt *int64                `json:"last_played_at,omitempty"`
+	References   []*MediaFileReference `json:"references"`
+}
+
+// MediaPlayback playbacks of the media file since the last flush
+type MediaPlayback struct {
+	Id           int64
+	Count        int64
+	LastPlayedAt int64
+}
+
+func (r *MediaFileReference) IsValid() engine.AppError {
+	if r.Source == "" || len(r.Source) > MediaReferenceMaxLength {
+		return engine.NewBadRequestError("model.media_reference.is_valid.source.app_error", "source="+r.Source)
+	}
+
+	if r.ObjectId == "" || len(r.ObjectId) > MediaReferenceMaxLength {
+		return engine.NewBadRequestError("model.media_reference.is_valid.object_id.app_error", "object_id="+r.ObjectId)
+	}
+
+	return nil
+}
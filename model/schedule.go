@@ -17,6 +17,7 @@ type Schedule struct {
 	Enabled        *bool   `db:"enabled" json:"enabled"`
 }
 
+// NextTime next run of the schedule after t, in milliseconds
 func (s *Schedule) NextTime(t time.Time) int64 {
 	if s.TimeZone != nil {
 		if loc, _ := time.LoadLocation(*s.TimeZone); loc != nil {
@@ -30,5 +31,10 @@ func (s *Schedule) NextTime(t time.Time) int64 {
 		return 0
 	}
 
-	return res.Next(t).UnixNano() / int64(time.Millisecond*1000)
+	next := res.Next(t)
+	if next.IsZero() {
+		return 0
+	}
+
+	return next.UnixNano() / int64(time.Millisecond)
 }
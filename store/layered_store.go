@@ -2,6 +2,8 @@ package store
 
 import (
 	"context"
+
+	"github.com/webitel/storage/model"
 )
 
 type LayeredStoreDatabaseLayer interface {
@@ -84,3 +86,11 @@ func (s *LayeredStore) FilePolicies() FilePoliciesStore {
 func (s *LayeredStore) SystemSettings() SystemSettingsStore {
 	return s.DatabaseLayer.SystemSettings()
 }
+
+func (s *LayeredStore) FileVariant() FileVariantStore {
+	return s.DatabaseLayer.FileVariant()
+}
+
+func (s *LayeredStore) Ping(ctx context.Context) []*model.HealthCheck {
+	return s.DatabaseLayer.Ping(ctx)
+}
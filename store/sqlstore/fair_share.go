@@ -0,0 +1,34 @@
+package sqlstore
+
+import (
+	"github.com/go-gorp/gorp"
+	"github.com/webitel/storage/model"
+)
+
+// claimFair runs the claim query of the queue. With the domain cap the claims of all instances are serialized
+// by an advisory lock of the queue: otherwise instances polling at the same time count the active jobs
+// before each other's claims commit, and each takes the free slots of the domain.
+func claimFair(db *gorp.DbMap, queue string, fair *model.FairShare, claim func(db gorp.SqlExecutor) error) error {
+	if fair.DomainMaxActive <= 0 {
+		return claim(db)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// a separate statement: in read committed the claim query takes its snapshot after the lock is acquired
+	if _, err = tx.Exec(`select pg_advisory_xact_lock(hashtext(:Queue))`, map[string]interface{}{
+		"Queue": queue,
+	}); err != nil {
+		return err
+	}
+
+	if err = claim(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
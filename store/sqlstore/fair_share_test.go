@@ -0,0 +1,135 @@
+package sqlstore
+
+import (
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/webitel/storage/model"
+)
+
+// testSupplier connects to the database of STORAGE_TEST_DATA_SOURCE with the storage schema,
+// the tests are skipped without it
+func testSupplier(t *testing.T) *SqlSupplier {
+	dsn := os.Getenv("STORAGE_TEST_DATA_SOURCE")
+	if dsn == "" {
+		t.Skip("STORAGE_TEST_DATA_SOURCE is not set")
+	}
+
+	return NewSqlSupplier(model.SqlSettings{
+		DriverName:                  model.NewString(model.DATABASE_DRIVER_POSTGRES),
+		DataSource:                  &dsn,
+		MaxIdleConns:                model.NewInt(1),
+		MaxOpenConns:                model.NewInt(2),
+		ConnMaxLifetimeMilliseconds: model.NewInt(60000),
+		QueryTimeout:                model.NewInt(10),
+	})
+}
+
+func TestUploadJobsFairShare(t *testing.T) {
+	ss := testSupplier(t)
+	instance := "test-" + model.NewId()[:8]
+
+	t.Cleanup(func() {
+		ss.GetMaster().Exec(`delete from storage.upload_file_jobs where instance = :Instance`, map[string]interface{}{
+			"Instance": instance,
+		})
+	})
+
+	jobs := []struct {
+		name    string
+		domain  int64
+		channel string
+	}{
+		{"d1_1", 1, model.UploadFileChannelChat},
+		{"d1_2", 1, model.UploadFileChannelChat},
+		{"d1_3", 1, model.UploadFileChannelChat},
+		{"d1_call", 1, model.UploadFileChannelCall},
+		{"d2_1", 2, model.UploadFileChannelChat},
+		{"d2_2", 2, model.UploadFileChannelChat},
+	}
+
+	for i, j := range jobs {
+		_, err := ss.GetMaster().Exec(`insert into storage.upload_file_jobs (name, uuid, mime_type, size, instance, created_at, updated_at, domain_id, channel)
+values (:Name, :Uuid, 'audio/wav', 1, :Instance, :CreatedAt, :CreatedAt, :DomainId, :Channel)`, map[string]interface{}{
+			"Name":      j.name,
+			"Uuid":      model.NewId(),
+			"Instance":  instance,
+			"CreatedAt": int64(1000 + i),
+			"DomainId":  j.domain,
+			"Channel":   j.channel,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fetch := func(limit int, fair *model.FairShare) []string {
+		res := <-ss.UploadJob().UpdateWithProfile(limit, instance, 0, true, fair)
+		if res.Err != nil {
+			t.Fatal(res.Err)
+		}
+
+		var names []string
+		for _, j := range res.Data.([]*model.JobUploadFileWithProfile) {
+			names = append(names, j.Name)
+		}
+		sort.Strings(names)
+
+		return names
+	}
+
+	expect := func(names []string, expected ...string) {
+		t.Helper()
+		if len(names) != len(expected) {
+			t.Fatalf("fetched %v, expected %v", names, expected)
+		}
+		for i := range names {
+			if names[i] != expected[i] {
+				t.Fatalf("fetched %v, expected %v", names, expected)
+			}
+		}
+	}
+
+	fair := &model.FairShare{PriorityChannels: []string{model.UploadFileChannelCall}}
+
+	// the call job of the first domain goes first despite being the newest, the second domain is not delayed
+	expect(fetch(2, fair), "d1_call", "d2_1")
+	expect(fetch(2, fair), "d1_1", "d2_2")
+	expect(fetch(10, fair), "d1_2", "d1_3")
+}
+
+func TestUploadJobsDomainMaxActive(t *testing.T) {
+	ss := testSupplier(t)
+	instance := "test-" + model.NewId()[:8]
+
+	t.Cleanup(func() {
+		ss.GetMaster().Exec(`delete from storage.upload_file_jobs where instance = :Instance`, map[string]interface{}{
+			"Instance": instance,
+		})
+	})
+
+	for i := 0; i < 4; i++ {
+		_, err := ss.GetMaster().Exec(`insert into storage.upload_file_jobs (name, uuid, mime_type, size, instance, created_at, updated_at, domain_id, channel)
+values ('f', :Uuid, 'audio/wav', 1, :Instance, :CreatedAt, :CreatedAt, 3, :Channel)`, map[string]interface{}{
+			"Uuid":      model.NewId(),
+			"Instance":  instance,
+			"CreatedAt": int64(1000 + i),
+			"Channel":   model.UploadFileChannelChat,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fair := &model.FairShare{DomainMaxActive: 2}
+	for i, expected := range []int{2, 0} {
+		res := <-ss.UploadJob().UpdateWithProfile(10, instance, 0, true, fair)
+		if res.Err != nil {
+			t.Fatal(res.Err)
+		}
+		if n := len(res.Data.([]*model.JobUploadFileWithProfile)); n != expected {
+			t.Fatalf("fetch %d: %d jobs, expected %d", i, n, expected)
+		}
+	}
+}
@@ -22,9 +22,13 @@ func NewSqlFilePoliciesStore(sqlStore SqlStore) store.FilePoliciesStore {
 func (s *SqlFilePoliciesStore) Create(ctx context.Context, domainId int64, policy *model.FilePolicy) (*model.FilePolicy, engine.AppError) {
 	err := s.GetMaster().WithContext(ctx).SelectOne(&policy, `with p as (
     insert into storage.file_policies (domain_id, created_at, created_by, updated_at, updated_by, name, enabled, mime_types,
-                                       speed_download, speed_upload, description, channels, retention_days, max_upload_size)
+                                       speed_download, speed_upload, description, channels, retention_days, max_upload_size,
+                                       allow_extensions, deny_extensions, attachment_mime_types, content_security_policy,
+                                       strip_metadata, transcode_codec, transcode_bit_rate, transcode_after_days)
     values (:DomainId, :CreatedAt, :CreatedBy, :UpdatedAt, :UpdatedBy, :Name, :Enabled, :MimeTypes,
-            :SpeedDownload, :SpeedUpload, :Description, :Channels, :RetentionDays, :MaxUploadSize)
+            :SpeedDownload, :SpeedUpload, :Description, :Channels, :RetentionDays, :MaxUploadSize,
+            :AllowExtensions, :DenyExtensions, :AttachmentMimeTypes, :ContentSecurityPolicy,
+            :StripMetadata, :TranscodeCodec, :TranscodeBitRate, :TranscodeAfterDays)
    returning *
 )
 SELECT p.id,
@@ -40,7 +44,15 @@ SELECT p.id,
        p.speed_download,
        p.speed_upload,
        p.retention_days,
-       p.max_upload_size
+       p.max_upload_size,
+       p.allow_extensions,
+       p.deny_extensions,
+       p.attachment_mime_types,
+       p.content_security_policy,
+       p.strip_metadata,
+       p.transcode_codec,
+       p.transcode_bit_rate,
+       p.transcode_after_days
 FROM p
          LEFT JOIN directory.wbt_user c ON c.id = p.created_by
          LEFT JOIN directory.wbt_user u ON u.id = p.updated_by;`, map[string]interface{}{
@@ -58,6 +70,15 @@ FROM p
 		"Channels":      pq.Array(policy.Channels),
 		"RetentionDays": policy.RetentionDays,
 		"MaxUploadSize": policy.MaxUploadSize,
+
+		"AllowExtensions":       pq.Array(policy.AllowExtensions),
+		"DenyExtensions":        pq.Array(policy.DenyExtensions),
+		"AttachmentMimeTypes":   pq.Array(policy.AttachmentMimeTypes),
+		"ContentSecurityPolicy": policy.ContentSecurityPolicy,
+		"StripMetadata":         policy.StripMetadata,
+		"TranscodeCodec":        policy.TranscodeCodec,
+		"TranscodeBitRate":      policy.TranscodeBitRate,
+		"TranscodeAfterDays":    policy.TranscodeAfterDays,
 	})
 
 	if err != nil {
@@ -104,7 +125,15 @@ func (s *SqlFilePoliciesStore) Get(ctx context.Context, domainId int64, id int32
        p.speed_download,
        p.speed_upload,
        p.retention_days,
-       p.max_upload_size
+       p.max_upload_size,
+       p.allow_extensions,
+       p.deny_extensions,
+       p.attachment_mime_types,
+       p.content_security_policy,
+       p.strip_metadata,
+       p.transcode_codec,
+       p.transcode_bit_rate,
+       p.transcode_after_days
 FROM storage.file_policies p
          LEFT JOIN directory.wbt_user c ON c.id = p.created_by
          LEFT JOIN directory.wbt_user u ON u.id = p.updated_by
@@ -134,7 +163,15 @@ func (s *SqlFilePoliciesStore) Update(ctx context.Context, domainId int64, polic
             mime_types = :MimeTypes,
             channels = :Channels,
 			retention_days = :RetentionDays,
-			max_upload_size = :MaxUploadSize
+			max_upload_size = :MaxUploadSize,
+			allow_extensions = :AllowExtensions,
+			deny_extensions = :DenyExtensions,
+			attachment_mime_types = :AttachmentMimeTypes,
+			content_security_policy = :ContentSecurityPolicy,
+			strip_metadata = :StripMetadata,
+			transcode_codec = :TranscodeCodec,
+			transcode_bit_rate = :TranscodeBitRate,
+			transcode_after_days = :TranscodeAfterDays
         where domain_id = :DomainId and id = :Id
 		returning *
 )
@@ -151,22 +188,38 @@ SELECT p.id,
        p.speed_download,
        p.speed_upload,
 	   p.retention_days,
-       p.max_upload_size
+       p.max_upload_size,
+       p.allow_extensions,
+       p.deny_extensions,
+       p.attachment_mime_types,
+       p.content_security_policy,
+       p.strip_metadata,
+       p.transcode_codec,
+       p.transcode_bit_rate,
+       p.transcode_after_days
 FROM p
          LEFT JOIN directory.wbt_user c ON c.id = p.created_by
          LEFT JOIN directory.wbt_user u ON u.id = p.updated_by`, map[string]interface{}{
 		"UpdatedAt": policy.UpdatedAt,
 		"UpdatedBy": policy.UpdatedBy.GetSafeId(),
 
-		"Enabled":       policy.Enabled,
-		"Name":          policy.Name,
-		"Description":   policy.Description,
-		"SpeedUpload":   policy.SpeedUpload,
-		"SpeedDownload": policy.SpeedDownload,
-		"MimeTypes":     pq.Array(policy.MimeTypes),
-		"Channels":      pq.Array(policy.Channels),
-		"RetentionDays": policy.RetentionDays,
-		"MaxUploadSize": policy.MaxUploadSize,
+		"Enabled":               policy.Enabled,
+		"Name":                  policy.Name,
+		"Description":           policy.Description,
+		"SpeedUpload":           policy.SpeedUpload,
+		"SpeedDownload":         policy.SpeedDownload,
+		"MimeTypes":             pq.Array(policy.MimeTypes),
+		"Channels":              pq.Array(policy.Channels),
+		"RetentionDays":         policy.RetentionDays,
+		"MaxUploadSize":         policy.MaxUploadSize,
+		"AllowExtensions":       pq.Array(policy.AllowExtensions),
+		"DenyExtensions":        pq.Array(policy.DenyExtensions),
+		"AttachmentMimeTypes":   pq.Array(policy.AttachmentMimeTypes),
+		"ContentSecurityPolicy": policy.ContentSecurityPolicy,
+		"StripMetadata":         policy.StripMetadata,
+		"TranscodeCodec":        policy.TranscodeCodec,
+		"TranscodeBitRate":      policy.TranscodeBitRate,
+		"TranscodeAfterDays":    policy.TranscodeAfterDays,
 
 		"DomainId": domainId,
 		"Id":       policy.Id,
@@ -224,7 +277,9 @@ func (s *SqlFilePoliciesStore) ChangePosition(ctx context.Context, domainId int6
 func (s *SqlFilePoliciesStore) AllByDomainId(ctx context.Context, domainId int64) ([]model.FilePolicy, engine.AppError) {
 	var list []model.FilePolicy
 	_, err := s.GetReplica().WithContext(ctx).Select(&list, `select id, channels, mime_types, p.name, p.speed_download,
-       p.speed_upload, p.retention_days, p.max_upload_size, max(updated_at) over (), name
+       p.speed_upload, p.retention_days, p.max_upload_size, p.allow_extensions, p.deny_extensions,
+       p.attachment_mime_types, p.content_security_policy, p.strip_metadata,
+       max(updated_at) over (), name
 from storage.file_policies p
 where p.domain_id = :DomainId
     and p.enabled
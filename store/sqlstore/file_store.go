@@ -118,6 +118,69 @@ from del`, jobId, profileId, properties.ToJson(), retentionUntil)
 	})
 }
 
+func (self SqlFileStore) SetThumbnail(ctx context.Context, id int64, thumbnail *model.Thumbnail) engine.AppError {
+	_, err := self.GetMaster().WithContext(ctx).Exec(`update storage.files
+set thumbnail = :Thumbnail::jsonb
+where id = :Id`, map[string]interface{}{
+		"Id":        id,
+		"Thumbnail": thumbnail.ToJson(),
+	})
+
+	if err != nil {
+		return engine.NewCustomCodeError("store.sql_file.set_thumbnail.app_error", fmt.Sprintf("id=%d, %s", id, err.Error()), extractCodeFromErr(err))
+	}
+
+	return nil
+}
+
+func (self SqlFileStore) MergeProperties(ctx context.Context, id int64, properties model.StringInterface) engine.AppError {
+	_, err := self.GetMaster().WithContext(ctx).Exec(`update storage.files
+set properties = coalesce(properties, '{}'::jsonb) || :Props::jsonb
+where id = :Id`, map[string]interface{}{
+		"Id":    id,
+		"Props": properties.ToJson(),
+	})
+
+	if err != nil {
+		return engine.NewCustomCodeError("store.sql_file.merge_properties.app_error", fmt.Sprintf("id=%d, %s", id, err.Error()), extractCodeFromErr(err))
+	}
+
+	return nil
+}
+
+// ReplaceContent points the file to new content, oldName protects from concurrent replacement
+func (self SqlFileStore) ReplaceContent(ctx context.Context, id int64, oldName string, file *model.File) engine.AppError {
+	res, err := self.GetMaster().WithContext(ctx).Exec(`update storage.files
+set name = :Name,
+    view_name = :ViewName,
+    mime_type = :Mime,
+    size = :Size,
+    sha256sum = :SHA256Sum,
+    properties = :Props::jsonb
+where id = :Id
+    and name = :OldName
+    and not coalesce(removed, false)`, map[string]interface{}{
+		"Id":        id,
+		"OldName":   oldName,
+		"Name":      file.Name,
+		"ViewName":  file.ViewName,
+		"Mime":      file.MimeType,
+		"Size":      file.Size,
+		"SHA256Sum": file.SHA256Sum,
+		"Props":     file.Properties.ToJson(),
+	})
+
+	if err != nil {
+		return engine.NewCustomCodeError("store.sql_file.replace_content.app_error", fmt.Sprintf("id=%d, %s", id, err.Error()), extractCodeFromErr(err))
+	}
+
+	if cnt, _ := res.RowsAffected(); cnt == 0 {
+		return engine.NewNotFoundError("store.sql_file.replace_content.not_found", fmt.Sprintf("id=%d, name=%s", id, oldName))
+	}
+
+	return nil
+}
+
 // get permissions of the call record for user
 func (self SqlFileStore) CheckCallRecordPermissions(ctx context.Context, fileId int, userId int64, domainId int64, groups []int) (bool, engine.AppError) {
 
@@ -209,7 +272,7 @@ FROM storage.files f
 	})
 
 	if err != nil {
-		return nil, engine.NewCustomCodeError("store.sql_file.get_by_uuid_with_profile.app_error", fmt.Sprintf("Uuid=%d %s", uuid, err.Error()), extractCodeFromErr(err))
+		return nil, engine.NewCustomCodeError("store.sql_file.get_by_uuid_with_profile.app_error", fmt.Sprintf("Uuid=%s %s", uuid, err.Error()), extractCodeFromErr(err))
 	}
 	return file, nil
 }
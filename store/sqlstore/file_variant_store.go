@@ -0,0 +1,99 @@
+package sqlstore
+
+import (
+	"context"
+	"fmt"
+
+	engine "github.com/webitel/engine/model"
+	"github.com/webitel/storage/model"
+	"github.com/webitel/storage/store"
+)
+
+type SqlFileVariantStore struct {
+	SqlStore
+}
+
+func NewSqlFileVariantStore(sqlStore SqlStore) store.FileVariantStore {
+	us := &SqlFileVariantStore{sqlStore}
+
+	return us
+}
+
+func (s *SqlFileVariantStore) Get(ctx context.Context, fileId int64, key string) (*model.FileVariant, engine.AppError) {
+	var variant *model.FileVariant
+	err := s.GetReplica().WithContext(ctx).SelectOne(&variant, `select v.id, v.file_id, v.domain_id, v.uuid, v.key, v.name, v.mime_type,
+       v.size, v.properties, v.channel, v.sha256sum, v.created_at
+from storage.file_variants v
+where v.file_id = :FileId
+    and v.key = :Key`, map[string]interface{}{
+		"FileId": fileId,
+		"Key":    key,
+	})
+
+	if err != nil {
+		return nil, engine.NewCustomCodeError("store.sql_file_variant.get.app_error", fmt.Sprintf("file_id=%d, key=%s, %s", fileId, key, err.Error()), extractCodeFromErr(err))
+	}
+
+	return variant, nil
+}
+
+func (s *SqlFileVariantStore) Save(ctx context.Context, variant *model.FileVariant) (*model.FileVariant, engine.AppError) {
+	id, err := s.GetMaster().WithContext(ctx).SelectInt(`insert into storage.file_variants (file_id, domain_id, uuid, key, name, mime_type, size,
+                                   properties, channel, sha256sum, created_at)
+values (:FileId, :DomainId, :Uuid, :Key, :Name, :Mime, :Size, :Props::jsonb, :Channel, :SHA256Sum, :CreatedAt)
+on conflict (file_id, key) do update
+    set name = excluded.name,
+        mime_type = excluded.mime_type,
+        size = excluded.size,
+        properties = excluded.properties,
+        sha256sum = excluded.sha256sum,
+        created_at = excluded.created_at
+returning id`, map[string]interface{}{
+		"FileId":    variant.FileId,
+		"DomainId":  variant.DomainId,
+		"Uuid":      variant.Uuid,
+		"Key":       variant.Key,
+		"Name":      variant.Name,
+		"Mime":      variant.MimeType,
+		"Size":      variant.Size,
+		"Props":     variant.Properties.ToJson(),
+		"Channel":   variant.Channel,
+		"SHA256Sum": variant.SHA256Sum,
+		"CreatedAt": variant.CreatedAt,
+	})
+
+	if err != nil {
+		return nil, engine.NewCustomCodeError("store.sql_file_variant.save.app_error", fmt.Sprintf("file_id=%d, key=%s, %s", variant.FileId, variant.Key, err.Error()), extractCodeFromErr(err))
+	}
+
+	variant.Id = id
+	return variant, nil
+}
+
+func (s *SqlFileVariantStore) GetAllByFileId(ctx context.Context, fileId int64) ([]*model.FileVariant, engine.AppError) {
+	var list []*model.FileVariant
+	_, err := s.GetMaster().WithContext(ctx).Select(&list, `select v.id, v.file_id, v.domain_id, v.uuid, v.key, v.name, v.mime_type,
+       v.size, v.properties, v.channel, v.sha256sum, v.created_at
+from storage.file_variants v
+where v.file_id = :FileId`, map[string]interface{}{
+		"FileId": fileId,
+	})
+
+	if err != nil {
+		return nil, engine.NewCustomCodeError("store.sql_file_variant.get_all.app_error", fmt.Sprintf("file_id=%d, %s", fileId, err.Error()), extractCodeFromErr(err))
+	}
+
+	return list, nil
+}
+
+func (s *SqlFileVariantStore) DeleteByFileId(ctx context.Context, fileId int64) engine.AppError {
+	_, err := s.GetMaster().WithContext(ctx).Exec(`delete from storage.file_variants where file_id = :FileId`, map[string]interface{}{
+		"FileId": fileId,
+	})
+
+	if err != nil {
+		return engine.NewCustomCodeError("store.sql_file_variant.delete.app_error", fmt.Sprintf("file_id=%d, %s", fileId, err.Error()), extractCodeFromErr(err))
+	}
+
+	return nil
+}
@@ -1,9 +1,11 @@
 package sqlstore
 
 import (
+	"context"
 	"database/sql"
 
 	"github.com/go-gorp/gorp"
+	"github.com/lib/pq"
 	engine "github.com/webitel/engine/model"
 	"github.com/webitel/storage/model"
 	"github.com/webitel/storage/store"
@@ -323,3 +325,108 @@ func (jss SqlJobStore) Delete(id string) store.StoreChannel {
 		}
 	})
 }
+
+func (jss SqlJobStore) Search(ctx context.Context, search *model.SearchJob) ([]*model.Job, engine.AppError) {
+	var jobs []*model.Job
+
+	_, err := jss.GetReplica().WithContext(ctx).Select(&jobs, `select *
+from storage.jobs j
+where (:Types::varchar[] isnull or j.type = any(:Types::varchar[]))
+  and (:Statuses::varchar[] isnull or j.status = any(:Statuses::varchar[]))
+order by j.create_at desc
+limit :Limit offset :Offset`, map[string]interface{}{
+		"Types":    pq.Array(search.Types),
+		"Statuses": pq.Array(search.Statuses),
+		"Limit":    search.GetLimit(),
+		"Offset":   search.GetOffset(),
+	})
+
+	if err != nil {
+		return nil, engine.NewCustomCodeError("store.sql_job.search.app_error", err.Error(), extractCodeFromErr(err))
+	}
+
+	return jobs, nil
+}
+
+// CreateScheduled creates the job of the schedule once for the schedule time, false - created by other instance.
+// The check and the insert run under an advisory lock of the schedule: storage.jobs has no unique index on
+// (schedule_id, schedule_time), and in read committed the check of a single statement does not see a row
+// inserted by a concurrent transaction.
+func (jss SqlJobStore) CreateScheduled(ctx context.Context, job *model.Job) (bool, engine.AppError) {
+	tx, err := jss.GetMaster().Begin()
+	if err != nil {
+		return false, engine.NewCustomCodeError("store.sql_job.create_scheduled.app_error", "id="+job.Id+", "+err.Error(), extractCodeFromErr(err))
+	}
+	defer tx.Rollback()
+
+	_, err = tx.WithContext(ctx).Exec(`select pg_advisory_xact_lock(hashtext('storage.jobs.schedule'), hashtext(:ScheduleId::text))`,
+		map[string]interface{}{
+			"ScheduleId": job.ScheduleId,
+		})
+	if err != nil {
+		return false, engine.NewCustomCodeError("store.sql_job.create_scheduled.app_error", "id="+job.Id+", "+err.Error(), extractCodeFromErr(err))
+	}
+
+	res, err := tx.WithContext(ctx).Exec(`insert into storage.jobs (id, type, priority, schedule_id, schedule_time, create_at, start_at,
+                          last_activity_at, status, progress, data)
+select :Id, :Type, :Priority, :ScheduleId, :ScheduleTime, :CreatedAt, :StartAt, :LastActivityAt, :Status, :Progress, :Data
+where not exists(select 1 from storage.jobs j where j.schedule_id = :ScheduleId and j.schedule_time = :ScheduleTime)
+on conflict do nothing`, map[string]interface{}{
+		"Id":             job.Id,
+		"Type":           job.Type,
+		"Priority":       job.Priority,
+		"ScheduleId":     job.ScheduleId,
+		"ScheduleTime":   job.ScheduleTime,
+		"CreatedAt":      job.CreateAt,
+		"StartAt":        job.StartAt,
+		"LastActivityAt": job.LastActivityAt,
+		"Status":         job.Status,
+		"Progress":       job.Progress,
+		"Data":           model.MapToJson(job.Data),
+	})
+	if err != nil {
+		return false, engine.NewCustomCodeError("store.sql_job.create_scheduled.app_error", "id="+job.Id+", "+err.Error(), extractCodeFromErr(err))
+	}
+
+	rows, _ := res.RowsAffected()
+
+	if err = tx.Commit(); err != nil {
+		return false, engine.NewCustomCodeError("store.sql_job.create_scheduled.app_error", "id="+job.Id+", "+err.Error(), extractCodeFromErr(err))
+	}
+
+	return rows == 1, nil
+}
+
+// GetLastScheduleTime schedule time of the last job created by the schedule, 0 - no jobs
+func (jss SqlJobStore) GetLastScheduleTime(ctx context.Context, scheduleId int64) (int64, engine.AppError) {
+	t, err := jss.GetMaster().WithContext(ctx).SelectInt(`select coalesce(max(j.schedule_time), 0)
+from storage.jobs j
+where j.schedule_id = :ScheduleId`, map[string]interface{}{
+		"ScheduleId": scheduleId,
+	})
+
+	if err != nil {
+		return 0, engine.NewCustomCodeError("store.sql_job.get_last_schedule_time.app_error", err.Error(), extractCodeFromErr(err))
+	}
+
+	return t, nil
+}
+
+// DeleteFinished removes finished jobs with the last activity before the time
+func (jss SqlJobStore) DeleteFinished(ctx context.Context, before int64) (int64, engine.AppError) {
+	res, err := jss.GetMaster().WithContext(ctx).Exec(`delete
+from storage.jobs j
+where j.status = any(:Statuses::varchar[])
+  and j.last_activity_at < :Before`, map[string]interface{}{
+		"Statuses": pq.Array([]string{model.JOB_STATUS_SUCCESS, model.JOB_STATUS_ERROR, model.JOB_STATUS_CANCELED}),
+		"Before":   before,
+	})
+
+	if err != nil {
+		return 0, engine.NewCustomCodeError("store.sql_job.delete_finished.app_error", err.Error(), extractCodeFromErr(err))
+	}
+
+	cnt, _ := res.RowsAffected()
+
+	return cnt, nil
+}
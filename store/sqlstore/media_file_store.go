@@ -1,6 +1,7 @@
 package sqlstore
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"net/http"
@@ -33,12 +34,16 @@ func (s *SqlMediaFileStore) Create(file *model.MediaFile) (*model.MediaFile, eng
                                      properties,
                                      instance,
                                      created_by,
-                                     created_at, updated_by, updated_at, domain_id)
-    values (:Name, :Size, :Mime, :Properties, :Instance, :CreatedBy, :CreatedAt, :UpdatedBy, :UpdatedAt, :DomainId)
+                                     created_at, updated_by, updated_at, domain_id, profile_id, folder, tags)
+    values (:Name, :Size, :Mime, :Properties, :Instance, :CreatedBy, :CreatedAt, :UpdatedBy, :UpdatedAt, :DomainId, :ProfileId,
+            :Folder, :Tags)
     returning *
 )
 select f.id, f.name, f.created_at, call_center.cc_get_lookup(c.id, c.name) created_by,
-       f.updated_at, call_center.cc_get_lookup(u.id, u.name) updated_by, f.mime_type, f.size, properties, d.name as domain_name
+       f.updated_at, call_center.cc_get_lookup(u.id, u.name) updated_by, f.mime_type, f.size, properties, d.name as domain_name,
+       (f.properties -> 'conformance' ->> 'conformant')::bool as conformant, f.profile_id, f.version, f.folder, f.tags,
+       coalesce(f.play_count, 0) as play_count, f.last_played_at,
+       (select count(*) from storage.media_file_references r where r.media_file_id = f.id) as reference_count
 from f
     left join directory.wbt_user c on f.created_by = c.id
     left join directory.wbt_user u on f.updated_by = u.id
@@ -53,6 +58,9 @@ from f
 		"UpdatedBy":  file.UpdatedBy.GetSafeId(),
 		"UpdatedAt":  file.UpdatedAt,
 		"DomainId":   file.DomainId,
+		"ProfileId":  file.ProfileId,
+		"Folder":     file.Folder,
+		"Tags":       pq.Array(file.Tags),
 	})
 
 	if err != nil {
@@ -70,15 +78,43 @@ func (s *SqlMediaFileStore) GetAllPage(domainId int64, search *model.SearchMedia
 	var files []*model.MediaFile
 
 	f := map[string]interface{}{
-		"DomainId": domainId,
-		"Ids":      pq.Array(search.Ids),
-		"Q":        search.GetQ(),
+		"DomainId":     domainId,
+		"Ids":          pq.Array(search.Ids),
+		"Q":            search.GetQ(),
+		"Folder":       search.Folder,
+		"Tags":         pq.Array(search.Tags),
+		"MimeTypes":    pq.Array(search.MimeTypes),
+		"SizeFrom":     nil,
+		"SizeTo":       nil,
+		"DurationFrom": nil,
+		"DurationTo":   nil,
+		"CreatedBy":    pq.Array(search.CreatedBy),
+	}
+
+	if search.Size != nil {
+		f["SizeFrom"] = search.Size.From
+		f["SizeTo"] = search.Size.To
+	}
+
+	if search.Duration != nil {
+		f["DurationFrom"] = search.Duration.From
+		f["DurationTo"] = search.Duration.To
 	}
 
 	err := s.ListQuery(&files, search.ListRequest,
 		`domain_id = :DomainId
 				and (:Ids::int[] isnull or id = any(:Ids))
-				and (:Q::varchar isnull or (name ilike :Q::varchar ))`,
+				and (:Q::varchar isnull or (name ilike :Q::varchar ))
+				and (:Folder::varchar isnull or (:Folder::varchar = '' and folder isnull)
+					or folder = :Folder::varchar or folder like :Folder::varchar || '/%')
+				and (:Tags::varchar[] isnull or tags @> :Tags::varchar[])
+				and (:MimeTypes::varchar[] isnull or mime_type = any(:MimeTypes))
+				and (:SizeFrom::int8 isnull or size >= :SizeFrom::int8)
+				and (:SizeTo::int8 isnull or :SizeTo::int8 = 0 or size <= :SizeTo::int8)
+				and (:DurationFrom::numeric isnull or duration >= :DurationFrom::numeric)
+				and (:DurationTo::numeric isnull or :DurationTo::numeric = 0 or duration <= :DurationTo::numeric)
+				and (:CreatedBy::int8[] isnull or exists(select 1 from storage.media_files m
+					where m.id = t.id and m.created_by = any(:CreatedBy)))`,
 		model.MediaFile{}, f)
 
 	if err != nil {
@@ -92,7 +128,10 @@ func (s *SqlMediaFileStore) Get(domainId int64, id int) (*model.MediaFile, engin
 	var file *model.MediaFile
 
 	err := s.GetMaster().SelectOne(&file, `select f.id, f.name, f.created_at, call_center.cc_get_lookup(c.id, c.name) created_by,
-       f.updated_at, call_center.cc_get_lookup(u.id, u.name) updated_by, f.mime_type, f.size, properties, d.name as domain_name
+       f.updated_at, call_center.cc_get_lookup(u.id, u.name) updated_by, f.mime_type, f.size, properties, d.name as domain_name,
+       (f.properties -> 'conformance' ->> 'conformant')::bool as conformant, f.profile_id, f.version, f.folder, f.tags,
+       coalesce(f.play_count, 0) as play_count, f.last_played_at,
+       (select count(*) from storage.media_file_references r where r.media_file_id = f.id) as reference_count
 	from  storage.media_files f
 		left join directory.wbt_user c on f.created_by = c.id
 		left join directory.wbt_user u on f.updated_by = u.id
@@ -108,8 +147,318 @@ func (s *SqlMediaFileStore) Get(domainId int64, id int) (*model.MediaFile, engin
 	return file, nil
 }
 
+// GetFolders folders of the domain with the count of files, nil name - root
+func (s *SqlMediaFileStore) GetFolders(ctx context.Context, domainId int64) ([]*model.MediaFolder, engine.AppError) {
+	var folders []*model.MediaFolder
+
+	_, err := s.GetReplica().WithContext(ctx).Select(&folders, `select coalesce(f.folder, '') as name, count(*) as count
+from storage.media_files f
+where f.domain_id = :DomainId
+group by f.folder
+order by 1`, map[string]interface{}{
+		"DomainId": domainId,
+	})
+
+	if err != nil {
+		return nil, engine.NewCustomCodeError("store.sql_media_file.get_folders.app_error", err.Error(), extractCodeFromErr(err))
+	}
+
+	return folders, nil
+}
+
+// BulkUpdate moves media files to the folder and adds or removes tags, returns the count of changed files
+func (s *SqlMediaFileStore) BulkUpdate(ctx context.Context, domainId int64, bulk *model.MediaFilesBulk, updatedBy *model.Lookup) (int64, engine.AppError) {
+	res, err := s.GetMaster().WithContext(ctx).Exec(`update storage.media_files f
+set folder = case when :Move::bool then nullif(:Folder::varchar, '') else f.folder end,
+    tags = case when :AddTags::varchar[] isnull and :RemoveTags::varchar[] isnull then f.tags
+        else array(select distinct t
+                   from unnest(coalesce(f.tags, '{}') || coalesce(:AddTags::varchar[], '{}')) t
+                   where not t = any(coalesce(:RemoveTags::varchar[], '{}'))
+                   order by t) end,
+    updated_at = :UpdatedAt,
+    updated_by = :UpdatedBy
+where f.domain_id = :DomainId and f.id = any(:Ids::int8[])`, map[string]interface{}{
+		"DomainId":   domainId,
+		"Ids":        pq.Array(bulk.Ids),
+		"Move":       bulk.Folder != nil,
+		"Folder":     bulk.Folder,
+		"AddTags":    pq.Array(bulk.AddTags),
+		"RemoveTags": pq.Array(bulk.RemoveTags),
+		"UpdatedAt":  model.GetMillis(),
+		"UpdatedBy":  updatedBy.GetSafeId(),
+	})
+
+	if err != nil {
+		return 0, engine.NewCustomCodeError("store.sql_media_file.bulk_update.app_error", err.Error(), extractCodeFromErr(err))
+	}
+
+	cnt, _ := res.RowsAffected()
+	return cnt, nil
+}
+
+// GetForMigration media files of the domain stored outside the backend profile
+func (s *SqlMediaFileStore) GetForMigration(ctx context.Context, domainId int64, profileId int, limit int) ([]*model.MediaFile, engine.AppError) {
+	var files []*model.MediaFile
+
+	_, err := s.GetReplica().WithContext(ctx).Select(&files, `select f.id, f.name, f.created_at, f.updated_at, f.mime_type, f.size,
+       f.properties, f.domain_id, f.profile_id, f.version
+from storage.media_files f
+where f.domain_id = :DomainId
+  and (f.profile_id isnull or f.profile_id != :ProfileId)
+order by f.id
+limit :Limit`, map[string]interface{}{
+		"DomainId":  domainId,
+		"ProfileId": profileId,
+		"Limit":     limit,
+	})
+
+	if err != nil {
+		return nil, engine.NewCustomCodeError("store.sql_media_file.get_for_migration.app_error", err.Error(), extractCodeFromErr(err))
+	}
+
+	return files, nil
+}
+
+// SetProfile moves the media file to the backend profile, properties hold the new location
+func (s *SqlMediaFileStore) SetProfile(ctx context.Context, id int64, profileId *int, properties model.StringInterface) engine.AppError {
+	_, err := s.GetMaster().WithContext(ctx).Exec(`with f as (
+    update storage.media_files
+    set profile_id = :ProfileId,
+        properties = :Properties
+    where id = :Id
+    returning id, version
+)
+update storage.media_file_versions v
+set profile_id = :ProfileId,
+    properties = :Properties
+from f
+where v.media_file_id = f.id and v.version = coalesce(f.version, 1)`, map[string]interface{}{
+		"Id":         id,
+		"ProfileId":  profileId,
+		"Properties": model.StringInterfaceToJson(properties),
+	})
+
+	if err != nil {
+		return engine.NewCustomCodeError("store.sql_media_file.set_profile.app_error", fmt.Sprintf("id=%d, %s", id, err.Error()), extractCodeFromErr(err))
+	}
+
+	return nil
+}
+
+// GetVersions all revisions of the media file, the newest first
+func (s *SqlMediaFileStore) GetVersions(ctx context.Context, domainId, id int64) ([]*model.MediaFileVersion, engine.AppError) {
+	var versions []*model.MediaFileVersion
+
+	_, err := s.GetReplica().WithContext(ctx).Select(&versions, `select v.id, v.media_file_id, v.version, v.mime_type, v.size, v.properties,
+       v.profile_id, v.created_at, call_center.cc_get_lookup(u.id, u.name) created_by,
+       v.version = coalesce(f.version, 1) as active
+from storage.media_file_versions v
+    inner join storage.media_files f on f.id = v.media_file_id
+    left join directory.wbt_user u on u.id = v.created_by
+where f.domain_id = :DomainId and f.id = :Id
+order by v.version desc`, map[string]interface{}{
+		"DomainId": domainId,
+		"Id":       id,
+	})
+
+	if err != nil {
+		return nil, engine.NewCustomCodeError("store.sql_media_file.get_versions.app_error", err.Error(), extractCodeFromErr(err))
+	}
+
+	return versions, nil
+}
+
+func (s *SqlMediaFileStore) GetVersion(ctx context.Context, domainId, id int64, version int) (*model.MediaFileVersion, engine.AppError) {
+	var v *model.MediaFileVersion
+
+	err := s.GetReplica().WithContext(ctx).SelectOne(&v, `select v.id, v.media_file_id, v.version, v.mime_type, v.size, v.properties,
+       v.profile_id, v.created_at, call_center.cc_get_lookup(u.id, u.name) created_by,
+       v.version = coalesce(f.version, 1) as active
+from storage.media_file_versions v
+    inner join storage.media_files f on f.id = v.media_file_id
+    left join directory.wbt_user u on u.id = v.created_by
+where f.domain_id = :DomainId and f.id = :Id and v.version = :Version`, map[string]interface{}{
+		"DomainId": domainId,
+		"Id":       id,
+		"Version":  version,
+	})
+
+	if err != nil {
+		return nil, engine.NewCustomCodeError("store.sql_media_file.get_version.app_error", err.Error(), extractCodeFromErr(err))
+	}
+
+	return v, nil
+}
+
+func (s *SqlMediaFileStore) CreateVersion(ctx context.Context, version *model.MediaFileVersion) (*model.MediaFileVersion, engine.AppError) {
+	var v *model.MediaFileVersion
+
+	err := s.GetMaster().WithContext(ctx).SelectOne(&v, `with v as (
+    insert into storage.media_file_versions (media_file_id, version, mime_type, size, properties, profile_id, created_at, created_by)
+    values (:MediaFileId, :Version, :Mime, :Size, :Properties, :ProfileId, :CreatedAt, :CreatedBy)
+    returning *
+)
+select v.id, v.media_file_id, v.version, v.mime_type, v.size, v.properties,
+       v.profile_id, v.created_at, call_center.cc_get_lookup(u.id, u.name) created_by, false as active
+from v
+    left join directory.wbt_user u on u.id = v.created_by`, map[string]interface{}{
+		"MediaFileId": version.MediaFileId,
+		"Version":     version.Version,
+		"Mime":        version.MimeType,
+		"Size":        version.Size,
+		"Properties":  model.StringInterfaceToJson(version.Properties),
+		"ProfileId":   version.ProfileId,
+		"CreatedAt":   version.CreatedAt,
+		"CreatedBy":   version.CreatedBy.GetSafeId(),
+	})
+
+	if err != nil {
+		if strings.Index(err.Error(), "duplicate") > -1 {
+			return nil, engine.NewCustomCodeError("store.sql_media_file.create_version.duplicate", fmt.Sprintf("id=%d, version=%d, %s", version.MediaFileId, version.Version, err.Error()), http.StatusConflict)
+		}
+		return nil, engine.NewCustomCodeError("store.sql_media_file.create_version.app_error", fmt.Sprintf("id=%d, version=%d, %s", version.MediaFileId, version.Version, err.Error()), extractCodeFromErr(err))
+	}
+
+	return v, nil
+}
+
+// SetActiveVersion copies the content fields of the revision to the media file
+func (s *SqlMediaFileStore) SetActiveVersion(ctx context.Context, domainId, id int64, version int, updatedAt int64, updatedBy *model.Lookup) engine.AppError {
+	res, err := s.GetMaster().WithContext(ctx).Exec(`update storage.media_files f
+set version = v.version,
+    mime_type = v.mime_type,
+    size = v.size,
+    properties = v.properties,
+    profile_id = v.profile_id,
+    updated_at = :UpdatedAt,
+    updated_by = :UpdatedBy
+from storage.media_file_versions v
+where f.domain_id = :DomainId and f.id = :Id
+  and v.media_file_id = f.id and v.version = :Version`, map[string]interface{}{
+		"DomainId":  domainId,
+		"Id":        id,
+		"Version":   version,
+		"UpdatedAt": updatedAt,
+		"UpdatedBy": updatedBy.GetSafeId(),
+	})
+
+	if err != nil {
+		return engine.NewCustomCodeError("store.sql_media_file.set_active_version.app_error", fmt.Sprintf("id=%d, version=%d, %s", id, version, err.Error()), extractCodeFromErr(err))
+	}
+
+	if cnt, _ := res.RowsAffected(); cnt == 0 {
+		return engine.NewNotFoundError("store.sql_media_file.set_active_version.not_found", fmt.Sprintf("id=%d, version=%d", id, version))
+	}
+
+	return nil
+}
+
+// AddPlaybacks adds the counted playbacks to the media files
+func (s *SqlMediaFileStore) AddPlaybacks(ctx context.Context, playbacks []*model.MediaPlayback) engine.AppError {
+	ids := make([]int64, 0, len(playbacks))
+	counts := make([]int64, 0, len(playbacks))
+	last := make([]int64, 0, len(playbacks))
+	for _, p := range playbacks {
+		ids = append(ids, p.Id)
+		counts = append(counts, p.Count)
+		last = append(last, p.LastPlayedAt)
+	}
+
+	_, err := s.GetMaster().WithContext(ctx).Exec(`update storage.media_files f
+set play_count = coalesce(f.play_count, 0) + p.count,
+    last_played_at = greatest(f.last_played_at, p.last_played_at)
+from unnest(:Ids::int8[], :Counts::int8[], :Last::int8[]) p(id, count, last_played_at)
+where f.id = p.id`, map[string]interface{}{
+		"Ids":    pq.Array(ids),
+		"Counts": pq.Array(counts),
+		"Last":   pq.Array(last),
+	})
+
+	if err != nil {
+		return engine.NewCustomCodeError("store.sql_media_file.add_playbacks.app_error", err.Error(), extractCodeFromErr(err))
+	}
+
+	return nil
+}
+
+func (s *SqlMediaFileStore) GetReferences(ctx context.Context, domainId, id int64) ([]*model.MediaFileReference, engine.AppError) {
+	var refs []*model.MediaFileReference
+
+	_, err := s.GetReplica().WithContext(ctx).Select(&refs, `select r.media_file_id, r.source, r.object_id, r.created_at
+from storage.media_file_references r
+    inner join storage.media_files f on f.id = r.media_file_id
+where f.domain_id = :DomainId and f.id = :Id
+order by r.source, r.object_id`, map[string]interface{}{
+		"DomainId": domainId,
+		"Id":       id,
+	})
+
+	if err != nil {
+		return nil, engine.NewCustomCodeError("store.sql_media_file.get_references.app_error", err.Error(), extractCodeFromErr(err))
+	}
+
+	return refs, nil
+}
+
+// AddReference registers the reference, the existing one is not changed
+func (s *SqlMediaFileStore) AddReference(ctx context.Context, domainId int64, ref *model.MediaFileReference) engine.AppError {
+	res, err := s.GetMaster().WithContext(ctx).Exec(`insert into storage.media_file_references (media_file_id, source, object_id, created_at)
+select f.id, :Source, :ObjectId, :CreatedAt
+from storage.media_files f
+where f.domain_id = :DomainId and f.id = :Id
+on conflict (media_file_id, source, object_id) do nothing`, map[string]interface{}{
+		"DomainId":  domainId,
+		"Id":        ref.MediaFileId,
+		"Source":    ref.Source,
+		"ObjectId":  ref.ObjectId,
+		"CreatedAt": ref.CreatedAt,
+	})
+
+	if err != nil {
+		return engine.NewCustomCodeError("store.sql_media_file.add_reference.app_error", err.Error(), extractCodeFromErr(err))
+	}
+
+	if cnt, _ := res.RowsAffected(); cnt == 0 {
+		// the file does not exist, or the reference is already registered
+		id, e := s.GetReplica().WithContext(ctx).SelectInt(`select f.id from storage.media_files f where f.domain_id = :DomainId and f.id = :Id`,
+			map[string]interface{}{"DomainId": domainId, "Id": ref.MediaFileId})
+		if e != nil {
+			return engine.NewCustomCodeError("store.sql_media_file.add_reference.app_error", e.Error(), extractCodeFromErr(e))
+		}
+		if id == 0 {
+			return engine.NewNotFoundError("store.sql_media_file.add_reference.not_found", fmt.Sprintf("id=%d", ref.MediaFileId))
+		}
+	}
+
+	return nil
+}
+
+func (s *SqlMediaFileStore) RemoveReference(ctx context.Context, domainId int64, ref *model.MediaFileReference) engine.AppError {
+	_, err := s.GetMaster().WithContext(ctx).Exec(`delete from storage.media_file_references r
+using storage.media_files f
+where f.id = r.media_file_id and f.domain_id = :DomainId
+  and r.media_file_id = :Id and r.source = :Source and r.object_id = :ObjectId`, map[string]interface{}{
+		"DomainId": domainId,
+		"Id":       ref.MediaFileId,
+		"Source":   ref.Source,
+		"ObjectId": ref.ObjectId,
+	})
+
+	if err != nil {
+		return engine.NewCustomCodeError("store.sql_media_file.remove_reference.app_error", err.Error(), extractCodeFromErr(err))
+	}
+
+	return nil
+}
+
 func (s SqlMediaFileStore) Delete(domainId, id int64) engine.AppError {
-	if _, err := s.GetMaster().Exec(`delete from storage.media_files p where id = :Id and domain_id = :DomainId`,
+	if _, err := s.GetMaster().Exec(`with f as (
+    delete from storage.media_files p where id = :Id and domain_id = :DomainId
+    returning id
+), r as (
+    delete from storage.media_file_references r using f where r.media_file_id = f.id
+)
+delete from storage.media_file_versions v using f where v.media_file_id = f.id`,
 		map[string]interface{}{"Id": id, "DomainId": domainId}); err != nil {
 		return engine.NewCustomCodeError("store.sql_media_file.delete.app_error", fmt.Sprintf("Id=%v, %s", id, err.Error()), extractCodeFromErr(err))
 	}
@@ -213,7 +562,12 @@ func (self *SqlMediaFileStore) DeleteByName(name, domain string) store.StoreChan
 
 func (self *SqlMediaFileStore) DeleteById(id int64) store.StoreChannel {
 	return store.Do(func(result *store.StoreResult) {
-		res, err := self.GetMaster().Exec("DELETE FROM storage.media_files WHERE id = :Id", map[string]interface{}{"Id": id})
+		res, err := self.GetMaster().Exec(`with v as (
+    delete from storage.media_file_versions where media_file_id = :Id
+), r as (
+    delete from storage.media_file_references where media_file_id = :Id
+)
+delete from storage.media_files where id = :Id`, map[string]interface{}{"Id": id})
 		if err != nil {
 			result.Err = engine.NewInternalError("store.sql_media_file.delete.app_error", fmt.Sprintf("id=%d, err: %s", id, err.Error()))
 			return
@@ -2,7 +2,6 @@ package sqlstore
 
 import (
 	"context"
-	dbsql "database/sql"
 	"errors"
 	"fmt"
 	"github.com/lib/pq"
@@ -45,6 +44,7 @@ type SqlSupplierOldStores struct {
 	importTemplate     store.ImportTemplateStore
 	filePolicies       store.FilePoliciesStore
 	sysSettings        store.SystemSettingsStore
+	fileVariant        store.FileVariantStore
 }
 
 type SqlSupplier struct {
@@ -80,6 +80,7 @@ func NewSqlSupplier(settings model.SqlSettings) *SqlSupplier {
 	supplier.oldStores.importTemplate = NewSqlImportTemplateStore(supplier)
 	supplier.oldStores.filePolicies = NewSqlFilePoliciesStore(supplier)
 	supplier.oldStores.sysSettings = NewSqlSysSettingsStore(supplier)
+	supplier.oldStores.fileVariant = NewSqlFileVariantStore(supplier)
 
 	err := supplier.GetMaster().CreateTablesIfNotExists()
 	if err != nil {
@@ -112,7 +113,7 @@ func (ss *SqlSupplier) GetAllConns() []*gorp.DbMap {
 }
 
 func setupConnection(con_type string, dataSource string, settings *model.SqlSettings) *gorp.DbMap {
-	db, err := dbsql.Open(*settings.DriverName, dataSource)
+	db, err := openTraced(*settings.DriverName, dataSource)
 	if err != nil {
 		wlog.Critical(fmt.Sprintf("Failed to open SQL connection to err:%v", err.Error()))
 		time.Sleep(time.Second)
@@ -345,6 +346,18 @@ func (ss *SqlSupplier) GetReplica() *gorp.DbMap {
 	return ss.replicas[rrNum]
 }
 
+func (ss *SqlSupplier) Ping(ctx context.Context) []*model.HealthCheck {
+	checks := []*model.HealthCheck{
+		model.NewHealthCheck("db_master", true, ss.master.Db.PingContext(ctx)),
+	}
+
+	for i, replica := range ss.replicas {
+		checks = append(checks, model.NewHealthCheck(fmt.Sprintf("db_replica_%d", i), true, replica.Db.PingContext(ctx)))
+	}
+
+	return checks
+}
+
 func (ss *SqlSupplier) DriverName() string {
 	return *ss.settings.DriverName
 }
@@ -396,3 +409,7 @@ func (ss *SqlSupplier) FilePolicies() store.FilePoliciesStore {
 func (ss *SqlSupplier) SystemSettings() store.SystemSettingsStore {
 	return ss.oldStores.sysSettings
 }
+
+func (ss *SqlSupplier) FileVariant() store.FileVariantStore {
+	return ss.oldStores.fileVariant
+}
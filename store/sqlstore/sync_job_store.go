@@ -1,6 +1,10 @@
 package sqlstore
 
 import (
+	"context"
+
+	"github.com/go-gorp/gorp"
+	"github.com/lib/pq"
 	engine "github.com/webitel/engine/model"
 	"github.com/webitel/storage/model"
 	"github.com/webitel/storage/store"
@@ -15,24 +19,51 @@ func NewSqlSyncFileStore(sqlStore SqlStore) store.SyncFileStore {
 	return us
 }
 
-func (s SqlSyncFileStore) FetchJobs(limit int) ([]*model.SyncJob, engine.AppError) {
+// FetchJobs takes pending jobs of the action round-robin by domain, see model.FairShare;
+// the synchronizer fetches each action up to its free workers
+func (s SqlSyncFileStore) FetchJobs(action string, instance string, limit int, fair *model.FairShare) ([]*model.SyncJob, engine.AppError) {
 	var res []*model.SyncJob
-	_, err := s.GetMaster().Select(&res, `update storage.file_jobs u
-set state = 1
+	err := claimFair(s.GetMaster(), "storage.file_jobs:"+action, fair, func(db gorp.SqlExecutor) error {
+		_, err := db.Select(&res, `update storage.file_jobs u
+set state = 1,
+    instance = :Instance,
+    updated_at = now()
 from (
     select j.id, j.file_id, f.domain_id, f.properties, f.profile_id, p.updated_at as profile_updated_at, f.name, f.size, f.mime_type, f.instance,
 		j.action, j.config
-    from storage.file_jobs j
+    from (
+        select c.*, row_number() over (partition by c.domain_id order by c.prio, c.created_at) as rn
+        from (
+            select j.id, j.created_at, f.domain_id,
+                   case when f.channel = any(:PriorityChannels::varchar[]) then 0 else 1 end as prio
+            from storage.file_jobs j
+                inner join storage.files f on f.id = j.file_id
+            where j.state = 0 and j.action = :Action
+        ) c
+    ) c
+        left join lateral (
+            select count(*) as active
+            from storage.file_jobs a
+                inner join storage.files af on af.id = a.file_id
+            where a.state = 1 and a.action = :Action and af.domain_id = c.domain_id
+        ) a on :DomainMaxActive::int > 0
+        inner join storage.file_jobs j on j.id = c.id
         inner join storage.files f on f.id = j.file_id
         left join storage.file_backend_profiles p on p.id = f.profile_id
-    where j.state = 0
-    order by j.created_at asc
+    where :DomainMaxActive::int = 0 or c.rn <= :DomainMaxActive::int - a.active
+    order by c.rn, c.prio, c.created_at
     limit :Limit
     for update OF j skip locked
 ) j
 where u.id = j.id and u.state = 0
 returning j.*`, map[string]interface{}{
-		"Limit": limit,
+			"Action":           action,
+			"Instance":         instance,
+			"Limit":            limit,
+			"DomainMaxActive":  fair.DomainMaxActive,
+			"PriorityChannels": pq.Array(fair.PriorityChannels),
+		})
+		return err
 	})
 
 	if err != nil {
@@ -107,10 +138,82 @@ func (s SqlSyncFileStore) Remove(jobId int64) engine.AppError {
 	return nil
 }
 
-func (s SqlSyncFileStore) RemoveErrors() engine.AppError {
+// Release returns the claimed job to the queue
+func (s SqlSyncFileStore) Release(jobId int64) engine.AppError {
+	_, err := s.GetMaster().Exec(`update storage.file_jobs
+	set state = 0,
+		instance = null,
+		updated_at = now()
+	where id = :Id and state = 1`, map[string]interface{}{
+		"Id": jobId,
+	})
+
+	if err != nil {
+		return engine.NewCustomCodeError("store.sql_sync_file_job.release.app_error", err.Error(), extractCodeFromErr(err))
+	}
+
+	return nil
+}
+
+// ReleaseInstance returns to the queue the jobs left active by the previous run of the instance
+func (s SqlSyncFileStore) ReleaseInstance(ctx context.Context, instance string) (int64, engine.AppError) {
+	res, err := s.GetMaster().WithContext(ctx).Exec(`update storage.file_jobs
+	set state = 0,
+		instance = null,
+		updated_at = now()
+	where state = 1 and instance = :Instance`, map[string]interface{}{
+		"Instance": instance,
+	})
+
+	if err != nil {
+		return 0, engine.NewCustomCodeError("store.sql_sync_file_job.release_instance.app_error", err.Error(), extractCodeFromErr(err))
+	}
+
+	cnt, _ := res.RowsAffected()
+
+	return cnt, nil
+}
+
+// Heartbeat marks the active jobs of the instance as alive
+func (s SqlSyncFileStore) Heartbeat(ctx context.Context, instance string) engine.AppError {
+	_, err := s.GetMaster().WithContext(ctx).Exec(`update storage.file_jobs
+	set updated_at = now()
+	where state = 1 and instance = :Instance`, map[string]interface{}{
+		"Instance": instance,
+	})
+
+	if err != nil {
+		return engine.NewCustomCodeError("store.sql_sync_file_job.heartbeat.app_error", err.Error(), extractCodeFromErr(err))
+	}
+
+	return nil
+}
+
+// ReclaimStale returns to the queue the active jobs without a heartbeat for staleMinutes
+func (s SqlSyncFileStore) ReclaimStale(ctx context.Context, staleMinutes int) (int64, engine.AppError) {
+	res, err := s.GetMaster().WithContext(ctx).Exec(`update storage.file_jobs
+	set state = 0,
+		instance = null,
+		updated_at = now()
+	where state = 1 and updated_at < now() - (:Minutes::int || ' minutes')::interval`, map[string]interface{}{
+		"Minutes": staleMinutes,
+	})
+
+	if err != nil {
+		return 0, engine.NewCustomCodeError("store.sql_sync_file_job.reclaim_stale.app_error", err.Error(), extractCodeFromErr(err))
+	}
+
+	cnt, _ := res.RowsAffected()
+
+	return cnt, nil
+}
+
+func (s SqlSyncFileStore) RemoveErrors(retentionHours int) engine.AppError {
 	_, err := s.GetMaster().Exec(`delete
 from storage.file_jobs j
-where j.updated_at < now() - interval '1h' and j.state = 3`)
+where j.updated_at < now() - (:Hours::int || ' hours')::interval and j.state = 3`, map[string]interface{}{
+		"Hours": retentionHours,
+	})
 	if err != nil {
 		return engine.NewCustomCodeError("store.sql_sync_file_job.remove_err.app_error", err.Error(), extractCodeFromErr(err))
 	}
@@ -134,3 +237,288 @@ func (s SqlSyncFileStore) SetError(jobId int64, e error) engine.AppError {
 
 	return nil
 }
+
+// CreateThumbnailJobs creates jobs for image and video files without a thumbnail, files with an active job are skipped
+func (s SqlSyncFileStore) CreateThumbnailJobs(ctx context.Context, domainId int64, job *model.ThumbnailJob) (int64, engine.AppError) {
+	cnt, err := s.GetMaster().WithContext(ctx).SelectInt(`with ins as (
+    insert into storage.file_jobs (state, file_id, action, config)
+    select 0, f.id, :Action, json_build_object('scale', :Scale::varchar)
+    from storage.files f
+    where f.domain_id = :DomainId::int8
+        and not coalesce(f.removed, false)
+        and (f.mime_type like 'image/%' or f.mime_type like 'video/%')
+        and f.mime_type not like 'image/svg%'
+        and f.uploaded_at between to_timestamp(:From::int8 / 1000.0) and to_timestamp(:To::int8 / 1000.0)
+        and (:Channels::varchar[] isnull or f.channel = any(:Channels::varchar[]))
+        and (:Force::bool or f.thumbnail isnull)
+        and not exists(select 1
+                       from storage.file_jobs j
+                       where j.file_id = f.id
+                         and j.action = :Action
+                         and j.state in (0, 1))
+    returning 1
+)
+select count(*)
+from ins`, map[string]interface{}{
+		"DomainId": domainId,
+		"Action":   model.SyncJobThumbnail,
+		"Scale":    job.Scale,
+		"From":     job.UploadedAt.From,
+		"To":       job.UploadedAt.To,
+		"Channels": pq.Array(job.Channels),
+		"Force":    job.Force,
+	})
+
+	if err != nil {
+		return 0, engine.NewCustomCodeError("store.sql_sync_file_job.create_thumbnail.app_error", err.Error(), extractCodeFromErr(err))
+	}
+
+	return cnt, nil
+}
+
+// SetTranscodeJobs creates jobs for audio files whose policy has transcode_codec, after transcode_after_days
+// since the upload. As on upload, a file is governed only by its first matching policy (highest position).
+// Instances run it at the same time, so the insert is serialized by an advisory lock
+func (s SqlSyncFileStore) SetTranscodeJobs(limit int) engine.AppError {
+	tx, err := s.GetMaster().Begin()
+	if err != nil {
+		return engine.NewCustomCodeError("store.sql_sync_file_job.set_transcode.app_error", err.Error(), extractCodeFromErr(err))
+	}
+	defer tx.Rollback()
+
+	// a separate statement: in read committed the insert takes its snapshot after the lock is acquired
+	if _, err = tx.Exec(`select pg_advisory_xact_lock(hashtext(:Lock))`, map[string]interface{}{
+		"Lock": "storage.file_jobs.set:" + model.SyncJobTranscode,
+	}); err != nil {
+		return engine.NewCustomCodeError("store.sql_sync_file_job.set_transcode.app_error", err.Error(), extractCodeFromErr(err))
+	}
+
+	_, err = tx.Exec(`insert into storage.file_jobs (state, file_id, action, config)
+select 0, f.id, :Action, json_build_object('codec', f.transcode_codec, 'bit_rate', f.transcode_bit_rate)
+from (
+    select distinct on (f.id) f.id, f.mime_type, f.uploaded_at,
+        p.transcode_codec, p.transcode_bit_rate, p.transcode_after_days
+    from storage.files f
+        inner join storage.file_policies p on p.domain_id = f.domain_id
+            and p.enabled
+            and f.channel = any(p.channels)
+            and f.mime_type ilike any(array(select replace(replace(m, '*', '%'), '?', '_') from unnest(p.mime_types) m))
+    where f.mime_type like 'audio/%'
+        and not coalesce(f.removed, false)
+        and f.properties -> :Transcoded::varchar isnull
+        and f.properties -> :TranscodeError::varchar isnull
+        and not exists(select 1 from storage.file_jobs j where j.file_id = f.id)
+        and exists(select 1
+                   from storage.file_policies tp
+                   where tp.domain_id = f.domain_id
+                     and tp.enabled
+                     and tp.transcode_codec notnull)
+    order by f.id, p.position desc
+) f
+where f.transcode_codec notnull
+    and f.mime_type <> case f.transcode_codec when :Mp3 then 'audio/mpeg' else 'audio/ogg' end
+    and f.uploaded_at <= now() - (f.transcode_after_days || ' days')::interval
+order by f.uploaded_at
+limit :Limit`, map[string]interface{}{
+		"Action":         model.SyncJobTranscode,
+		"Mp3":            model.TranscodeCodecMp3,
+		"Transcoded":     model.FilePropertyTranscoded,
+		"TranscodeError": model.FilePropertyTranscodeError,
+		"Limit":          limit,
+	})
+
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		return engine.NewCustomCodeError("store.sql_sync_file_job.set_transcode.app_error", err.Error(), extractCodeFromErr(err))
+	}
+
+	return nil
+}
+
+// CreateMetadataJobs creates jobs for audio and video files without media metadata, files with an active job are skipped
+func (s SqlSyncFileStore) CreateMetadataJobs(ctx context.Context, domainId int64, job *model.MediaMetadataJob) (int64, engine.AppError) {
+	cnt, err := s.GetMaster().WithContext(ctx).SelectInt(`with ins as (
+    insert into storage.file_jobs (state, file_id, action)
+    select 0, f.id, :Action
+    from storage.files f
+    where f.domain_id = :DomainId::int8
+        and not coalesce(f.removed, false)
+        and (f.mime_type like 'audio/%' or f.mime_type like 'video/%')
+        and f.uploaded_at between to_timestamp(:From::int8 / 1000.0) and to_timestamp(:To::int8 / 1000.0)
+        and (:Channels::varchar[] isnull or f.channel = any(:Channels::varchar[]))
+        and (:Force::bool or f.properties -> :Duration::varchar isnull)
+        and not exists(select 1
+                       from storage.file_jobs j
+                       where j.file_id = f.id
+                         and j.action = :Action
+                         and j.state in (0, 1))
+    returning 1
+)
+select count(*)
+from ins`, map[string]interface{}{
+		"DomainId": domainId,
+		"Action":   model.SyncJobMetadata,
+		"Duration": model.FilePropertyDuration,
+		"From":     job.UploadedAt.From,
+		"To":       job.UploadedAt.To,
+		"Channels": pq.Array(job.Channels),
+		"Force":    job.Force,
+	})
+
+	if err != nil {
+		return 0, engine.NewCustomCodeError("store.sql_sync_file_job.create_metadata.app_error", err.Error(), extractCodeFromErr(err))
+	}
+
+	return cnt, nil
+}
+
+func (s SqlSyncFileStore) JobsProgress(ctx context.Context, domainId int64, action string) (*model.FileJobsProgress, engine.AppError) {
+	var progress *model.FileJobsProgress
+	err := s.GetReplica().WithContext(ctx).SelectOne(&progress, `select count(*) filter ( where j.state = 0 ) as pending,
+       count(*) filter ( where j.state = 1 ) as active,
+       count(*) filter ( where j.state = 3 ) as failed
+from storage.file_jobs j
+    inner join storage.files f on f.id = j.file_id
+where f.domain_id = :DomainId::int8
+    and j.action = :Action`, map[string]interface{}{
+		"DomainId": domainId,
+		"Action":   action,
+	})
+
+	if err != nil {
+		return nil, engine.NewCustomCodeError("store.sql_sync_file_job.progress.app_error", err.Error(), extractCodeFromErr(err))
+	}
+
+	return progress, nil
+}
+
+func (s SqlSyncFileStore) Search(ctx context.Context, domainId int64, search *model.SearchFileJob) ([]*model.FileJob, engine.AppError) {
+	var from, to *int64
+	if search.CreatedAt != nil {
+		if search.CreatedAt.From > 0 {
+			from = &search.CreatedAt.From
+		}
+		if search.CreatedAt.To > 0 {
+			to = &search.CreatedAt.To
+		}
+	}
+
+	var jobs []*model.FileJob
+	_, err := s.GetReplica().WithContext(ctx).Select(&jobs, `select j.id, j.file_id, f.name as file_name, j.action, j.state, j.error,
+       (extract(epoch from j.created_at) * 1000)::int8 as created_at,
+       (extract(epoch from j.updated_at) * 1000)::int8 as updated_at
+from storage.file_jobs j
+    inner join storage.files f on f.id = j.file_id
+where f.domain_id = :DomainId::int8
+    and (:Actions::varchar[] isnull or j.action = any(:Actions::varchar[]))
+    and (:States::int[] isnull or j.state = any(:States::int[]))
+    and (:From::int8 isnull or j.created_at >= to_timestamp(:From::int8 / 1000.0))
+    and (:To::int8 isnull or j.created_at <= to_timestamp(:To::int8 / 1000.0))
+order by j.created_at desc
+limit :Limit offset :Offset`, map[string]interface{}{
+		"DomainId": domainId,
+		"Actions":  pq.Array(search.Actions),
+		"States":   pq.Array(search.States),
+		"From":     from,
+		"To":       to,
+		"Limit":    search.GetLimit(),
+		"Offset":   search.GetOffset(),
+	})
+
+	if err != nil {
+		return nil, engine.NewCustomCodeError("store.sql_sync_file_job.search.app_error", err.Error(), extractCodeFromErr(err))
+	}
+
+	return jobs, nil
+}
+
+func (s SqlSyncFileStore) Get(ctx context.Context, domainId int64, id int64) (*model.FileJob, engine.AppError) {
+	var job *model.FileJob
+	err := s.GetReplica().WithContext(ctx).SelectOne(&job, `select j.id, j.file_id, f.name as file_name, j.action, j.state, j.error,
+       (extract(epoch from j.created_at) * 1000)::int8 as created_at,
+       (extract(epoch from j.updated_at) * 1000)::int8 as updated_at
+from storage.file_jobs j
+    inner join storage.files f on f.id = j.file_id
+where f.domain_id = :DomainId::int8
+    and j.id = :Id::int8`, map[string]interface{}{
+		"DomainId": domainId,
+		"Id":       id,
+	})
+
+	if err != nil {
+		return nil, engine.NewCustomCodeError("store.sql_sync_file_job.get.app_error", err.Error(), extractCodeFromErr(err))
+	}
+
+	return job, nil
+}
+
+// Retry returns failed jobs to the queue
+func (s SqlSyncFileStore) Retry(ctx context.Context, domainId int64, ids []int64) (int64, engine.AppError) {
+	res, err := s.GetMaster().WithContext(ctx).Exec(`update storage.file_jobs j
+set state = 0,
+    error = null,
+    updated_at = now()
+from storage.files f
+where f.id = j.file_id
+    and f.domain_id = :DomainId::int8
+    and j.id = any(:Ids::int8[])
+    and j.state = 3`, map[string]interface{}{
+		"DomainId": domainId,
+		"Ids":      pq.Array(ids),
+	})
+
+	if err != nil {
+		return 0, engine.NewCustomCodeError("store.sql_sync_file_job.retry.app_error", err.Error(), extractCodeFromErr(err))
+	}
+
+	cnt, _ := res.RowsAffected()
+
+	return cnt, nil
+}
+
+// Cancel removes pending and failed jobs, active jobs are finished by the synchronizer
+func (s SqlSyncFileStore) Cancel(ctx context.Context, domainId int64, ids []int64) (int64, engine.AppError) {
+	res, err := s.GetMaster().WithContext(ctx).Exec(`delete
+from storage.file_jobs j
+using storage.files f
+where f.id = j.file_id
+    and f.domain_id = :DomainId::int8
+    and j.id = any(:Ids::int8[])
+    and j.state in (0, 3)`, map[string]interface{}{
+		"DomainId": domainId,
+		"Ids":      pq.Array(ids),
+	})
+
+	if err != nil {
+		return 0, engine.NewCustomCodeError("store.sql_sync_file_job.cancel.app_error", err.Error(), extractCodeFromErr(err))
+	}
+
+	cnt, _ := res.RowsAffected()
+
+	return cnt, nil
+}
+
+// Queue jobs by action and state, domainId 0 - all domains
+func (s SqlSyncFileStore) Queue(ctx context.Context, domainId int64) ([]*model.FileJobQueue, engine.AppError) {
+	var queue []*model.FileJobQueue
+	_, err := s.GetReplica().WithContext(ctx).Select(&queue, `select j.action,
+       count(*) filter ( where j.state = 0 ) as pending,
+       count(*) filter ( where j.state = 1 ) as active,
+       count(*) filter ( where j.state = 3 ) as failed
+from storage.file_jobs j
+    inner join storage.files f on f.id = j.file_id
+where (:DomainId::int8 = 0 or f.domain_id = :DomainId::int8)
+group by j.action
+order by j.action`, map[string]interface{}{
+		"DomainId": domainId,
+	})
+
+	if err != nil {
+		return nil, engine.NewCustomCodeError("store.sql_sync_file_job.queue.app_error", err.Error(), extractCodeFromErr(err))
+	}
+
+	return queue, nil
+}
@@ -0,0 +1,172 @@
+package sqlstore
+
+import (
+	"context"
+	dbsql "database/sql"
+	sqldriver "database/sql/driver"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/webitel/storage/store/sqlstore")
+
+// openTraced opens the database through a connector that starts a span for every
+// query executed with a context that already carries a span.
+func openTraced(driverName, dataSource string) (*dbsql.DB, error) {
+	db, err := dbsql.Open(driverName, dataSource)
+	if err != nil {
+		return nil, err
+	}
+
+	d := db.Driver()
+	db.Close()
+
+	var connector sqldriver.Connector
+	if dc, ok := d.(sqldriver.DriverContext); ok {
+		if connector, err = dc.OpenConnector(dataSource); err != nil {
+			return nil, err
+		}
+	} else {
+		connector = &dsnConnector{driver: d, dsn: dataSource}
+	}
+
+	return dbsql.OpenDB(&tracedConnector{Connector: connector, system: driverName}), nil
+}
+
+type dsnConnector struct {
+	driver sqldriver.Driver
+	dsn    string
+}
+
+func (c *dsnConnector) Connect(_ context.Context) (sqldriver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c *dsnConnector) Driver() sqldriver.Driver {
+	return c.driver
+}
+
+type tracedConnector struct {
+	sqldriver.Connector
+	system string
+}
+
+func (c *tracedConnector) Connect(ctx context.Context) (sqldriver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tracedConn{Conn: conn, system: c.system}, nil
+}
+
+type tracedConn struct {
+	sqldriver.Conn
+	system string
+}
+
+func (c *tracedConn) startSpan(ctx context.Context, query string) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, nil
+	}
+
+	return tracer.Start(ctx, queryOperation(query),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", c.system),
+			attribute.String("db.statement", query),
+		),
+	)
+}
+
+func endSpan(span trace.Span, err error) {
+	if span == nil {
+		return
+	}
+
+	if err != nil && err != sqldriver.ErrSkip {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (c *tracedConn) ExecContext(ctx context.Context, query string, args []sqldriver.NamedValue) (sqldriver.Result, error) {
+	execer, ok := c.Conn.(sqldriver.ExecerContext)
+	if !ok {
+		return nil, sqldriver.ErrSkip
+	}
+
+	ctx, span := c.startSpan(ctx, query)
+	res, err := execer.ExecContext(ctx, query, args)
+	endSpan(span, err)
+
+	return res, err
+}
+
+func (c *tracedConn) QueryContext(ctx context.Context, query string, args []sqldriver.NamedValue) (sqldriver.Rows, error) {
+	queryer, ok := c.Conn.(sqldriver.QueryerContext)
+	if !ok {
+		return nil, sqldriver.ErrSkip
+	}
+
+	ctx, span := c.startSpan(ctx, query)
+	rows, err := queryer.QueryContext(ctx, query, args)
+	endSpan(span, err)
+
+	return rows, err
+}
+
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (sqldriver.Stmt, error) {
+	if p, ok := c.Conn.(sqldriver.ConnPrepareContext); ok {
+		return p.PrepareContext(ctx, query)
+	}
+
+	return c.Conn.Prepare(query)
+}
+
+func (c *tracedConn) BeginTx(ctx context.Context, opts sqldriver.TxOptions) (sqldriver.Tx, error) {
+	if b, ok := c.Conn.(sqldriver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+
+	return c.Conn.Begin()
+}
+
+func (c *tracedConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(sqldriver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+
+	return nil
+}
+
+func (c *tracedConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(sqldriver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+
+	return nil
+}
+
+func (c *tracedConn) IsValid() bool {
+	if v, ok := c.Conn.(sqldriver.Validator); ok {
+		return v.IsValid()
+	}
+
+	return true
+}
+
+// queryOperation span name - the first keyword of the statement
+func queryOperation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "SQL"
+	}
+
+	return strings.ToUpper(fields[0])
+}
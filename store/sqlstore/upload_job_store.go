@@ -1,6 +1,10 @@
 package sqlstore
 
 import (
+	"context"
+
+	"github.com/go-gorp/gorp"
+	"github.com/lib/pq"
 	engine "github.com/webitel/engine/model"
 	"github.com/webitel/storage/model"
 	"github.com/webitel/storage/store"
@@ -71,11 +75,13 @@ func (self *SqlUploadJobStore) GetAllPageByInstance(limit int, instance string)
 	})
 }
 
-func (self *SqlUploadJobStore) UpdateWithProfile(limit int, instance string, betweenAttemptSec int64, defStore bool) store.StoreChannel {
+// UpdateWithProfile takes jobs of the instance round-robin by domain, see model.FairShare
+func (self *SqlUploadJobStore) UpdateWithProfile(limit int, instance string, betweenAttemptSec int64, defStore bool, fair *model.FairShare) store.StoreChannel {
 	return store.Do(func(result *store.StoreResult) {
 		var jobs []*model.JobUploadFileWithProfile
 
-		_, err := self.GetMaster().Select(&jobs, `update storage.upload_file_jobs uu
+		err := claimFair(self.GetMaster(), "storage.upload_file_jobs", fair, func(db gorp.SqlExecutor) error {
+			_, err := db.Select(&jobs, `update storage.upload_file_jobs uu
 set attempts = attempts + 1
   ,state = 1
   ,updated_at = extract(EPOCH from now()) :: BIGINT
@@ -93,7 +99,14 @@ from (
          profile.id as profile_id,
          profile.updated_at profile_updated_at,
          t.channel
-       FROM storage.upload_file_jobs as t
+       FROM (select t.*,
+                    row_number() over (partition by t.domain_id order by t.prio, t.created_at) as rn
+             from (select t.*,
+                          case when t.channel = any (:PriorityChannels::varchar[]) then 0 else 1 end as prio
+                   from storage.upload_file_jobs t
+                   where t.state = 0
+                     AND t.instance = :Instance
+                     AND (t.updated_at < :UpdatedAt OR t.attempts = 0)) t) as t
          left join lateral (              select
                                              tmp.domain_id,
                                              tmp.id,
@@ -107,15 +120,24 @@ from (
                                                  where p1.domain_id = t.domain_id and NOT p1.disabled is TRUE) as tmp
                                            order by tmp.priority desc
                                            FETCH FIRST 1 ROW ONLY              ) profile ON profile.domain_id = t.domain_id
-       WHERE state = 0  and (:UseDef::bool = true or profile.id notnull ) AND instance = :Instance AND (t.updated_at < :UpdatedAt OR attempts = 0)
-       ORDER BY created_at ASC
+         left join lateral (select count(*) as active
+                            from storage.upload_file_jobs a
+                            where a.domain_id = t.domain_id
+                              and a.state = 1) a on :DomainMaxActive::int > 0
+       WHERE (:UseDef::bool = true or profile.id notnull )
+         AND (:DomainMaxActive::int = 0 or t.rn <= :DomainMaxActive::int - a.active)
+       ORDER BY t.rn, t.prio, t.created_at ASC
        LIMIT :Limit) tmp
 WHERE tmp.id = uu.id and state = 0
-returning tmp.*`, map[string]interface{}{
-			"UseDef":    defStore,
-			"Instance":  instance,
-			"Limit":     limit,
-			"UpdatedAt": model.GetMillis() - betweenAttemptSec,
+returning tmp.*, uu.attempts`, map[string]interface{}{
+				"UseDef":           defStore,
+				"Instance":         instance,
+				"Limit":            limit,
+				"UpdatedAt":        model.GetMillis() - betweenAttemptSec,
+				"DomainMaxActive":  fair.DomainMaxActive,
+				"PriorityChannels": pq.Array(fair.PriorityChannels),
+			})
+			return err
 		})
 		if err != nil {
 			result.Err = engine.NewInternalError("store.sql_upload_job.update_with_profile.app_error", err.Error())
@@ -147,3 +169,86 @@ func (self *SqlUploadJobStore) RemoveById(id int64) engine.AppError {
 
 	return nil
 }
+
+// Release returns the claimed job to the queue without counting the attempt
+func (self *SqlUploadJobStore) Release(id int64) engine.AppError {
+	_, err := self.GetMaster().Exec(`update storage.upload_file_jobs
+set state = 0,
+    attempts = greatest(attempts - 1, 0)
+where id = :Id and state = 1`, map[string]any{
+		"Id": id,
+	})
+
+	if err != nil {
+		return engine.NewCustomCodeError("store.sql_upload_job.release.app_error", err.Error(), extractCodeFromErr(err))
+	}
+
+	return nil
+}
+
+// ReleaseInstance returns to the queue the jobs left claimed by the previous run of the instance
+func (self *SqlUploadJobStore) ReleaseInstance(ctx context.Context, instance string) (int64, engine.AppError) {
+	res, err := self.GetMaster().WithContext(ctx).Exec(`update storage.upload_file_jobs
+set state = 0
+where instance = :Instance and state = 1`, map[string]any{
+		"Instance": instance,
+	})
+
+	if err != nil {
+		return 0, engine.NewCustomCodeError("store.sql_upload_job.release_instance.app_error", err.Error(), extractCodeFromErr(err))
+	}
+
+	cnt, _ := res.RowsAffected()
+
+	return cnt, nil
+}
+
+// Queue upload jobs of all instances by state
+func (self *SqlUploadJobStore) Queue(ctx context.Context) (*model.UploadJobsQueue, engine.AppError) {
+	var queue *model.UploadJobsQueue
+	err := self.GetReplica().WithContext(ctx).SelectOne(&queue, `select count(*) filter ( where t.state = 0 ) as pending,
+       count(*) filter ( where t.state = 1 ) as active,
+       count(*) filter ( where t.state = 0 and t.attempts > 0 ) as retrying
+from storage.upload_file_jobs t`)
+
+	if err != nil {
+		return nil, engine.NewCustomCodeError("store.sql_upload_job.queue.app_error", err.Error(), extractCodeFromErr(err))
+	}
+
+	return queue, nil
+}
+
+// DomainsLag pending upload and file jobs per domain, the most delayed domains first
+func (self *SqlUploadJobStore) DomainsLag(ctx context.Context) ([]*model.DomainJobsLag, engine.AppError) {
+	var lag []*model.DomainJobsLag
+	_, err := self.GetReplica().WithContext(ctx).Select(&lag, `with u as (
+    select t.domain_id,
+           count(*) as uploads,
+           (extract(epoch from now()) * 1000)::int8 - min(t.created_at) as upload_lag
+    from storage.upload_file_jobs t
+    where t.state = 0
+    group by t.domain_id
+), fj as (
+    select f.domain_id,
+           count(*) as file_jobs,
+           (extract(epoch from now() - min(j.created_at)) * 1000)::int8 as file_job_lag
+    from storage.file_jobs j
+        inner join storage.files f on f.id = j.file_id
+    where j.state = 0
+    group by f.domain_id
+)
+select coalesce(u.domain_id, fj.domain_id) as domain_id,
+       coalesce(u.uploads, 0) as uploads,
+       coalesce(u.upload_lag, 0) as upload_lag,
+       coalesce(fj.file_jobs, 0) as file_jobs,
+       coalesce(fj.file_job_lag, 0) as file_job_lag
+from u
+    full join fj on fj.domain_id = u.domain_id
+order by greatest(coalesce(u.upload_lag, 0), coalesce(fj.file_job_lag, 0)) desc`)
+
+	if err != nil {
+		return nil, engine.NewCustomCodeError("store.sql_upload_job.domains_lag.app_error", err.Error(), extractCodeFromErr(err))
+	}
+
+	return lag, nil
+}
@@ -56,25 +56,48 @@ type StoreData interface {
 	ImportTemplate() ImportTemplateStore
 	FilePolicies() FilePoliciesStore
 	SystemSettings() SystemSettingsStore
+	FileVariant() FileVariantStore
+
+	// Ping checks the connection of the master and each replica database
+	Ping(ctx context.Context) []*model.HealthCheck
 }
 
 type UploadJobStore interface {
 	Create(job *model.JobUploadFile) (*model.JobUploadFile, engine.AppError)
 	//Save(job *model.JobUploadFile) StoreChannel
 	GetAllPageByInstance(limit int, instance string) StoreChannel
-	UpdateWithProfile(limit int, instance string, betweenAttemptSec int64, defStore bool) StoreChannel
+	UpdateWithProfile(limit int, instance string, betweenAttemptSec int64, defStore bool, fair *model.FairShare) StoreChannel
 	SetStateError(id int, errMsg string) StoreChannel
 	RemoveById(id int64) engine.AppError
+	Release(id int64) engine.AppError
+	ReleaseInstance(ctx context.Context, instance string) (int64, engine.AppError)
+	Queue(ctx context.Context) (*model.UploadJobsQueue, engine.AppError)
+	DomainsLag(ctx context.Context) ([]*model.DomainJobsLag, engine.AppError)
 }
 
 type SyncFileStore interface {
-	FetchJobs(limit int) ([]*model.SyncJob, engine.AppError)
+	FetchJobs(action string, instance string, limit int, fair *model.FairShare) ([]*model.SyncJob, engine.AppError)
+	Release(jobId int64) engine.AppError
+	ReleaseInstance(ctx context.Context, instance string) (int64, engine.AppError)
+	Heartbeat(ctx context.Context, instance string) engine.AppError
+	ReclaimStale(ctx context.Context, staleMinutes int) (int64, engine.AppError)
 	SetRemoveJobs(localExpDay int) engine.AppError
 	Clean(jobId int64) engine.AppError
 	Remove(jobId int64) engine.AppError
 
-	RemoveErrors() engine.AppError
+	RemoveErrors(retentionHours int) engine.AppError
 	SetError(jobId int64, e error) engine.AppError
+
+	CreateThumbnailJobs(ctx context.Context, domainId int64, job *model.ThumbnailJob) (int64, engine.AppError)
+	SetTranscodeJobs(limit int) engine.AppError
+	CreateMetadataJobs(ctx context.Context, domainId int64, job *model.MediaMetadataJob) (int64, engine.AppError)
+	JobsProgress(ctx context.Context, domainId int64, action string) (*model.FileJobsProgress, engine.AppError)
+
+	Search(ctx context.Context, domainId int64, search *model.SearchFileJob) ([]*model.FileJob, engine.AppError)
+	Get(ctx context.Context, domainId int64, id int64) (*model.FileJob, engine.AppError)
+	Retry(ctx context.Context, domainId int64, ids []int64) (int64, engine.AppError)
+	Cancel(ctx context.Context, domainId int64, ids []int64) (int64, engine.AppError)
+	Queue(ctx context.Context, domainId int64) ([]*model.FileJobQueue, engine.AppError)
 }
 
 type FileBackendProfileStore interface {
@@ -98,6 +121,9 @@ type FileStore interface {
 	Metadata(domainId int64, id int64) (model.BaseFile, engine.AppError)
 
 	MoveFromJob(jobId int64, profileId *int, properties model.StringInterface, retentionUntil *time.Time) StoreChannel
+	SetThumbnail(ctx context.Context, id int64, thumbnail *model.Thumbnail) engine.AppError
+	MergeProperties(ctx context.Context, id int64, properties model.StringInterface) engine.AppError
+	ReplaceContent(ctx context.Context, id int64, oldName string, file *model.File) engine.AppError
 	CheckCallRecordPermissions(ctx context.Context, fileId int, currentUserId int64, domainId int64, groups []int) (bool, engine.AppError)
 }
 
@@ -106,6 +132,18 @@ type MediaFileStore interface {
 	GetAllPage(domainId int64, search *model.SearchMediaFile) ([]*model.MediaFile, engine.AppError)
 	Get(domainId int64, id int) (*model.MediaFile, engine.AppError)
 	Delete(domainId, id int64) engine.AppError
+	GetForMigration(ctx context.Context, domainId int64, profileId int, limit int) ([]*model.MediaFile, engine.AppError)
+	SetProfile(ctx context.Context, id int64, profileId *int, properties model.StringInterface) engine.AppError
+	GetFolders(ctx context.Context, domainId int64) ([]*model.MediaFolder, engine.AppError)
+	BulkUpdate(ctx context.Context, domainId int64, bulk *model.MediaFilesBulk, updatedBy *model.Lookup) (int64, engine.AppError)
+	AddPlaybacks(ctx context.Context, playbacks []*model.MediaPlayback) engine.AppError
+	GetReferences(ctx context.Context, domainId, id int64) ([]*model.MediaFileReference, engine.AppError)
+	AddReference(ctx context.Context, domainId int64, ref *model.MediaFileReference) engine.AppError
+	RemoveReference(ctx context.Context, domainId int64, ref *model.MediaFileReference) engine.AppError
+	GetVersions(ctx context.Context, domainId, id int64) ([]*model.MediaFileVersion, engine.AppError)
+	GetVersion(ctx context.Context, domainId, id int64, version int) (*model.MediaFileVersion, engine.AppError)
+	CreateVersion(ctx context.Context, version *model.MediaFileVersion) (*model.MediaFileVersion, engine.AppError)
+	SetActiveVersion(ctx context.Context, domainId, id int64, version int, updatedAt int64, updatedBy *model.Lookup) engine.AppError
 
 	Save(file *model.MediaFile) StoreChannel
 	GetAllByDomain(domain string, offset, limit int) StoreChannel
@@ -136,6 +174,11 @@ type JobStore interface {
 	GetNewestJobByStatusAndType(status string, jobType string) StoreChannel
 	GetCountByStatusAndType(status string, jobType string) StoreChannel
 	Delete(id string) StoreChannel
+
+	Search(ctx context.Context, search *model.SearchJob) ([]*model.Job, engine.AppError)
+	CreateScheduled(ctx context.Context, job *model.Job) (bool, engine.AppError)
+	GetLastScheduleTime(ctx context.Context, scheduleId int64) (int64, engine.AppError)
+	DeleteFinished(ctx context.Context, before int64) (int64, engine.AppError)
 }
 
 type CognitiveProfileStore interface {
@@ -182,6 +225,13 @@ type FilePoliciesStore interface {
 	SetRetentionDay(ctx context.Context, domainId int64, policy *model.FilePolicy) (int64, engine.AppError)
 }
 
+type FileVariantStore interface {
+	Get(ctx context.Context, fileId int64, key string) (*model.FileVariant, engine.AppError)
+	Save(ctx context.Context, variant *model.FileVariant) (*model.FileVariant, engine.AppError)
+	GetAllByFileId(ctx context.Context, fileId int64) ([]*model.FileVariant, engine.AppError)
+	DeleteByFileId(ctx context.Context, fileId int64) engine.AppError
+}
+
 type SystemSettingsStore interface {
 	ValueByName(ctx context.Context, domainId int64, name string) (engine.SysValue, engine.AppError)
 }
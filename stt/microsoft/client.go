@@ -16,6 +16,7 @@ import (
 	"time"
 
 	"github.com/webitel/storage/model"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 
 	"github.com/pkg/errors"
 )
@@ -116,7 +117,7 @@ func NewClient(config Config) (*client, error) {
 		key:       config.Key,
 		region:    config.Region,
 		cbUri:     config.Callback,
-		http:      http.Client{},
+		http:      http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)},
 		host:      fmt.Sprintf("https://%s.api.cognitive.microsoft.com", config.Region),
 		signature: hex.EncodeToString(h.Sum(nil)),
 	}
@@ -138,7 +139,7 @@ type Hook struct {
 func (c *client) Transcript(ctx context.Context, id int64, fileUri, locale string) (model.FileTranscript, error) {
 	var data []byte
 
-	task, err := c.TranscriptJob(id, fileUri, locale)
+	task, err := c.TranscriptJob(ctx, id, fileUri, locale)
 	if err != nil {
 		return model.FileTranscript{}, err
 	}
@@ -151,7 +152,7 @@ func (c *client) Transcript(ctx context.Context, id int64, fileUri, locale strin
 		return model.FileTranscript{}, errors.New(task.Properties.Error.Message)
 	}
 
-	data, err = c.LoadTranscript(task)
+	data, err = c.LoadTranscript(ctx, task)
 	if err != nil {
 		return model.FileTranscript{}, err
 	}
@@ -177,7 +178,7 @@ func (t Task) Finished() bool {
 	return t.Status == "Succeeded" || t.Status == "Failed"
 }
 
-func (c *client) TranscriptJob(fileId int64, fileUrl string, locale string) (*Task, error) {
+func (c *client) TranscriptJob(ctx context.Context, fileId int64, fileUrl string, locale string) (*Task, error) {
 
 	tr := &transcriptRequest{
 		ContentUrls: []string{fileUrl},
@@ -197,7 +198,7 @@ func (c *client) TranscriptJob(fileId int64, fileUrl string, locale string) (*Ta
 
 	data, _ := json.Marshal(tr)
 
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/speechtotext/v3.0/transcriptions", c.host), bytes.NewBuffer(data))
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/speechtotext/v3.0/transcriptions", c.host), bytes.NewBuffer(data))
 	if err != nil {
 		return nil, err
 	}
@@ -232,14 +233,14 @@ func (c *client) TranscriptJob(fileId int64, fileUrl string, locale string) (*Ta
 	return &t, nil
 }
 
-func (c *client) Finished(t *Task) (bool, error) {
+func (c *client) Finished(ctx context.Context, t *Task) (bool, error) {
 	// todo or error ?
 	var data []byte
 	if t.Finished() {
 		return true, nil
 	}
 
-	req, err := http.NewRequest("GET", t.Self, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", t.Self, nil)
 	if err != nil {
 		return false, err
 	}
@@ -264,8 +265,8 @@ func (c *client) Finished(t *Task) (bool, error) {
 	return t.Finished(), nil
 }
 
-func (c *client) LoadTranscript(t *Task) ([]byte, error) {
-	files, err := c.GetFiles(t)
+func (c *client) LoadTranscript(ctx context.Context, t *Task) ([]byte, error) {
+	files, err := c.GetFiles(ctx, t)
 	if err != nil {
 		return nil, err
 	}
@@ -277,7 +278,7 @@ func (c *client) LoadTranscript(t *Task) ([]byte, error) {
 	file := files[0]
 	var data []byte
 
-	req, err := http.NewRequest("GET", file.Links.ContentUrl, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", file.Links.ContentUrl, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -296,10 +297,10 @@ func (c *client) LoadTranscript(t *Task) ([]byte, error) {
 	return data, nil
 }
 
-func (c *client) GetFiles(t *Task) ([]*File, error) {
+func (c *client) GetFiles(ctx context.Context, t *Task) ([]*File, error) {
 	var data []byte
 
-	req, err := http.NewRequest("GET", t.Links.Files, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", t.Links.Files, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -332,7 +333,7 @@ func (c *client) WaitFoSuccess(ctx context.Context, t *Task) (ok bool, err error
 		case <-ctx.Done():
 			return
 		case <-time.After(time.Second * 10):
-			if ok, err = c.Finished(t); ok || err != nil {
+			if ok, err = c.Finished(ctx, t); ok || err != nil {
 				return
 			}
 		}
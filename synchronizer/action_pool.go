@@ -0,0 +1,81 @@
+package synchronizer
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/webitel/storage/app"
+	"github.com/webitel/storage/interfaces"
+	"github.com/webitel/storage/pool"
+)
+
+// actionPool workers of one synchronizer action; jobs are fetched only for free slots, so Exec does not block
+type actionPool struct {
+	app      *app.App
+	action   string
+	pool     interfaces.PoolInterface
+	capacity int64
+	queued   atomic.Int64
+	stopping atomic.Bool
+}
+
+type actionTask struct {
+	task    interfaces.TaskInterface
+	pool    *actionPool
+	release func()
+}
+
+func newActionPool(a *app.App, action string, workers int) *actionPool {
+	return &actionPool{
+		app:      a,
+		action:   action,
+		pool:     pool.NewPool(workers, workers),
+		capacity: int64(workers * 2),
+	}
+}
+
+// free number of jobs that can be taken
+func (p *actionPool) free() int {
+	n := p.capacity - p.queued.Load()
+	if n < 0 {
+		return 0
+	}
+
+	return int(n)
+}
+
+// exec release returns the job to the queue if the pool is stopped before it runs
+func (p *actionPool) exec(task interfaces.TaskInterface, release func()) {
+	p.queued.Add(1)
+	p.pool.Exec(&actionTask{
+		task:    task,
+		pool:    p,
+		release: release,
+	})
+}
+
+// stop queued jobs of the pool are not run; the channel is closed when the active ones finish
+func (p *actionPool) stop() <-chan struct{} {
+	p.stopping.Store(true)
+	p.pool.Close()
+
+	done := make(chan struct{})
+	go func() {
+		p.pool.Wait()
+		close(done)
+	}()
+
+	return done
+}
+
+func (t *actionTask) Execute() {
+	defer t.pool.queued.Add(-1)
+	if t.pool.stopping.Load() {
+		t.release()
+		return
+	}
+
+	start := time.Now()
+	t.task.Execute()
+	t.pool.app.ObserveFileJob(t.pool.action, time.Since(start))
+}
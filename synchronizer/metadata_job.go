@@ -0,0 +1,35 @@
+package synchronizer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/webitel/storage/app"
+	"github.com/webitel/storage/model"
+	"github.com/webitel/wlog"
+)
+
+type metadataJob struct {
+	file model.SyncJob
+	app  *app.App
+}
+
+func (s *metadataJob) Execute() {
+	n := time.Now()
+	meta, err := s.app.ExtractFileMetadata(context.Background(), s.file.DomainId, s.file.FileId)
+	if err != nil {
+		wlog.Error(fmt.Sprintf("[metadata] job_id: %d, file_id: %d, error: %s", s.file.Id, s.file.FileId, err.Error()))
+		if err = s.app.Store.SyncFile().SetError(s.file.Id, err); err != nil {
+			wlog.Error(err.Error())
+		}
+		return
+	}
+
+	if err = s.app.Store.SyncFile().Remove(s.file.Id); err != nil {
+		wlog.Error(fmt.Sprintf("[metadata] file %d, error: %s", s.file.FileId, err.Error()))
+	}
+
+	wlog.Debug(fmt.Sprintf("[metadata] job_id: %d, file_id: %d, %s %vs, time %v", s.file.Id, s.file.FileId,
+		meta.Codec, meta.Duration, time.Since(n)))
+}
@@ -1,6 +1,7 @@
 package synchronizer
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/webitel/storage/app"
@@ -35,6 +36,11 @@ func (j *removeFileJob) Execute() {
 		wlog.Error(fmt.Sprintf("file %d, error: %s", j.file.FileId, err.Error()))
 	}
 
+	err = j.app.RemoveFileVariants(context.Background(), store, j.file.FileId)
+	if err != nil {
+		wlog.Error(fmt.Sprintf("file %d, error: %s", j.file.FileId, err.Error()))
+	}
+
 	err = j.app.Store.SyncFile().Clean(j.file.Id)
 	if err != nil {
 		wlog.Error(fmt.Sprintf("file %d, error: %s", j.file.FileId, err.Error()))
@@ -1,57 +1,78 @@
 package synchronizer
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"time"
 
+	"github.com/juju/ratelimit"
 	engine "github.com/webitel/engine/model"
 	"github.com/webitel/storage/app"
 	"github.com/webitel/storage/interfaces"
 	"github.com/webitel/storage/model"
-	"github.com/webitel/storage/pool"
 	"github.com/webitel/wlog"
 )
 
+const (
+	transcodeScanInterval = time.Minute
+	heartbeatInterval     = time.Minute
+	reclaimInterval       = 5 * time.Minute
+)
+
 type synchronizer struct {
 	App             *app.App
-	limit           int
 	schedule        chan struct{}
 	pollingInterval time.Duration
 	stopSignal      chan struct{}
-	pool            interfaces.PoolInterface
+	pools           map[string]*actionPool
 	mx              sync.RWMutex
 	stopped         bool
+	thumbnailBucket *ratelimit.Bucket
 }
 
 func init() {
 	app.RegisterSynchronizer(func(a *app.App) interfaces.SynchronizerFilesInterface {
 		wlog.Debug("Initialize synchronizer")
+		var thumbnailBucket *ratelimit.Bucket
+		if rate := a.Config().Thumbnail.JobRate; rate > 0 {
+			thumbnailBucket = ratelimit.NewBucketWithRate(float64(rate), int64(rate))
+		}
+
+		pools := make(map[string]*actionPool)
+		for _, action := range model.SyncJobActions {
+			pools[action] = newActionPool(a, action, a.Config().FileJobs.Workers(action))
+		}
+
 		return &synchronizer{
 			App:             a,
-			limit:           100,
 			schedule:        make(chan struct{}, 1),
 			stopSignal:      make(chan struct{}),
 			pollingInterval: time.Second * 1,
-			pool:            pool.NewPool(5, 10), //FIXME added config
+			pools:           pools,
+			thumbnailBucket: thumbnailBucket,
 		}
 	})
 }
 
 func (s *synchronizer) Start() {
 	wlog.Debug("Run synchronizer")
+	// jobs that were running when the previous run crashed
+	if cnt, err := s.App.ReleaseInstanceFileJobs(context.Background()); err != nil {
+		wlog.Error(err.Error())
+	} else if cnt > 0 {
+		wlog.Info(fmt.Sprintf("released %d file jobs of the previous run", cnt))
+	}
 	go s.run()
 }
 
 func (s *synchronizer) run() {
-	var i int
+	var transcodeScan, heartbeat, reclaim time.Time
 	for {
 		select {
 		case <-s.schedule:
 		case <-time.After(s.pollingInterval):
-		start:
 			var err engine.AppError
-			var jobs []*model.SyncJob
 
 			if err = s.App.RemoveFileJobErrors(); err != nil {
 				wlog.Error(err.Error())
@@ -61,29 +82,29 @@ func (s *synchronizer) run() {
 				wlog.Error(err.Error())
 			}
 
-			jobs, err = s.App.FetchFileJobs(s.limit)
-			if err != nil {
-				wlog.Error(err.Error())
-				continue
-			}
-			count := len(jobs)
-
-			if count > 0 {
-				wlog.Debug(fmt.Sprintf("fetch %d file jobs", count))
-				for i = 0; i < count; i++ {
-					if task := s.getTask(jobs[i]); task != nil {
-						s.pool.Exec(task)
-					} else {
-						wlog.Error(fmt.Sprintf("bad job action: %v", jobs[i]))
-						s.App.Store.SyncFile().Remove(jobs[i].Id)
-					}
+			if time.Since(transcodeScan) > transcodeScanInterval {
+				transcodeScan = time.Now()
+				if err = s.App.SetTranscodeFileJobs(); err != nil {
+					wlog.Error(err.Error())
 				}
+			}
 
-				if count == s.limit && !s.isStopped() {
-					goto start
+			if time.Since(heartbeat) > heartbeatInterval {
+				heartbeat = time.Now()
+				if err = s.App.HeartbeatFileJobs(context.Background()); err != nil {
+					wlog.Error(err.Error())
 				}
 			}
 
+			if time.Since(reclaim) > reclaimInterval {
+				reclaim = time.Now()
+				s.reclaim()
+			}
+
+			for action, p := range s.pools {
+				s.fetch(action, p)
+			}
+
 		case <-s.stopSignal:
 			wlog.Debug("Synchronizer received stop signal.")
 			return
@@ -91,20 +112,86 @@ func (s *synchronizer) run() {
 	}
 }
 
+// fetch takes jobs of the action for the free slots of its workers
+func (s *synchronizer) fetch(action string, p *actionPool) {
+	for !s.isStopped() {
+		free := p.free()
+		if free == 0 {
+			return
+		}
+
+		jobs, err := s.App.FetchFileJobs(action, free)
+		if err != nil {
+			wlog.Error(err.Error())
+			return
+		}
+
+		if len(jobs) > 0 {
+			wlog.Debug(fmt.Sprintf("fetch %d %s file jobs", len(jobs), action))
+		}
+
+		for _, job := range jobs {
+			if task := s.getTask(job); task != nil {
+				p.exec(task, s.releaseFunc(job.Id))
+			} else {
+				wlog.Error(fmt.Sprintf("bad job action: %v", job))
+				s.App.Store.SyncFile().Remove(job.Id)
+			}
+		}
+
+		if len(jobs) < free {
+			return
+		}
+	}
+}
+
+// reclaim returns to the queue jobs of instances that stopped without releasing them
+func (s *synchronizer) reclaim() {
+	cnt, err := s.App.ReclaimStaleFileJobs(context.Background())
+	if err != nil {
+		wlog.Error(err.Error())
+		return
+	}
+
+	if cnt > 0 {
+		wlog.Info(fmt.Sprintf("reclaimed %d stale file jobs", cnt))
+	}
+}
+
+func (s *synchronizer) releaseFunc(jobId int64) func() {
+	return func() {
+		if err := s.App.ReleaseFileJob(jobId); err != nil {
+			wlog.Error(err.Error())
+		}
+	}
+}
+
 func (s *synchronizer) isStopped() bool {
 	s.mx.RLock()
 	defer s.mx.RUnlock()
 	return s.stopped
 }
 
-func (s *synchronizer) Stop() {
+func (s *synchronizer) Stop(ctx context.Context) {
 	s.mx.Lock()
 	s.stopped = true
 	s.mx.Unlock()
 
 	s.stopSignal <- struct{}{}
-	s.pool.Close()
-	s.pool.Wait()
+	stopped := make([]<-chan struct{}, 0, len(s.pools))
+	for _, p := range s.pools {
+		stopped = append(stopped, p.stop())
+	}
+
+	// active jobs not finished until the shutdown deadline are returned to the queue by the next instance run
+	for _, done := range stopped {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			wlog.Warn("Synchronizer stopped, active file jobs are not finished")
+			return
+		}
+	}
 	wlog.Debug("Synchronizer stopped.")
 }
 
@@ -122,6 +209,25 @@ func (s *synchronizer) getTask(src *model.SyncJob) interfaces.TaskInterface {
 			file: *src,
 		}
 
+	case model.SyncJobThumbnail:
+		return &thumbnailJob{
+			app:    s.App,
+			file:   *src,
+			bucket: s.thumbnailBucket,
+		}
+
+	case model.SyncJobTranscode:
+		return &transcodeJob{
+			app:  s.App,
+			file: *src,
+		}
+
+	case model.SyncJobMetadata:
+		return &metadataJob{
+			app:  s.App,
+			file: *src,
+		}
+
 	default:
 		return nil
 	}
@@ -0,0 +1,45 @@
+package synchronizer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/juju/ratelimit"
+	"github.com/webitel/storage/app"
+	"github.com/webitel/storage/model"
+	"github.com/webitel/wlog"
+)
+
+type thumbnailJob struct {
+	file   model.SyncJob
+	app    *app.App
+	bucket *ratelimit.Bucket
+}
+
+func (s *thumbnailJob) Execute() {
+	var p model.ThumbnailJobOptions
+	json.Unmarshal(s.file.Config, &p)
+
+	if s.bucket != nil {
+		s.bucket.Wait(1)
+	}
+
+	n := time.Now()
+	t, err := s.app.GenerateFileThumbnail(context.Background(), s.file.DomainId, s.file.FileId, p.Scale)
+	if err != nil {
+		wlog.Error(fmt.Sprintf("[thumbnail] job_id: %d, file_id: %d, error: %s", s.file.Id, s.file.FileId, err.Error()))
+		if err = s.app.Store.SyncFile().SetError(s.file.Id, err); err != nil {
+			wlog.Error(err.Error())
+		}
+		return
+	}
+
+	if err = s.app.Store.SyncFile().Remove(s.file.Id); err != nil {
+		wlog.Error(fmt.Sprintf("[thumbnail] file %d, error: %s", s.file.FileId, err.Error()))
+	}
+
+	wlog.Debug(fmt.Sprintf("[thumbnail] job_id: %d, file_id: %d created %s (%d bytes), time %v", s.file.Id, s.file.FileId,
+		t.Name, t.Size, time.Since(n)))
+}
@@ -0,0 +1,51 @@
+package synchronizer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/webitel/storage/app"
+	"github.com/webitel/storage/model"
+	"github.com/webitel/storage/transcoding"
+	"github.com/webitel/wlog"
+)
+
+type transcodeJob struct {
+	file model.SyncJob
+	app  *app.App
+}
+
+func (s *transcodeJob) Execute() {
+	var opts transcoding.AudioOptions
+	json.Unmarshal(s.file.Config, &opts)
+
+	n := time.Now()
+	ctx := context.Background()
+
+	err := s.app.TranscodeFile(ctx, s.file.DomainId, s.file.FileId, opts)
+	if err != nil {
+		wlog.Error(fmt.Sprintf("[transcode] job_id: %d, file_id: %d, error: %s", s.file.Id, s.file.FileId, err.Error()))
+		switch err.GetStatusCode() {
+		case http.StatusNotFound:
+			// the file was removed after the job was created, there is nothing to mark
+		default:
+			// the error mark keeps the policy from selecting the file again
+			s.app.Store.File().MergeProperties(ctx, s.file.FileId, model.StringInterface{
+				model.FilePropertyTranscodeError: err.Error(),
+			})
+		}
+		if err = s.app.Store.SyncFile().SetError(s.file.Id, err); err != nil {
+			wlog.Error(err.Error())
+		}
+		return
+	}
+
+	if err = s.app.Store.SyncFile().Remove(s.file.Id); err != nil {
+		wlog.Error(fmt.Sprintf("[transcode] file %d, error: %s", s.file.FileId, err.Error()))
+	}
+
+	wlog.Debug(fmt.Sprintf("[transcode] job_id: %d, file_id: %d to %s, time %v", s.file.Id, s.file.FileId, opts.Codec, time.Since(n)))
+}
@@ -1,102 +1,168 @@
-package main
+package transcoding
 
-// #cgo LDFLAGS: -lavcodec -lavformat -lavutil -lavdevice -lswresample
-//#include "audio.c"
-import "C"
 import (
-	"bytes"
-	"context"
-	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
-	"os"
-	"reflect"
-	"runtime"
-	"runtime/cgo"
-	"unsafe"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
 )
 
-/*
-	TODO
-	1. Read from memory https://stackoverflow.com/questions/19785254/play-a-video-from-memorystream-using-ffmpeg
-*/
+const (
+	CodecOpus = "opus"
+	CodecMp3  = "mp3"
+	CodecWav  = "wav"
 
-type transcoding struct {
-	rate int
-	w    io.WriteCloser
+	MinBitRate = 6   // kbps
+	MaxBitRate = 320 // kbps
+
+	MinSampleRate = 8000  // Hz
+	MaxSampleRate = 48000 // Hz
+)
+
+var (
+	ErrUnsupportedCodec = errors.New("not supported codec")
+)
+
+type codecInfo struct {
+	encoder        string
+	format         string
+	mimeType       string
+	extension      string
+	defaultBitRate int
 }
 
-func New(w io.WriteCloser, rate int) *transcoding {
-	return &transcoding{
-		rate: rate,
-		w:    w,
-	}
+var codecs = map[string]codecInfo{
+	CodecOpus: {encoder: "libopus", format: "ogg", mimeType: "audio/ogg", extension: ".ogg", defaultBitRate: 32},
+	CodecMp3:  {encoder: "libmp3lame", format: "mp3", mimeType: "audio/mpeg", extension: ".mp3", defaultBitRate: 64},
+	CodecWav:  {encoder: "pcm_s16le", format: "wav", mimeType: "audio/wav", extension: ".wav"},
+}
+
+// opus encodes only at these rates
+var opusSampleRates = []int{8000, 12000, 16000, 24000, 48000}
+
+// AudioOptions target codec, BitRate in kbps (0 - codec default),
+// SampleRate in Hz (0 - rate of the original)
+type AudioOptions struct {
+	Codec      string `json:"codec"`
+	BitRate    int    `json:"bit_rate"`
+	SampleRate int    `json:"sample_rate,omitempty"`
 }
 
-func (t *transcoding) Write(data []byte) (int, error) {
-	return t.w.Write(data)
+func IsSupportAudioCodec(codec string) bool {
+	_, ok := codecs[codec]
+	return ok
 }
 
-//export goFrameHandler
-func goFrameHandler(ctx C.uintptr_t, samples *C.uint8_t, clen C.int, cchannels C.int) {
-	if clen == 0 || cchannels == 0 {
-		return
+func (o AudioOptions) IsValid() error {
+	if !IsSupportAudioCodec(o.Codec) {
+		return fmt.Errorf("%w: %s", ErrUnsupportedCodec, o.Codec)
 	}
 
-	h := cgo.Handle(ctx)
-	v := h.Value()
-	tr, ok := v.(*transcoding)
-	if !ok {
-		panic(1)
+	if o.BitRate != 0 && (o.BitRate < MinBitRate || o.BitRate > MaxBitRate) {
+		return fmt.Errorf("bit rate %d, allowed %d-%d kbps", o.BitRate, MinBitRate, MaxBitRate)
 	}
 
-	//res := make([][]float32, cchannels, cchannels)
-	bufLen := (int)(clen)
-	//length := (int)(clen / cchannels)
-	//channels := (int)(cchannels)
-
-	var list []C.uint8_t
-	sliceHeader := (*reflect.SliceHeader)(unsafe.Pointer(&list))
-	sliceHeader.Cap = bufLen
-	sliceHeader.Len = bufLen
-	sliceHeader.Data = uintptr(unsafe.Pointer(samples))
-
-	//for i := 0; i < (int)(channels); i++ {
-	//	res[i] = make([]float32, length, length)
-	//}
-	//
-	//for k, sample := range list {
-	//	res[k%channels][(k-(k%channels))/channels] = (float32)(sample)
-	//}
-
-	buf := new(bytes.Buffer)
-	binary.Write(buf, binary.LittleEndian, list)
-	tr.Write(buf.Bytes())
-	fmt.Println("W ", len(buf.Bytes()))
+	if o.SampleRate != 0 {
+		if o.SampleRate < MinSampleRate || o.SampleRate > MaxSampleRate {
+			return fmt.Errorf("sample rate %d, allowed %d-%d Hz", o.SampleRate, MinSampleRate, MaxSampleRate)
+		}
+
+		if o.Codec == CodecOpus && !slices.Contains(opusSampleRates, o.SampleRate) {
+			return fmt.Errorf("sample rate %d, opus allowed %v Hz", o.SampleRate, opusSampleRates)
+		}
+	}
+
+	return nil
 }
 
-func (e *transcoding) Decode(ctx context.Context, path string) {
-	h := cgo.NewHandle(e)
-	C.transcode(C.uintptr_t(h), C.CString(path), C.int(e.rate))
-	h.Delete()
-	e.w.Close()
+func (o AudioOptions) MimeType() string {
+	return codecs[o.Codec].mimeType
 }
 
-func init() {
-	C.initTranscoding()
+func (o AudioOptions) Extension() string {
+	return codecs[o.Codec].extension
 }
 
-func main() {
+// FileName replaces the extension of name with the codec extension
+func (o AudioOptions) FileName(name string) string {
+	return strings.TrimSuffix(name, path.Ext(name)) + o.Extension()
+}
 
-	gfile, err := os.OpenFile("./out.pcm", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
-	if err != nil {
-		panic(err.Error())
+// bitRate 0 for uncompressed codecs
+func (o AudioOptions) bitRate() int {
+	c := codecs[o.Codec]
+	if o.BitRate > 0 && c.defaultBitRate > 0 {
+		return o.BitRate
 	}
 
-	t := New(gfile, 16000)
+	return c.defaultBitRate
+}
+
+// AudioArgs ffmpeg arguments to transcode audio from stdin to stdout, the channel count is kept
+func AudioArgs(o AudioOptions) ([]string, error) {
+	if err := o.IsValid(); err != nil {
+		return nil, err
+	}
 
-	t.Decode(context.TODO(), "https://dev.webitel.com/api/storage/recordings/69904/stream?access_token=ira4d61g4jb1jm7647eysbhohr")
+	args := []string{
+		"-hide_banner", "-loglevel", "error",
+		"-i", "pipe:0",
+		"-vn",
+		"-map_metadata", "-1",
+	}
+
+	return append(args, o.encodeArgs()...), nil
+}
+
+// encodeArgs codec and output format to stdout
+func (o AudioOptions) encodeArgs() []string {
+	c := codecs[o.Codec]
+	args := []string{"-acodec", c.encoder}
+
+	if br := o.bitRate(); br > 0 {
+		args = append(args, "-b:a", strconv.Itoa(br)+"k")
+	}
+
+	if o.SampleRate > 0 {
+		args = append(args, "-ar", strconv.Itoa(o.SampleRate))
+	}
+
+	return append(args, "-f", c.format, "pipe:1")
+}
+
+// AudioCodecOf codec for the audio processing result of the mime type: opus and wav are kept, others - mp3
+func AudioCodecOf(mime string) string {
+	switch mime {
+	case "audio/ogg", "audio/opus":
+		return CodecOpus
+	case "audio/wav", "audio/x-wav", "audio/wave":
+		return CodecWav
+	default:
+		return CodecMp3
+	}
+}
+
+func NewAudioReader(src io.Reader, o AudioOptions) (*Reader, error) {
+	args, err := AudioArgs(o)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewReader(src, args)
+}
 
-	gfile.Close()
-	runtime.GC()
+// NewPCMReader decodes audio into WAV PCM 16 bit at rate, the WAV header contains the channel count
+func NewPCMReader(src io.Reader, rate int) (*Reader, error) {
+	return NewReader(src, []string{
+		"-hide_banner", "-loglevel", "error",
+		"-i", "pipe:0",
+		"-vn",
+		"-acodec", "pcm_s16le",
+		"-ar", strconv.Itoa(rate),
+		"-f", "wav",
+		"pipe:1",
+	})
 }
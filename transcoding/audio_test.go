@@ -0,0 +1,56 @@
+package transcoding
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestAudioOptions(t *testing.T) {
+	o := AudioOptions{Codec: CodecOpus}
+	if o.FileName("record.wav") != "record.ogg" || o.MimeType() != "audio/ogg" {
+		t.Fatalf("bad opus output %s %s", o.FileName("record.wav"), o.MimeType())
+	}
+
+	args, err := AudioArgs(AudioOptions{Codec: CodecMp3, BitRate: 48})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args[len(args)-4] != "48k" {
+		t.Fatalf("bad args %v", args)
+	}
+
+	if _, err = AudioArgs(AudioOptions{Codec: "flac"}); !errors.Is(err, ErrUnsupportedCodec) {
+		t.Fatalf("expected unsupported codec, got %v", err)
+	}
+
+	if err = (AudioOptions{Codec: CodecOpus, BitRate: 1000}).IsValid(); err == nil {
+		t.Fatal("expected bit rate error")
+	}
+
+	args, err = AudioArgs(AudioOptions{Codec: CodecWav, BitRate: 48, SampleRate: 8000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args[len(args)-4] != "8000" || slices.Contains(args, "-b:a") {
+		t.Fatalf("bad wav args %v", args)
+	}
+
+	if err = (AudioOptions{Codec: CodecOpus, SampleRate: 44100}).IsValid(); err == nil {
+		t.Fatal("expected opus sample rate error")
+	}
+}
+
+func TestNormalizeArgs(t *testing.T) {
+	args, err := NormalizeArgs(DefaultLoudnessTarget, 8000, "/tmp/out.wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i := slices.Index(args, "-af"); i < 0 || args[i+1] != "loudnorm=I=-23:TP=-2:LRA=11" || args[len(args)-1] != "/tmp/out.wav" {
+		t.Fatalf("bad args %v", args)
+	}
+
+	if _, err = NormalizeArgs(0, 8000, "/tmp/out.wav"); err == nil {
+		t.Fatal("expected loudness error")
+	}
+}
@@ -1 +0,0 @@
-CGO_CFLAGS="-I/usr/include/x86_64-linux-gnu -g -O2 " go build -buildmode=plugin  audio.go
\ No newline at end of file
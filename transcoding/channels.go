@@ -0,0 +1,70 @@
+package transcoding
+
+import (
+	"fmt"
+	"io"
+)
+
+// MaxChannels the highest channel number + 1 that can be extracted
+const MaxChannels = 8
+
+// ExtractChannelArgs ffmpeg arguments to extract channel (from 0) as mono
+func ExtractChannelArgs(o AudioOptions, channel int) ([]string, error) {
+	if err := o.IsValid(); err != nil {
+		return nil, err
+	}
+
+	if channel < 0 || channel >= MaxChannels {
+		return nil, fmt.Errorf("channel %d, allowed 0-%d", channel, MaxChannels-1)
+	}
+
+	args := []string{
+		"-hide_banner", "-loglevel", "error",
+		"-i", "pipe:0",
+		"-vn",
+		"-map_metadata", "-1",
+		"-af", fmt.Sprintf("pan=mono|c0=c%d", channel),
+	}
+
+	return append(args, o.encodeArgs()...), nil
+}
+
+// MergeChannelsArgs ffmpeg arguments for stereo from two recordings: the first (stdin) - left channel, the second (fd 3) - right.
+// Multichannel inputs are downmixed to mono, the result duration is the longest input
+func MergeChannelsArgs(o AudioOptions) ([]string, error) {
+	if err := o.IsValid(); err != nil {
+		return nil, err
+	}
+
+	args := []string{
+		"-hide_banner", "-loglevel", "error",
+		"-i", "pipe:0",
+		"-i", "pipe:3",
+		"-filter_complex", "[0:a]aformat=channel_layouts=mono,pan=stereo|c0=c0[l];" +
+			"[1:a]aformat=channel_layouts=mono,pan=stereo|c1=c0[r];" +
+			"[l][r]amix=inputs=2:duration=longest:normalize=0[a]",
+		"-map", "[a]",
+		"-map_metadata", "-1",
+	}
+
+	return append(args, o.encodeArgs()...), nil
+}
+
+func NewExtractChannelReader(src io.Reader, o AudioOptions, channel int) (*Reader, error) {
+	args, err := ExtractChannelArgs(o, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewReader(src, args)
+}
+
+// NewMergeChannelsReader merges left and right into stereo
+func NewMergeChannelsReader(left, right io.Reader, o AudioOptions) (*Reader, error) {
+	args, err := MergeChannelsArgs(o)
+	if err != nil {
+		return nil, err
+	}
+
+	return newReader(left, args, right)
+}
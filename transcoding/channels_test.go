@@ -0,0 +1,31 @@
+package transcoding
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestChannelsArgs(t *testing.T) {
+	args, err := ExtractChannelArgs(AudioOptions{Codec: CodecWav}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i := slices.Index(args, "-af"); i < 0 || args[i+1] != "pan=mono|c0=c1" {
+		t.Fatalf("bad extract args %v", args)
+	}
+
+	if _, err = ExtractChannelArgs(AudioOptions{Codec: CodecWav}, MaxChannels); err == nil {
+		t.Fatal("expected channel error")
+	}
+
+	if args, err = MergeChannelsArgs(AudioOptions{Codec: CodecMp3}); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(args, "pipe:3") || args[len(args)-2] != "mp3" {
+		t.Fatalf("bad merge args %v", args)
+	}
+
+	if AudioCodecOf("audio/x-wav") != CodecWav || AudioCodecOf("audio/flac") != CodecMp3 {
+		t.Fatal("bad codec of mime type")
+	}
+}
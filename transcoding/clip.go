@@ -0,0 +1,90 @@
+package transcoding
+
+import (
+	"io"
+	"strconv"
+	"strings"
+)
+
+type clipFormat struct {
+	format    string
+	mimeType  string
+	extension string
+	codec     []string
+}
+
+// compressed audio is copied without transcoding, the cut precision is one codec frame (20-30 ms);
+// PCM is re-encoded into the same format to cut with sample precision
+var clipFormats = map[string]clipFormat{
+	"audio/mpeg":  {format: "mp3", mimeType: "audio/mpeg", extension: ".mp3", codec: []string{"-c:a", "copy"}},
+	"audio/mp3":   {format: "mp3", mimeType: "audio/mpeg", extension: ".mp3", codec: []string{"-c:a", "copy"}},
+	"audio/ogg":   {format: "ogg", mimeType: "audio/ogg", extension: ".ogg", codec: []string{"-c:a", "copy"}},
+	"audio/opus":  {format: "ogg", mimeType: "audio/ogg", extension: ".ogg", codec: []string{"-c:a", "copy"}},
+	"audio/webm":  {format: "webm", mimeType: "audio/webm", extension: ".webm", codec: []string{"-c:a", "copy"}},
+	"audio/aac":   {format: "adts", mimeType: "audio/aac", extension: ".aac", codec: []string{"-c:a", "copy"}},
+	"audio/wav":   {format: "wav", mimeType: "audio/wav", extension: ".wav", codec: []string{"-c:a", "pcm_s16le"}},
+	"audio/x-wav": {format: "wav", mimeType: "audio/wav", extension: ".wav", codec: []string{"-c:a", "pcm_s16le"}},
+	"audio/wave":  {format: "wav", mimeType: "audio/wav", extension: ".wav", codec: []string{"-c:a", "pcm_s16le"}},
+}
+
+var (
+	// other audio types are transcoded to mp3
+	clipAudioDefault = clipFormat{format: "mp3", mimeType: "audio/mpeg", extension: ".mp3", codec: []string{"-c:a", "libmp3lame", "-q:a", "4"}}
+	// video can be cut precisely only with transcoding, mp4 is written to a pipe as fragments
+	clipVideoDefault = clipFormat{format: "mp4", mimeType: "video/mp4", extension: ".mp4", codec: []string{
+		"-c:v", "libx264", "-preset", "veryfast",
+		"-c:a", "aac",
+		"-movflags", "frag_keyframe+empty_moov",
+	}}
+)
+
+func IsSupportClip(mime string) bool {
+	return strings.HasPrefix(mime, "audio/") || strings.HasPrefix(mime, "video/")
+}
+
+func clipFormatOf(mime string) clipFormat {
+	if f, ok := clipFormats[mime]; ok {
+		return f
+	}
+
+	if strings.HasPrefix(mime, "video/") {
+		return clipVideoDefault
+	}
+
+	return clipAudioDefault
+}
+
+// ClipMimeType result type of ClipArgs for a file of the mime type
+func ClipMimeType(mime string) string {
+	return clipFormatOf(mime).mimeType
+}
+
+// ClipExtension result extension of ClipArgs for a file of the mime type
+func ClipExtension(mime string) string {
+	return clipFormatOf(mime).extension
+}
+
+// ClipArgs ffmpeg arguments to cut the [start, end) seconds clip. -ss after -i, because a pipe input
+// does not support seeking: ffmpeg reads the stream from the start and discards data before start
+func ClipArgs(mime string, start, end float64) []string {
+	f := clipFormatOf(mime)
+	args := []string{
+		"-hide_banner", "-loglevel", "error",
+		"-i", "pipe:0",
+		"-ss", formatSec(start),
+		"-to", formatSec(end),
+		"-map_metadata", "-1",
+	}
+	args = append(args, f.codec...)
+
+	return append(args, "-f", f.format, "pipe:1")
+}
+
+// NewClipReader cuts a clip from src
+func NewClipReader(src io.Reader, mime string, start, end float64) (*Reader, error) {
+	return NewReader(src, ClipArgs(mime, start, end))
+}
+
+func formatSec(sec float64) string {
+	return strconv.FormatFloat(sec, 'f', 3, 64)
+}
@@ -0,0 +1,20 @@
+package transcoding
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestClipArgs(t *testing.T) {
+	args := ClipArgs("audio/mpeg", 1.5, 31)
+	if i := slices.Index(args, "-ss"); i < 0 || args[i+1] != "1.500" || args[i+3] != "31.000" {
+		t.Fatalf("bad range %v", args)
+	}
+	if !slices.Contains(args, "copy") {
+		t.Fatalf("mp3 must be copied %v", args)
+	}
+
+	if ClipMimeType("audio/x-wav") != "audio/wav" || ClipMimeType("audio/flac") != "audio/mpeg" || ClipMimeType("video/webm") != "video/mp4" {
+		t.Fatal("bad clip mime type")
+	}
+}
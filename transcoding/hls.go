@@ -0,0 +1,106 @@
+package transcoding
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	HLSMasterPlaylist = "hls_master.m3u8"
+	HLSSegmentSec     = 6
+
+	HLSPlaylistMimeType = "application/vnd.apple.mpegurl"
+	HLSSegmentMimeType  = "video/mp2t"
+)
+
+// HLSRendition one quality variant, bitrate in kbps; VideoHeight 0 - audio only
+type HLSRendition struct {
+	VideoHeight  int
+	VideoBitRate int
+	AudioBitRate int
+}
+
+var (
+	HLSAudioRenditions = []HLSRendition{
+		{AudioBitRate: 32},
+		{AudioBitRate: 96},
+	}
+	HLSVideoRenditions = []HLSRendition{
+		{VideoHeight: 360, VideoBitRate: 800, AudioBitRate: 64},
+		{VideoHeight: 720, VideoBitRate: 2500, AudioBitRate: 128},
+	}
+)
+
+// IsHLSFile the name belongs to HLS files: playlists and segments, without a path
+func IsHLSFile(name string) bool {
+	return strings.HasPrefix(name, "hls_") && !strings.ContainsAny(name, "/\\") &&
+		(strings.HasSuffix(name, ".m3u8") || strings.HasSuffix(name, ".ts"))
+}
+
+func HLSMimeType(name string) string {
+	if strings.HasSuffix(name, ".m3u8") {
+		return HLSPlaylistMimeType
+	}
+
+	return HLSSegmentMimeType
+}
+
+// HLSArgs ffmpeg arguments to segment stdin into HLS with several quality variants in the dir directory:
+// master playlist HLSMasterPlaylist, playlists hls_v<N>.m3u8 and segments hls_v<N>_<NNNNN>.ts
+func HLSArgs(dir string, video, audio bool) []string {
+	renditions := HLSAudioRenditions
+	if video {
+		renditions = HLSVideoRenditions
+	}
+
+	args := []string{
+		"-hide_banner", "-loglevel", "error",
+		"-i", "pipe:0",
+		"-map_metadata", "-1",
+	}
+
+	streams := make([]string, 0, len(renditions))
+	for i, r := range renditions {
+		var s []string
+		if video {
+			idx := strconv.Itoa(i)
+			args = append(args,
+				"-map", "0:v:0",
+				"-filter:v:"+idx, fmt.Sprintf("scale=-2:%d", r.VideoHeight),
+				"-c:v:"+idx, "libx264",
+				"-b:v:"+idx, strconv.Itoa(r.VideoBitRate)+"k",
+			)
+			s = append(s, "v:"+idx)
+		}
+		if audio {
+			idx := strconv.Itoa(i)
+			args = append(args,
+				"-map", "0:a:0",
+				"-c:a:"+idx, "aac",
+				"-b:a:"+idx, strconv.Itoa(r.AudioBitRate)+"k",
+			)
+			s = append(s, "a:"+idx)
+		}
+		streams = append(streams, strings.Join(s, ","))
+	}
+
+	if video {
+		// key frames at segment boundaries so variants switch without gaps
+		args = append(args,
+			"-preset", "veryfast",
+			"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", HLSSegmentSec),
+		)
+	}
+
+	return append(args,
+		"-var_stream_map", strings.Join(streams, " "),
+		"-f", "hls",
+		"-hls_time", strconv.Itoa(HLSSegmentSec),
+		"-hls_playlist_type", "vod",
+		"-hls_segment_filename", filepath.Join(dir, "hls_v%v_%05d.ts"),
+		"-master_pl_name", HLSMasterPlaylist,
+		filepath.Join(dir, "hls_v%v.m3u8"),
+	)
+}
@@ -0,0 +1,22 @@
+package transcoding
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestHLSArgs(t *testing.T) {
+	args := HLSArgs("/tmp/hls", true, true)
+	if i := slices.Index(args, "-var_stream_map"); i < 0 || args[i+1] != "v:0,a:0 v:1,a:1" {
+		t.Fatalf("bad stream map %v", args)
+	}
+
+	args = HLSArgs("/tmp/hls", false, true)
+	if i := slices.Index(args, "-var_stream_map"); i < 0 || args[i+1] != "a:0 a:1" || slices.Contains(args, "libx264") {
+		t.Fatalf("bad audio args %v", args)
+	}
+
+	if !IsHLSFile("hls_v0_00001.ts") || IsHLSFile("hls_../x.ts") || IsHLSFile("waveform_spp256.json") {
+		t.Fatal("bad hls file name check")
+	}
+}
@@ -0,0 +1,36 @@
+package transcoding
+
+import (
+	"strings"
+)
+
+// ThumbnailArgs ffmpeg arguments for a PNG thumbnail of an image or a video frame, nil - the type is not supported
+func ThumbnailArgs(mime string, scale string) []string {
+	if strings.HasPrefix(mime, "image/") {
+		return []string{
+			"-i", "pipe:0",
+			"-f", "image2pipe",
+			"-vcodec", "png",
+			"-pix_fmt", "rgba", // Pixel format
+			"-threads", "1",
+			"-vf", scale,
+			"pipe:1",
+		}
+	} else if strings.HasPrefix(mime, "video/") {
+		return []string{
+			"-err_detect", "ignore_err",
+			//"-f", "mp4", // Input file format
+			"-i", "pipe:0", // Use pipe:0 to read data from the io.Reader
+			"-ss", "00:00:01", // Seek 1 second
+			"-vframes", "1", // Capture only 1 frame
+			"-f", "image2pipe", // Output as image2pipe
+			"-vcodec", "png", // Output as PNG
+			"-pix_fmt", "rgba", // Pixel format
+			//"-threads", "1",
+			"-vf", scale,
+			"pipe:1", // pipe:1 to write to the io.Writer
+		}
+	}
+
+	return nil
+}
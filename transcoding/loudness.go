@@ -0,0 +1,44 @@
+package transcoding
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	// EBU R128
+	DefaultLoudnessTarget = -23
+
+	MinLoudnessTarget = -70
+	MaxLoudnessTarget = -5
+)
+
+// TelephonySampleRates rates at which FreeSWITCH plays files without conversion
+var TelephonySampleRates = []int{8000, 16000}
+
+// NormalizeArgs ffmpeg arguments to normalize loudness to loudness LUFS (loudnorm, single pass)
+// and convert to mono WAV PCM 16 bit at sampleRate. The result is written to the out file,
+// because a WAV header with the size can only be written to a file
+func NormalizeArgs(loudness, sampleRate int, out string) ([]string, error) {
+	if loudness < MinLoudnessTarget || loudness > MaxLoudnessTarget {
+		return nil, fmt.Errorf("loudness %d, allowed %d-%d LUFS", loudness, MinLoudnessTarget, MaxLoudnessTarget)
+	}
+
+	o := AudioOptions{Codec: CodecWav, SampleRate: sampleRate}
+	if err := o.IsValid(); err != nil {
+		return nil, err
+	}
+
+	return []string{
+		"-hide_banner", "-loglevel", "error",
+		"-i", "pipe:0",
+		"-vn",
+		"-map_metadata", "-1",
+		"-af", fmt.Sprintf("loudnorm=I=%d:TP=-2:LRA=11", loudness),
+		"-ac", "1",
+		"-ar", strconv.Itoa(sampleRate),
+		"-acodec", codecs[CodecWav].encoder,
+		"-f", codecs[CodecWav].format,
+		"-y", out,
+	}, nil
+}
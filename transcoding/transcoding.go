@@ -0,0 +1,120 @@
+// Package transcoding runs ffmpeg to convert media streams: audio codecs, PCM, thumbnails.
+// Input is passed via stdin, the result is read from stdout
+package transcoding
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+const (
+	ffmpegBin = "ffmpeg"
+)
+
+// Reader output of the ffmpeg process, Close waits for the process to exit and returns its error
+type Reader struct {
+	io.ReadCloser
+	cmd    *exec.Cmd
+	stderr bytes.Buffer
+	inputs sync.WaitGroup
+}
+
+// NewReader runs ffmpeg with args, src is passed to stdin
+func NewReader(src io.Reader, args []string) (*Reader, error) {
+	return newReader(src, args)
+}
+
+// newReader extra inputs are available in ffmpeg as pipe:3, pipe:4, ...
+func newReader(src io.Reader, args []string, extra ...io.Reader) (*Reader, error) {
+	cmd := exec.Command(ffmpegBin, args...)
+	cmd.Stdin = src
+
+	r := &Reader{
+		cmd: cmd,
+	}
+	cmd.Stderr = &r.stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	r.ReadCloser = stdout
+
+	writers := make([]*os.File, 0, len(extra))
+	defer func() {
+		// the read ends stay only in the ffmpeg process
+		for _, f := range cmd.ExtraFiles {
+			f.Close()
+		}
+	}()
+
+	for range extra {
+		pr, pw, err := os.Pipe()
+		if err != nil {
+			closeFiles(writers)
+			return nil, err
+		}
+		cmd.ExtraFiles = append(cmd.ExtraFiles, pr)
+		writers = append(writers, pw)
+	}
+
+	if err = cmd.Start(); err != nil {
+		closeFiles(writers)
+		return nil, err
+	}
+
+	for i, in := range extra {
+		r.inputs.Add(1)
+		go func(w *os.File, in io.Reader) {
+			defer r.inputs.Done()
+			// a write error means ffmpeg has finished reading
+			io.Copy(w, in)
+			w.Close()
+		}(writers[i], in)
+	}
+
+	return r, nil
+}
+
+func (r *Reader) Close() error {
+	r.ReadCloser.Close()
+	err := r.cmd.Wait()
+	r.inputs.Wait()
+
+	if err != nil {
+		if r.stderr.Len() > 0 {
+			return errors.New(strings.TrimSpace(r.stderr.String()))
+		}
+		return err
+	}
+
+	return nil
+}
+
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+// Run runs ffmpeg that writes the result to files, src is passed to stdin
+func Run(src io.Reader, args []string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command(ffmpegBin, args...)
+	cmd.Stdin = src
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if stderr.Len() > 0 {
+			return errors.New(strings.TrimSpace(stderr.String()))
+		}
+		return err
+	}
+
+	return nil
+}
@@ -1,6 +1,7 @@
 package tts
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -26,7 +27,7 @@ type ElevenLabsRequest struct {
 	ApplyTextNormalization *string                 `json:"apply_text_normalization,omitempty"`
 }
 
-func ElevenLabs(params TTSParams) (io.ReadCloser, *string, *int, error) {
+func ElevenLabs(ctx context.Context, params TTSParams) (io.ReadCloser, *string, *int, error) {
 	token := string(fixKey(params.Key))
 	voiceId := ""
 
@@ -85,7 +86,7 @@ func ElevenLabs(params TTSParams) (io.ReadCloser, *string, *int, error) {
 	url := fmt.Sprintf("https://api.elevenlabs.io/v1/text-to-speech/%s/stream%s", voiceId, outFormat)
 	payload := strings.NewReader(string(jsonData))
 
-	request, err := http.NewRequest("POST", url, payload)
+	request, err := http.NewRequestWithContext(ctx, "POST", url, payload)
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -93,7 +94,7 @@ func ElevenLabs(params TTSParams) (io.ReadCloser, *string, *int, error) {
 	request.Header.Add("Content-Type", "application/json")
 	request.Header.Add("xi-api-key", token)
 
-	res, err := http.DefaultClient.Do(request)
+	res, err := httpClient.Do(request)
 	if err != nil {
 		return nil, nil, nil, err
 	}
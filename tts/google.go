@@ -12,9 +12,8 @@ import (
 	texttospeechpb "google.golang.org/genproto/googleapis/cloud/texttospeech/v1"
 )
 
-func Google(params TTSParams) (io.ReadCloser, *string, *int, error) {
+func Google(ctx context.Context, params TTSParams) (io.ReadCloser, *string, *int, error) {
 	// Instantiates a client.
-	ctx := context.Background()
 	var err error
 	var client *texttospeech.Client
 
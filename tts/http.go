@@ -0,0 +1,10 @@
+package tts
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// httpClient passes the trace context to the synthesis providers
+var httpClient = &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)}
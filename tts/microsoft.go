@@ -2,6 +2,7 @@ package tts
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -12,10 +13,10 @@ import (
 	"github.com/webitel/wlog"
 )
 
-func Microsoft(req TTSParams) (io.ReadCloser, *string, *int, error) {
+func Microsoft(ctx context.Context, req TTSParams) (io.ReadCloser, *string, *int, error) {
 	var request *http.Request
 	var data string
-	token, err := microsoftToken(fixKey(req.Key), req.Region)
+	token, err := microsoftToken(ctx, fixKey(req.Key), req.Region)
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -32,7 +33,7 @@ func Microsoft(req TTSParams) (io.ReadCloser, *string, *int, error) {
 </speak>
 `, req.Language, req.BackgroundNode(), req.Language, req.Voice, microsoftLocalesNameMapping(req.Language, req.Voice), req.Text)
 
-	request, err = http.NewRequest("POST", fmt.Sprintf("https://%s.tts.speech.microsoft.com/cognitiveservices/v1", req.Region), bytes.NewBuffer([]byte(data)))
+	request, err = http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("https://%s.tts.speech.microsoft.com/cognitiveservices/v1", req.Region), bytes.NewBuffer([]byte(data)))
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -46,7 +47,7 @@ func Microsoft(req TTSParams) (io.ReadCloser, *string, *int, error) {
 		request.Header.Set("X-Microsoft-OutputFormat", "audio-16khz-32kbitrate-mono-mp3")
 	}
 
-	result, err := http.DefaultClient.Do(request)
+	result, err := httpClient.Do(request)
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -72,8 +73,8 @@ func Microsoft(req TTSParams) (io.ReadCloser, *string, *int, error) {
 	return result.Body, &contentType, nil, nil
 }
 
-func microsoftToken(key, region string) (string, error) {
-	req, err := http.NewRequest("POST", fmt.Sprintf("https://%s.api.cognitive.microsoft.com/sts/v1.0/issueToken", region), nil)
+func microsoftToken(ctx context.Context, key, region string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("https://%s.api.cognitive.microsoft.com/sts/v1.0/issueToken", region), nil)
 	if err != nil {
 		return "", err
 	}
@@ -81,7 +82,7 @@ func microsoftToken(key, region string) (string, error) {
 	req.Header.Set("Context-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("Ocp-Apim-Subscription-Key", key)
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := httpClient.Do(req)
 
 	if err != nil {
 		return "", err
@@ -1,6 +1,7 @@
 package tts
 
 import (
+	"context"
 	"fmt"
 	"io"
 
@@ -10,10 +11,11 @@ import (
 	"github.com/aws/aws-sdk-go/service/polly"
 )
 
-func Poly(req TTSParams) (io.ReadCloser, *string, *int, error) {
+func Poly(ctx context.Context, req TTSParams) (io.ReadCloser, *string, *int, error) {
 	config := &aws.Config{
 		Region:      aws.String("eu-west-1"),
 		Credentials: credentials.NewStaticCredentials(string(req.Key), req.Token, ""),
+		HTTPClient:  httpClient,
 	}
 
 	if req.Region != "" {
@@ -47,7 +49,7 @@ func Poly(req TTSParams) (io.ReadCloser, *string, *int, error) {
 		params.TextType = aws.String(req.TextType)
 	}
 
-	if out, err := p.SynthesizeSpeech(params); err != nil {
+	if out, err := p.SynthesizeSpeechWithContext(ctx, params); err != nil {
 		return nil, nil, nil, err
 	} else {
 		return out.AudioStream, out.ContentType, nil, nil
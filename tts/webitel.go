@@ -1,6 +1,7 @@
 package tts
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"net/url"
@@ -19,7 +20,7 @@ func SetWbtTTSEndpoint(endpoint string) {
 	wbtTTSEndpoint = endpoint
 }
 
-func Webitel(req TTSParams) (io.ReadCloser, *string, *int, error) {
+func Webitel(ctx context.Context, req TTSParams) (io.ReadCloser, *string, *int, error) {
 	req.Text = strings.TrimSpace(req.Text)
 	l := len(req.Text)
 
@@ -35,16 +36,14 @@ func Webitel(req TTSParams) (io.ReadCloser, *string, *int, error) {
 	u.Path = ttsWebitelResource
 	urlStr := u.String()
 
-	client := &http.Client{}
-
-	r, err := http.NewRequest(http.MethodPost, urlStr, strings.NewReader(strings.Replace(data.Encode(), "+", "%20", -1))) // URL-encoded payload
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, urlStr, strings.NewReader(strings.Replace(data.Encode(), "+", "%20", -1))) // URL-encoded payload
 	if err != nil {
 		return nil, nil, nil, err
 	}
 	r.Header.Add("Accept", "text/plain")
 	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 
-	result, err := client.Do(r)
+	result, err := httpClient.Do(r)
 	if err != nil {
 		return nil, nil, nil, err
 	}
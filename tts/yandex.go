@@ -1,13 +1,14 @@
 package tts
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 )
 
-func Yandex(params TTSParams) (io.ReadCloser, *string, *int, error) {
+func Yandex(ctx context.Context, params TTSParams) (io.ReadCloser, *string, *int, error) {
 	api := fmt.Sprintf("https://tts.api.cloud.yandex.net/speech/v1/tts:synthesize?lang=%s", url.QueryEscape(params.Language))
 
 	if params.Voice != "" {
@@ -20,10 +21,10 @@ func Yandex(params TTSParams) (io.ReadCloser, *string, *int, error) {
 		api += "&text=" + url.QueryEscape(params.Text)
 	}
 
-	request, err := http.NewRequest("POST", api, nil)
+	request, err := http.NewRequestWithContext(ctx, "POST", api, nil)
 	request.Header.Add("Authorization", fmt.Sprintf("Api-Key %s", params.Token))
 
-	result, err := http.DefaultClient.Do(request)
+	result, err := httpClient.Do(request)
 	if err != nil {
 		return nil, nil, nil, err
 	}
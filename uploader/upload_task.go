@@ -1,8 +1,13 @@
 package uploader
 
 import (
+	"context"
 	"fmt"
 	engine "github.com/webitel/engine/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"io"
 
 	"github.com/webitel/storage/utils"
@@ -12,10 +17,13 @@ import (
 	"github.com/webitel/wlog"
 )
 
+var tracer = otel.Tracer("github.com/webitel/storage/uploader")
+
 type UploadTask struct {
-	app *app.App
-	job *model.JobUploadFileWithProfile
-	log *wlog.Logger
+	app      *app.App
+	uploader *UploaderInterfaceImpl
+	job      *model.JobUploadFileWithProfile
+	log      *wlog.Logger
 }
 
 func (u *UploadTask) Name() string {
@@ -25,6 +33,28 @@ func (u *UploadTask) Name() string {
 //TODO added max count attempts ?
 
 func (u *UploadTask) Execute() {
+	if u.uploader.isStopped() {
+		u.release()
+		return
+	}
+
+	ctx, span := tracer.Start(context.Background(), "UploadTask.Execute", trace.WithAttributes(
+		attribute.Int64("job.id", u.job.Id),
+		attribute.Int("job.attempts", u.job.Attempts),
+		attribute.Int64("domain_id", u.job.DomainId),
+		attribute.String("file.uuid", u.job.Uuid),
+		attribute.Int64("file.size", u.job.Size),
+	))
+	defer span.End()
+
+	var err engine.AppError
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}()
+
 	store, err := u.app.GetFileBackendStore(u.job.ProfileId, u.job.ProfileUpdatedAt)
 
 	if err != nil {
@@ -34,7 +64,7 @@ func (u *UploadTask) Execute() {
 
 	u.log.Debug(fmt.Sprintf("start upload task %d [%s] to store %s", u.job.Id, u.Name(), store.Name()))
 
-	r, err := u.app.FileCache.Reader(u.job, 0)
+	r, err := u.app.FileReader(ctx, u.app.FileCache, u.job, 0)
 	if err != nil {
 		u.storeError(err)
 		return
@@ -56,14 +86,34 @@ func (u *UploadTask) Execute() {
 		},
 	}
 	var reader io.ReadCloser
-	reader, err = u.app.FilePolicyForUpload(f.DomainId, &f.BaseFile, r)
+	reader, err = u.app.FilePolicyForUpload(ctx, f.DomainId, &f.BaseFile, r)
 	if err != nil {
 		u.cancelUpload(err)
 		return
 	}
 	defer reader.Close()
 
-	if _, err = store.Write(reader, f); err != nil && err.GetId() != utils.ErrFileWriteExistsId {
+	var src io.Reader
+	if src, err = app.ReadUploadHead(reader); err != nil {
+		u.cancelUpload(err)
+		return
+	}
+
+	probe := u.app.NewMediaProbe(ctx, f.MimeType)
+	if probe != nil {
+		src = io.TeeReader(reader, probe)
+	}
+
+	_, err = u.app.WriteFile(ctx, store, src, f)
+	if probe != nil {
+		if err == nil {
+			u.app.ApplyMediaProbe(probe, f.Properties)
+		} else {
+			probe.Close()
+		}
+	}
+
+	if err != nil && err.GetId() != utils.ErrFileWriteExistsId {
 		if model.IsFilePolicyError(err) {
 			u.cancelUpload(err)
 		} else {
@@ -97,6 +147,15 @@ func (u *UploadTask) cancelUpload(err engine.AppError) {
 	u.removeCacheFile()
 }
 
+// release returns the job to the queue, the next instance run executes it
+func (u *UploadTask) release() {
+	if err := u.app.Store.UploadJob().Release(u.job.Id); err != nil {
+		u.log.Error(err.Error(), wlog.Err(err))
+		return
+	}
+	u.log.Debug(fmt.Sprintf("released upload task %d [%s]", u.job.Id, u.Name()))
+}
+
 func (u *UploadTask) removeCacheFile() {
 	if err := u.app.FileCache.Remove(u.job); err != nil {
 		u.log.Error(err.Error(),
@@ -1,6 +1,7 @@
 package uploader
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"time"
@@ -47,6 +48,12 @@ func init() {
 
 func (u *UploaderInterfaceImpl) Start() {
 	u.log.Debug("Run uploader")
+	// jobs that were running when the previous run crashed
+	if cnt, err := u.App.Store.UploadJob().ReleaseInstance(context.Background(), u.App.GetInstanceId()); err != nil {
+		u.log.Error(err.Error(), wlog.Err(err))
+	} else if cnt > 0 {
+		u.log.Info(fmt.Sprintf("released %d upload jobs of the previous run", cnt))
+	}
 	go u.run()
 }
 
@@ -60,7 +67,7 @@ func (u *UploaderInterfaceImpl) run() {
 		case <-u.schedule:
 		case <-time.After(u.pollingInterval):
 		start:
-			if result = <-u.App.Store.UploadJob().UpdateWithProfile(u.limit, u.App.GetInstanceId(), u.betweenAttemptSec, u.App.UseDefaultStore()); result.Err != nil {
+			if result = <-u.App.Store.UploadJob().UpdateWithProfile(u.limit, u.App.GetInstanceId(), u.betweenAttemptSec, u.App.UseDefaultStore(), u.App.Config().FairShare.FairShare()); result.Err != nil {
 				u.log.Critical(result.Err.Error(),
 					wlog.Err(result.Err),
 				)
@@ -73,9 +80,11 @@ func (u *UploaderInterfaceImpl) run() {
 				u.log.Debug(fmt.Sprintf("fetch %d jobs upload files", count))
 				for i = 0; i < count; i++ {
 					j := jobs[i]
+					u.App.ObserveUploadJob(j)
 					u.pool.Exec(&UploadTask{
-						app: u.App,
-						job: jobs[i],
+						app:      u.App,
+						uploader: u,
+						job:      jobs[i],
 						log: u.log.With(
 							wlog.Int64("file_id", j.Id),
 							wlog.String("call_id", j.Uuid), // TODO
@@ -100,13 +109,25 @@ func (u *UploaderInterfaceImpl) isStopped() bool {
 	return u.stopped
 }
 
-func (u *UploaderInterfaceImpl) Stop() {
+func (u *UploaderInterfaceImpl) Stop(ctx context.Context) {
 	u.mx.Lock()
 	u.stopped = true
 	u.mx.Unlock()
 
 	u.stopSignal <- struct{}{}
 	u.pool.Close()
-	u.pool.Wait()
-	u.log.Debug("Uploader stopped.")
+
+	// queued jobs of the pool go back to the database queue, active uploads are awaited until the shutdown deadline
+	wait := make(chan struct{})
+	go func() {
+		u.pool.Wait()
+		close(wait)
+	}()
+
+	select {
+	case <-wait:
+		u.log.Debug("Uploader stopped.")
+	case <-ctx.Done():
+		u.log.Warn("Uploader stopped, active uploads are not finished")
+	}
 }
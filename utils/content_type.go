@@ -0,0 +1,311 @@
+package utils
+
+import (
+	"bytes"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/h2non/filetype"
+	"github.com/h2non/filetype/matchers"
+	"github.com/h2non/filetype/types"
+)
+
+// ContentSniffLen number of bytes from the start of the file enough to detect
+// containers (OOXML/ODF in zip, MP4 ftyp brands) and to look for dangerous content
+const ContentSniffLen = 32 * 1024
+
+const (
+	ContentDangerExecutable = "executable"
+	ContentDangerScript     = "script"
+	ContentDangerMacro      = "macro"
+)
+
+const mimeOctetStream = "application/octet-stream"
+
+var (
+	typeOdt = filetype.NewType("odt", "application/vnd.oasis.opendocument.text")
+	typeOds = filetype.NewType("ods", "application/vnd.oasis.opendocument.spreadsheet")
+	typeOdp = filetype.NewType("odp", "application/vnd.oasis.opendocument.presentation")
+
+	executableTypes = map[types.Type]bool{
+		matchers.TypeExe:   true,
+		matchers.TypeElf:   true,
+		matchers.TypeMachO: true,
+		matchers.TypeDex:   true,
+		matchers.TypeDey:   true,
+	}
+
+	officeTypes = map[types.Type]bool{
+		matchers.TypeDoc:  true,
+		matchers.TypeXls:  true,
+		matchers.TypePpt:  true,
+		matchers.TypeDocx: true,
+		matchers.TypeXlsx: true,
+		matchers.TypePptx: true,
+		matchers.TypeZip:  true,
+	}
+
+	oleSignature = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+	// DangerousExtensions extensions blocked regardless of the declared Content-Type
+	DangerousExtensions = []string{
+		"exe", "dll", "com", "scr", "pif", "cpl", "msi", "msp", "mst", "sys", "drv",
+		"bat", "cmd", "ps1", "psm1", "vbs", "vbe", "js", "jse", "wsf", "wsh", "hta", "lnk",
+		"jar", "apk", "app", "dmg", "pkg", "deb", "rpm", "sh", "bash", "csh", "ksh", "run", "elf",
+		"reg", "inf", "scf", "docm", "xlsm", "pptm", "dotm", "xltm", "potm",
+	}
+
+	dangerousExtensions = make(map[string]struct{})
+
+	mimeAliases = [][]string{
+		{"audio/wav", "audio/x-wav", "audio/wave", "audio/vnd.wave"},
+		{"audio/mpeg", "audio/mp3", "audio/mpeg3", "audio/x-mpeg", "audio/x-mp3"},
+		{"audio/ogg", "audio/opus", "audio/vorbis", "audio/x-ogg", "application/ogg", "video/ogg"},
+		// webm is a matroska profile; MediaRecorder declares voice notes as audio/webm
+		{"audio/webm", "video/webm", "audio/x-matroska", "video/x-matroska", "video/matroska"},
+		{"audio/mp4", "audio/m4a", "audio/x-m4a", "video/mp4", "audio/aac"},
+		{"audio/x-flac", "audio/flac"},
+		{"image/jpeg", "image/jpg", "image/pjpeg"},
+		{"video/quicktime", "video/mp4"},
+		{"application/x-ole-storage", "application/msword", "application/vnd.ms-excel", "application/vnd.ms-powerpoint", "application/vnd.ms-outlook"},
+		{"application/zip", "application/x-zip-compressed", "application/x-zip"},
+	}
+
+	zipContainers = []string{
+		"application/vnd.openxmlformats-officedocument.",
+		"application/vnd.oasis.opendocument.",
+		"application/epub+zip",
+	}
+
+	textMimeTypes = []string{
+		"text/",
+		"application/json",
+		"application/xml",
+		"application/csv",
+		"application/x-ndjson",
+		"application/javascript",
+		"image/svg+xml",
+		"message/rfc822",
+	}
+
+	reHtmlScript = regexp.MustCompile(`(?i)(<script[\s>/]|javascript:|<[a-z][^>]*\son[a-z]+\s*=|<iframe[\s>]|<object[\s>]|<embed[\s>])`)
+	reHtml       = regexp.MustCompile(`(?i)^\s*(<!doctype\s+html|<html[\s>]|<head[\s>]|<body[\s>])`)
+	reSvg        = regexp.MustCompile(`(?i)<svg[\s>]`)
+)
+
+func init() {
+	for _, v := range DangerousExtensions {
+		dangerousExtensions[v] = struct{}{}
+	}
+
+	filetype.AddMatcher(typeOdt, odfMatcher(typeOdt))
+	filetype.AddMatcher(typeOds, odfMatcher(typeOds))
+	filetype.AddMatcher(typeOdp, odfMatcher(typeOdp))
+}
+
+// ContentType result of checking the start of the file
+type ContentType struct {
+	MimeType  string
+	Extension string
+	Text      bool
+	// Danger reason the content is blocked, empty if the content is safe
+	Danger string
+}
+
+// DetectContentType detects the content type from the first bytes of the file and looks for
+// executable code, scripts and macros
+func DetectContentType(head []byte) ContentType {
+	var res ContentType
+
+	if len(head) == 0 {
+		return res
+	}
+
+	kind, _ := filetype.Match(head)
+	if kind != filetype.Unknown {
+		res.MimeType = kind.MIME.Value
+		res.Extension = kind.Extension
+		if executableTypes[kind] {
+			res.Danger = ContentDangerExecutable
+		} else if officeTypes[kind] && hasMacro(head) {
+			res.Danger = ContentDangerMacro
+		}
+		return res
+	}
+
+	if bytes.HasPrefix(head, oleSignature) {
+		// old MS Office formats not recognized by filetype
+		res.MimeType = "application/x-ole-storage"
+		if hasMacro(head) {
+			res.Danger = ContentDangerMacro
+		}
+		return res
+	}
+
+	if bytes.HasPrefix(head, []byte("#!")) {
+		res.Danger = ContentDangerScript
+		return res
+	}
+
+	if !isText(head) {
+		return res
+	}
+
+	res.Text = true
+	switch {
+	case reSvg.Match(head) && !reHtml.Match(head):
+		res.MimeType = "image/svg+xml"
+		res.Extension = "svg"
+	case reHtml.Match(head):
+		res.MimeType = "text/html"
+		res.Extension = "html"
+	default:
+		res.MimeType = "text/plain"
+		res.Extension = "txt"
+	}
+
+	if res.Extension != "txt" && HasActiveContent(head) {
+		res.Danger = ContentDangerScript
+	}
+
+	return res
+}
+
+// HasActiveContent looks for scripts and event handlers in HTML/SVG
+func HasActiveContent(head []byte) bool {
+	return reHtmlScript.Match(head)
+}
+
+// IsMarkupMimeType types that a browser can run as a document
+func IsMarkupMimeType(mime string) bool {
+	mime = normalizeMime(mime)
+	return mime == "text/html" || mime == "application/xhtml+xml" || mime == "image/svg+xml" ||
+		mime == "text/xml" || mime == "application/xml"
+}
+
+// FileExtension returns the lower case file extension without the dot
+func FileExtension(name string) string {
+	ext := filepath.Ext(name)
+	if ext == "" {
+		return ""
+	}
+
+	return strings.ToLower(ext[1:])
+}
+
+func IsDangerousExtension(ext string) bool {
+	_, ok := dangerousExtensions[strings.ToLower(strings.TrimPrefix(ext, "."))]
+	return ok
+}
+
+// IsGenericMimeType returns true if the client did not specify a concrete type
+func IsGenericMimeType(mime string) bool {
+	mime = normalizeMime(mime)
+	return mime == "" || mime == mimeOctetStream || mime == "binary/octet-stream"
+}
+
+func IsTextMimeType(mime string) bool {
+	mime = normalizeMime(mime)
+	for _, v := range textMimeTypes {
+		if strings.HasPrefix(mime, v) {
+			return true
+		}
+	}
+
+	return strings.HasSuffix(mime, "+json") || strings.HasSuffix(mime, "+xml")
+}
+
+// MimeCompatible checks whether the declared Content-Type matches the one detected from the content
+func MimeCompatible(declared string, detected ContentType) bool {
+	declared = normalizeMime(declared)
+	mime := normalizeMime(detected.MimeType)
+
+	if declared == mime || (mime != "" && strings.HasPrefix(declared, mime)) {
+		return true
+	}
+
+	if detected.Text {
+		return IsTextMimeType(declared)
+	}
+
+	for _, group := range mimeAliases {
+		if inList(group, declared) && inList(group, mime) {
+			return true
+		}
+	}
+
+	if inList(mimeAliases[len(mimeAliases)-1], mime) {
+		// without the full archive directory OOXML/ODF are detected as a plain zip
+		for _, v := range zipContainers {
+			if strings.HasPrefix(declared, v) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func normalizeMime(mime string) string {
+	if i := strings.IndexByte(mime, ';'); i > -1 {
+		mime = mime[:i]
+	}
+
+	return strings.ToLower(strings.TrimSpace(mime))
+}
+
+func inList(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isText(head []byte) bool {
+	if bytes.IndexByte(head, 0) > -1 {
+		return false
+	}
+
+	// the last character may be cut by the buffer boundary
+	for i := 0; i < utf8.UTFMax && len(head) > 0; i++ {
+		if utf8.Valid(head) {
+			return true
+		}
+		head = head[:len(head)-1]
+	}
+
+	return false
+}
+
+// hasMacro looks for a VBA project in MS Office documents (OLE and OOXML)
+func hasMacro(head []byte) bool {
+	return bytes.Contains(head, []byte("vbaProject.bin")) ||
+		bytes.Contains(head, []byte("_VBA_PROJECT")) ||
+		bytes.Contains(head, utf16le("_VBA_PROJECT")) ||
+		bytes.Contains(head, utf16le("Macros"))
+}
+
+func utf16le(s string) []byte {
+	out := make([]byte, 0, len(s)*2)
+	for i := 0; i < len(s); i++ {
+		out = append(out, s[i], 0)
+	}
+
+	return out
+}
+
+// odfMatcher OpenDocument: a zip whose first entry is an uncompressed mimetype file
+func odfMatcher(t types.Type) matchers.Matcher {
+	sign := []byte("mimetype" + t.MIME.Value)
+
+	return func(buf []byte) bool {
+		return len(buf) > 30+len(sign) &&
+			buf[0] == 'P' && buf[1] == 'K' && buf[2] == 0x3 && buf[3] == 0x4 &&
+			bytes.HasPrefix(buf[30:], sign)
+	}
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestDetectContentType(t *testing.T) {
+	cases := []struct {
+		name     string
+		head     []byte
+		declared string
+		danger   string
+		ok       bool
+	}{
+		{"pe", []byte("MZ\x90\x00\x03\x00\x00\x00"), "image/png", ContentDangerExecutable, false},
+		{"elf", append([]byte("\x7fELF\x02\x01\x01\x00"), make([]byte, 64)...), "application/octet-stream", ContentDangerExecutable, false},
+		{"shebang", []byte("#!/bin/sh\nrm -rf /"), "text/plain", ContentDangerScript, false},
+		{"svg script", []byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`), "image/svg+xml", ContentDangerScript, true},
+		{"svg handler", []byte(`<svg onload="alert(1)"></svg>`), "image/svg+xml", ContentDangerScript, true},
+		{"svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"><rect/></svg>`), "image/svg+xml", "", true},
+		{"csv", []byte("id,name\n1,test\n"), "text/csv", "", true},
+		{"json", []byte(`{"a": 1}`), "application/json", "", true},
+		{"png as jpeg", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR"), "image/jpeg", "", false},
+		{"wav", []byte("RIFF\x24\x00\x00\x00WAVEfmt "), "audio/wav", "", true},
+		{"webm voice note", []byte("\x1a\x45\xdf\xa3\x9f\x42\x86\x81\x01\x42\x82\x84webm"), "audio/webm;codecs=opus", "", true},
+		{"webm video", []byte("\x1a\x45\xdf\xa3\x9f\x42\x86\x81\x01\x42\x82\x84webm"), "video/webm", "", true},
+		{"matroska audio", []byte("\x1a\x45\xdf\xa3\x9f\x42\x86\x81\x01\x42\x82\x88matroska"), "audio/x-matroska", "", true},
+		{"matroska as webm", []byte("\x1a\x45\xdf\xa3\x9f\x42\x86\x81\x01\x42\x82\x88matroska"), "audio/webm", "", true},
+		{"ogg opus", []byte("OggS\x00\x02\x00\x00\x00\x00\x00\x00\x00\x00OpusHead"), "audio/opus", "", true},
+		{"ogg video", []byte("OggS\x00\x02\x00\x00\x00\x00\x00\x00\x00\x00"), "video/ogg", "", true},
+		{"webm as mp3", []byte("\x1a\x45\xdf\xa3\x9f\x42\x86\x81\x01\x42\x82\x84webm"), "audio/mpeg", "", false},
+	}
+
+	for _, c := range cases {
+		ct := DetectContentType(c.head)
+		if ct.Danger != c.danger {
+			t.Errorf("%s: danger %q, expected %q", c.name, ct.Danger, c.danger)
+		}
+		if c.danger == "" && MimeCompatible(c.declared, ct) != c.ok {
+			t.Errorf("%s: declared %s, detected %s, expected compatible=%v", c.name, c.declared, ct.MimeType, c.ok)
+		}
+	}
+}
+
+func TestIsDangerousExtension(t *testing.T) {
+	if !IsDangerousExtension(FileExtension("invoice.PDF.exe")) {
+		t.Error("exe must be dangerous")
+	}
+	if IsDangerousExtension(FileExtension("record.mp3")) {
+		t.Error("mp3 must be allowed")
+	}
+}
@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// DiskFreePercent free space of the disk containing path, available to unprivileged users.
+// The directory may not exist yet, then its nearest existing parent is checked
+func DiskFreePercent(path string) (float64, error) {
+	var st syscall.Statfs_t
+	for {
+		err := syscall.Statfs(path, &st)
+		if err == nil {
+			break
+		}
+
+		parent := filepath.Dir(path)
+		if !os.IsNotExist(err) || parent == path {
+			return 0, err
+		}
+		path = parent
+	}
+
+	if st.Blocks == 0 {
+		return 0, nil
+	}
+
+	return float64(st.Bavail) / float64(st.Blocks) * 100, nil
+}
@@ -0,0 +1,275 @@
+package utils
+
+import (
+	"container/list"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	engine "github.com/webitel/engine/model"
+	"golang.org/x/sync/singleflight"
+)
+
+const readCacheTmpPrefix = ".load_"
+
+// VersionedFile version of the file content for the cache key, the size is used without it
+type VersionedFile interface {
+	GetVersion() int64
+}
+
+// ReadCache size limited directory of cached files shared by all CachedFileBackend of the directory;
+// least recently read files are removed when the size is exceeded
+type ReadCache struct {
+	directory string
+	maxSize   int64
+	mx        sync.Mutex
+	size      int64
+	order     *list.List
+	items     map[string]*list.Element
+}
+
+type readCacheEntry struct {
+	name string
+	size int64
+}
+
+// NewReadCache indexes files left in the directory by a previous run, oldest modified are evicted first.
+// maxSize 0 - no limit
+func NewReadCache(directory string, maxSize int64) *ReadCache {
+	c := &ReadCache{
+		directory: directory,
+		maxSize:   maxSize,
+		order:     list.New(),
+		items:     make(map[string]*list.Element),
+	}
+
+	type cached struct {
+		readCacheEntry
+		modTime int64
+	}
+	var files []cached
+
+	filepath.WalkDir(directory, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), readCacheTmpPrefix) {
+			os.Remove(path)
+			return nil
+		}
+		if info, e := d.Info(); e == nil {
+			files = append(files, cached{readCacheEntry{path, info.Size()}, info.ModTime().UnixNano()})
+		}
+		return nil
+	})
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime < files[j].modTime
+	})
+
+	c.mx.Lock()
+	for _, f := range files {
+		c.items[f.name] = c.order.PushFront(&f.readCacheEntry)
+		c.size += f.size
+	}
+	c.evict("")
+	c.mx.Unlock()
+
+	return c
+}
+
+// Size total size of the cached files
+func (c *ReadCache) Size() int64 {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	return c.size
+}
+
+func (c *ReadCache) touch(name string) {
+	c.mx.Lock()
+	if el, ok := c.items[name]; ok {
+		c.order.MoveToFront(el)
+	}
+	c.mx.Unlock()
+}
+
+func (c *ReadCache) add(name string, size int64) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if el, ok := c.items[name]; ok {
+		c.size -= el.Value.(*readCacheEntry).size
+		c.order.Remove(el)
+	}
+
+	c.items[name] = c.order.PushFront(&readCacheEntry{name: name, size: size})
+	c.size += size
+	c.evict(name)
+}
+
+// evict removes least recently read files until the size fits, keep - the file just added
+func (c *ReadCache) evict(keep string) {
+	if c.maxSize <= 0 {
+		return
+	}
+
+	for el := c.order.Back(); el != nil && c.size > c.maxSize; {
+		prev := el.Prev()
+		if e := el.Value.(*readCacheEntry); e.name != keep {
+			c.removeElement(el)
+		}
+		el = prev
+	}
+}
+
+// purge removes cached copies of all versions of the file except keep
+func (c *ReadCache) purge(dir, storeName, keep string) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	for name, el := range c.items {
+		if name != keep && filepath.Dir(name) == dir && isCachedVersion(filepath.Base(name), storeName) {
+			c.removeElement(el)
+		}
+	}
+}
+
+func (c *ReadCache) removeElement(el *list.Element) {
+	e := el.Value.(*readCacheEntry)
+	os.Remove(e.name)
+	c.order.Remove(el)
+	delete(c.items, e.name)
+	c.size -= e.size
+}
+
+// isCachedVersion name is <storeName>.<version>
+func isCachedVersion(name, storeName string) bool {
+	if !strings.HasPrefix(name, storeName+".") {
+		return false
+	}
+
+	_, err := strconv.ParseInt(name[len(storeName)+1:], 10, 64)
+	return err == nil
+}
+
+// CachedFileBackend local read cache of a remote backend: on the first read the file is downloaded
+// into the cache directory, next reads are served from disk
+type CachedFileBackend struct {
+	FileBackend
+	cache *ReadCache
+	group singleflight.Group
+}
+
+func NewCachedFileBackend(backend FileBackend, cache *ReadCache) *CachedFileBackend {
+	return &CachedFileBackend{
+		FileBackend: backend,
+		cache:       cache,
+	}
+}
+
+func (c *CachedFileBackend) Reader(file File, offset int64) (io.ReadCloser, engine.AppError) {
+	name := c.cachePath(file)
+
+	f, err := os.Open(name)
+	if err != nil {
+		if _, e, _ := c.group.Do(name, func() (interface{}, error) {
+			return nil, c.load(file, name)
+		}); e != nil {
+			// the cache is not available, read directly
+			return c.FileBackend.Reader(file, offset)
+		}
+
+		if f, err = os.Open(name); err != nil {
+			return c.FileBackend.Reader(file, offset)
+		}
+	} else {
+		c.cache.touch(name)
+	}
+
+	if offset > 0 {
+		if _, err = f.Seek(offset, io.SeekStart); err != nil {
+			f.Close()
+			return nil, engine.NewInternalError("utils.file.cached.seek.app_error", err.Error())
+		}
+	}
+
+	return f, nil
+}
+
+func (c *CachedFileBackend) Write(src io.Reader, file File) (int64, engine.AppError) {
+	c.Purge(file)
+	return c.FileBackend.Write(src, file)
+}
+
+func (c *CachedFileBackend) Remove(file File) engine.AppError {
+	c.Purge(file)
+	return c.FileBackend.Remove(file)
+}
+
+// Purge removes cached copies of the file for all its versions
+func (c *CachedFileBackend) Purge(file File) {
+	name := c.cachePath(file)
+	c.cache.purge(filepath.Dir(name), filepath.Base(file.GetStoreName()), "")
+}
+
+// Backend the backend without the cache
+func (c *CachedFileBackend) Backend() FileBackend {
+	return c.FileBackend
+}
+
+// load downloads the file into a temporary file of the cache directory and renames it, so readers never
+// see a partial file; copies of other versions of the file are no longer needed
+func (c *CachedFileBackend) load(file File, name string) error {
+	r, err := c.FileBackend.Reader(file, 0)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if e := os.MkdirAll(filepath.Dir(name), 0774); e != nil {
+		return e
+	}
+
+	tmp, e := os.CreateTemp(filepath.Dir(name), readCacheTmpPrefix+"*")
+	if e != nil {
+		return e
+	}
+
+	n, e := io.Copy(tmp, r)
+	if e == nil {
+		e = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+
+	if e == nil {
+		e = os.Rename(tmp.Name(), name)
+	}
+
+	if e != nil {
+		os.Remove(tmp.Name())
+		return e
+	}
+
+	c.cache.add(name, n)
+	c.cache.purge(filepath.Dir(name), filepath.Base(file.GetStoreName()), name)
+
+	return nil
+}
+
+func (c *CachedFileBackend) cachePath(file File) string {
+	version := file.GetSize()
+	if v, ok := file.(VersionedFile); ok {
+		version = v.GetVersion()
+	}
+
+	return filepath.Join(c.cache.directory, strconv.FormatInt(file.Domain(), 10),
+		fmt.Sprintf("%s.%d", filepath.Base(file.GetStoreName()), version))
+}
@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	engine "github.com/webitel/engine/model"
+	"github.com/webitel/storage/model"
+)
+
+type countBackend struct {
+	LocalFileBackend
+	data  []byte
+	reads int
+}
+
+func (b *countBackend) Reader(file File, offset int64) (io.ReadCloser, engine.AppError) {
+	b.reads++
+	return io.NopCloser(bytes.NewReader(b.data[offset:])), nil
+}
+
+func (b *countBackend) Remove(file File) engine.AppError {
+	return nil
+}
+
+func TestCachedFileBackend(t *testing.T) {
+	remote := &countBackend{data: []byte("0123456789")}
+	c := NewCachedFileBackend(remote, NewReadCache(t.TempDir(), 0))
+	f := &model.MediaFile{BaseFile: model.BaseFile{Name: "prompt.wav", Size: 10}}
+
+	for i := 0; i < 2; i++ {
+		r, err := c.Reader(f, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(r)
+		r.Close()
+		if string(data) != "456789" {
+			t.Fatalf("bad data %s", data)
+		}
+	}
+
+	if remote.reads != 1 {
+		t.Fatalf("expected one remote read, got %d", remote.reads)
+	}
+}
+
+func cachedMediaFile(name string, updatedAt int64) *model.MediaFile {
+	f := &model.MediaFile{BaseFile: model.BaseFile{Name: name, Size: 10}}
+	f.DomainId = 1
+	f.UpdatedAt = updatedAt
+	return f
+}
+
+func readAll(t *testing.T, c *CachedFileBackend, f File) {
+	r, err := c.Reader(f, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.ReadAll(r)
+	r.Close()
+}
+
+func TestCachedFileBackendEvict(t *testing.T) {
+	dir := t.TempDir()
+	remote := &countBackend{data: []byte("0123456789")}
+	c := NewCachedFileBackend(remote, NewReadCache(dir, 25))
+
+	a := cachedMediaFile("a.wav", 0)
+	b := cachedMediaFile("b.wav", 0)
+	d := cachedMediaFile("d.wav", 0)
+
+	readAll(t, c, a)
+	readAll(t, c, b)
+	readAll(t, c, a)
+	readAll(t, c, d)
+
+	if size := c.cache.Size(); size != 20 {
+		t.Fatalf("expected cache size 20, got %d", size)
+	}
+	if _, err := os.Stat(c.cachePath(b)); !os.IsNotExist(err) {
+		t.Fatal("least recently read file must be evicted")
+	}
+	if _, err := os.Stat(c.cachePath(a)); err != nil {
+		t.Fatal("recently read file must stay cached")
+	}
+
+	// the index is restored after restart
+	if size := NewReadCache(dir, 25).Size(); size != 20 {
+		t.Fatalf("expected restored cache size 20, got %d", size)
+	}
+}
+
+func TestCachedFileBackendPurge(t *testing.T) {
+	dir := t.TempDir()
+	remote := &countBackend{data: []byte("0123456789")}
+	c := NewCachedFileBackend(remote, NewReadCache(dir, 0))
+
+	f := cachedMediaFile("a.wav", 1)
+	other := cachedMediaFile("a.wav.1", 1)
+	readAll(t, c, f)
+	readAll(t, c, other)
+
+	updated := *f
+	updated.UpdatedAt = 2
+	readAll(t, c, &updated)
+
+	if _, err := os.Stat(c.cachePath(f)); !os.IsNotExist(err) {
+		t.Fatal("copy of the previous version must be removed")
+	}
+
+	if err := c.Remove(&updated); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "1", "*"))
+	if len(files) != 1 || files[0] != c.cachePath(other) {
+		t.Fatalf("expected only %s to stay cached, got %v", c.cachePath(other), files)
+	}
+}
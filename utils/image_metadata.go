@@ -0,0 +1,376 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"strings"
+	"sync"
+)
+
+const (
+	MetadataExif    = "exif"
+	MetadataXmp     = "xmp"
+	MetadataIptc    = "iptc"
+	MetadataComment = "comment"
+)
+
+const (
+	exifOrientationTag = 0x0112
+	maxMetadataChunk   = 1 << 20
+)
+
+var (
+	jpegExifHeader   = []byte("Exif\x00\x00")
+	jpegXmpHeader    = []byte("http://ns.adobe.com/xap/1.0/")
+	jpegXmpExtHeader = []byte("http://ns.adobe.com/xmp/extension/")
+	pngSignature     = []byte("\x89PNG\r\n\x1a\n")
+)
+
+// MetadataStripper removes EXIF/XMP/IPTC from images while the stream is read.
+// The EXIF orientation is kept in a minimal EXIF block so the image is displayed the same way
+type MetadataStripper struct {
+	pr  *io.PipeReader
+	pw  *io.PipeWriter
+	src *bufio.Reader
+
+	sync.Mutex
+	removed     []string
+	orientation uint16
+}
+
+func IsSupportStripMetadata(mime string) bool {
+	switch strings.ToLower(mime) {
+	case "image/jpeg", "image/jpg", "image/pjpeg", "image/png", "image/webp":
+		return true
+	}
+
+	return false
+}
+
+func NewMetadataStripper(mime string, src io.Reader) *MetadataStripper {
+	pr, pw := io.Pipe()
+	s := &MetadataStripper{
+		pr:  pr,
+		pw:  pw,
+		src: bufio.NewReaderSize(src, 64*1024),
+	}
+
+	go func() {
+		var err error
+		switch strings.ToLower(mime) {
+		case "image/png":
+			err = s.png()
+		case "image/webp":
+			err = s.webp()
+		default:
+			err = s.jpeg()
+		}
+
+		if err == nil {
+			_, err = io.Copy(pw, s.src)
+		}
+		pw.CloseWithError(err)
+	}()
+
+	return s
+}
+
+func (s *MetadataStripper) Read(p []byte) (int, error) {
+	return s.pr.Read(p)
+}
+
+func (s *MetadataStripper) Close() error {
+	return s.pr.Close()
+}
+
+// Removed returns the types of removed metadata, call after reading is finished
+func (s *MetadataStripper) Removed() []string {
+	s.Lock()
+	defer s.Unlock()
+	return s.removed
+}
+
+func (s *MetadataStripper) Orientation() uint16 {
+	s.Lock()
+	defer s.Unlock()
+	return s.orientation
+}
+
+func (s *MetadataStripper) remove(kind string) {
+	s.Lock()
+	defer s.Unlock()
+	for _, v := range s.removed {
+		if v == kind {
+			return
+		}
+	}
+	s.removed = append(s.removed, kind)
+}
+
+func (s *MetadataStripper) setOrientation(o uint16) {
+	s.Lock()
+	s.orientation = o
+	s.Unlock()
+}
+
+func (s *MetadataStripper) write(p ...[]byte) error {
+	for _, v := range p {
+		if _, err := s.pw.Write(v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *MetadataStripper) jpeg() error {
+	head, err := s.src.Peek(2)
+	if err != nil || head[0] != 0xFF || head[1] != 0xD8 {
+		return nil
+	}
+	if _, err = io.CopyN(s.pw, s.src, 2); err != nil {
+		return err
+	}
+
+	for {
+		marker, err := s.src.Peek(2)
+		if err != nil || marker[0] != 0xFF {
+			return nil
+		}
+
+		m := marker[1]
+		if m == 0xDA || m == 0xD9 || m == 0x01 || (m >= 0xD0 && m <= 0xD7) {
+			// image data follows, metadata segments can only come before SOS
+			return nil
+		}
+
+		head, err := s.src.Peek(4)
+		if err != nil {
+			return nil
+		}
+		size := int(binary.BigEndian.Uint16(head[2:])) - 2
+		if size < 0 {
+			return nil
+		}
+
+		switch m {
+		case 0xE1, 0xED, 0xFE:
+			seg := make([]byte, 4+size)
+			if _, err = io.ReadFull(s.src, seg); err != nil {
+				return err
+			}
+			payload := seg[4:]
+
+			switch {
+			case m == 0xED:
+				s.remove(MetadataIptc)
+			case m == 0xFE:
+				s.remove(MetadataComment)
+			case bytes.HasPrefix(payload, jpegExifHeader):
+				s.remove(MetadataExif)
+				if o := exifOrientation(payload[len(jpegExifHeader):]); o > 1 {
+					s.setOrientation(o)
+					tiff := orientationExif(o)
+					l := make([]byte, 2)
+					binary.BigEndian.PutUint16(l, uint16(2+len(jpegExifHeader)+len(tiff)))
+					if err = s.write([]byte{0xFF, 0xE1}, l, jpegExifHeader, tiff); err != nil {
+						return err
+					}
+				}
+			case bytes.HasPrefix(payload, jpegXmpHeader) || bytes.HasPrefix(payload, jpegXmpExtHeader):
+				s.remove(MetadataXmp)
+			default:
+				if err = s.write(seg); err != nil {
+					return err
+				}
+			}
+		default:
+			if _, err = io.CopyN(s.pw, s.src, int64(4+size)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *MetadataStripper) png() error {
+	head, err := s.src.Peek(len(pngSignature))
+	if err != nil || !bytes.Equal(head, pngSignature) {
+		return nil
+	}
+	if _, err = io.CopyN(s.pw, s.src, int64(len(pngSignature))); err != nil {
+		return err
+	}
+
+	for {
+		head, err = s.src.Peek(8)
+		if err != nil {
+			return nil
+		}
+		size := int64(binary.BigEndian.Uint32(head[:4]))
+		kind := string(head[4:8])
+
+		switch kind {
+		case "tEXt", "zTXt", "iTXt", "tIME":
+			s.remove(MetadataComment)
+			if _, err = s.src.Discard(int(12 + size)); err != nil {
+				return err
+			}
+		case "eXIf":
+			if size > maxMetadataChunk {
+				s.remove(MetadataExif)
+				if _, err = s.src.Discard(int(12 + size)); err != nil {
+					return err
+				}
+				continue
+			}
+			chunk := make([]byte, 12+size)
+			if _, err = io.ReadFull(s.src, chunk); err != nil {
+				return err
+			}
+			s.remove(MetadataExif)
+			if o := exifOrientation(chunk[8 : 8+size]); o > 1 {
+				s.setOrientation(o)
+				if err = s.write(pngChunk("eXIf", orientationExif(o))); err != nil {
+					return err
+				}
+			}
+		case "IEND":
+			return nil
+		default:
+			if _, err = io.CopyN(s.pw, s.src, 12+size); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// webp the RIFF container size is written in the header, so EXIF/XMP chunks are not removed
+// but turned into unknown zero-filled chunks, which decoders skip
+func (s *MetadataStripper) webp() error {
+	head, err := s.src.Peek(12)
+	if err != nil || string(head[:4]) != "RIFF" || string(head[8:12]) != "WEBP" {
+		return nil
+	}
+	if _, err = io.CopyN(s.pw, s.src, 12); err != nil {
+		return err
+	}
+
+	for {
+		head, err = s.src.Peek(8)
+		if err != nil {
+			return nil
+		}
+		kind := string(head[:4])
+		size := int64(binary.LittleEndian.Uint32(head[4:8]))
+		size += size & 1
+
+		switch kind {
+		case "VP8X":
+			if size > 64 {
+				return nil
+			}
+			chunk := make([]byte, 8+size)
+			if _, err = io.ReadFull(s.src, chunk); err != nil {
+				return err
+			}
+			if size > 0 {
+				chunk[8] &^= 0x08 | 0x04 // EXIF, XMP flags
+			}
+			if err = s.write(chunk); err != nil {
+				return err
+			}
+		case "EXIF", "XMP ":
+			if kind == "EXIF" {
+				s.remove(MetadataExif)
+			} else {
+				s.remove(MetadataXmp)
+			}
+			hdr := make([]byte, 8)
+			copy(hdr, "JUNK")
+			copy(hdr[4:], head[4:8])
+			s.src.Discard(8)
+			if _, err = s.src.Discard(int(size)); err != nil {
+				return err
+			}
+			if err = s.write(hdr); err != nil {
+				return err
+			}
+			if _, err = io.CopyN(s.pw, zeroReader{}, size); err != nil {
+				return err
+			}
+		default:
+			if _, err = io.CopyN(s.pw, s.src, 8+size); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// exifOrientation returns the Orientation tag value from the EXIF TIFF structure
+func exifOrientation(tiff []byte) uint16 {
+	if len(tiff) < 8 {
+		return 0
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	offset := int(order.Uint32(tiff[4:8]))
+	if offset+2 > len(tiff) {
+		return 0
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < count; i++ {
+		e := offset + 2 + i*12
+		if e+12 > len(tiff) {
+			return 0
+		}
+		if order.Uint16(tiff[e:]) == exifOrientationTag {
+			return order.Uint16(tiff[e+8:])
+		}
+	}
+
+	return 0
+}
+
+// orientationExif TIFF structure containing only the Orientation tag
+func orientationExif(o uint16) []byte {
+	b := make([]byte, 26)
+	copy(b, "II*\x00")
+	binary.LittleEndian.PutUint32(b[4:], 8)
+	binary.LittleEndian.PutUint16(b[8:], 1)
+	binary.LittleEndian.PutUint16(b[10:], exifOrientationTag)
+	binary.LittleEndian.PutUint16(b[12:], 3) // SHORT
+	binary.LittleEndian.PutUint32(b[14:], 1)
+	binary.LittleEndian.PutUint16(b[18:], o)
+	// next IFD = 0
+
+	return b
+}
+
+func pngChunk(kind string, data []byte) []byte {
+	b := make([]byte, 12+len(data))
+	binary.BigEndian.PutUint32(b, uint32(len(data)))
+	copy(b[4:], kind)
+	copy(b[8:], data)
+	binary.BigEndian.PutUint32(b[8+len(data):], crc32.ChecksumIEEE(b[4:8+len(data)]))
+
+	return b
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+func jpegSegment(marker byte, payload []byte) []byte {
+	b := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(b[2:], uint16(len(payload)+2))
+	return append(b, payload...)
+}
+
+func TestMetadataStripperJpeg(t *testing.T) {
+	exif := append([]byte("Exif\x00\x00"), orientationExif(6)...)
+	exif = append(exif, []byte("GPS 50.4501,30.5234 SERIAL-123")...)
+	image := []byte{0xFF, 0xDA, 0x00, 0x02, 0x01, 0x02, 0x03, 0xFF, 0xD9}
+
+	var src []byte
+	src = append(src, 0xFF, 0xD8)
+	src = append(src, jpegSegment(0xE0, []byte("JFIF\x00\x01\x02"))...)
+	src = append(src, jpegSegment(0xE1, exif)...)
+	src = append(src, jpegSegment(0xE1, []byte("http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta/>"))...)
+	src = append(src, jpegSegment(0xED, []byte("Photoshop 3.0\x00"))...)
+	src = append(src, image...)
+
+	s := NewMetadataStripper("image/jpeg", bytes.NewReader(src))
+	out, err := io.ReadAll(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(out, []byte("SERIAL")) || bytes.Contains(out, []byte("xmpmeta")) || bytes.Contains(out, []byte("Photoshop")) {
+		t.Fatal("metadata not removed")
+	}
+	if !bytes.HasSuffix(out, image) || !bytes.Contains(out, []byte("JFIF")) {
+		t.Fatal("image data corrupted")
+	}
+	if exifOrientation(out[bytes.Index(out, []byte("Exif\x00\x00"))+6:]) != 6 {
+		t.Fatal("orientation lost")
+	}
+	if len(s.Removed()) != 3 {
+		t.Fatalf("removed %v", s.Removed())
+	}
+}
+
+func TestMetadataStripperPng(t *testing.T) {
+	var src []byte
+	src = append(src, pngSignature...)
+	src = append(src, pngChunk("IHDR", make([]byte, 13))...)
+	src = append(src, pngChunk("tEXt", []byte("Author\x00secret"))...)
+	src = append(src, pngChunk("IDAT", []byte{1, 2, 3})...)
+	src = append(src, pngChunk("IEND", nil)...)
+
+	out, err := io.ReadAll(NewMetadataStripper("image/png", bytes.NewReader(src)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(out, []byte("secret")) || !bytes.HasSuffix(out, pngChunk("IEND", nil)) {
+		t.Fatal("bad png output")
+	}
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/webitel/storage/model"
+	"github.com/webitel/storage/transcoding"
+)
+
+func IsSupportImageVariant(mime string) bool {
+	return strings.HasPrefix(mime, "image/") && !strings.HasPrefix(mime, "image/svg")
+}
+
+// NewImageVariant runs ffmpeg that scales the image from src to the given variant
+func NewImageVariant(src io.Reader, v *model.ImageVariant) (*transcoding.Reader, error) {
+	return transcoding.NewReader(src, imageVariantArgs(v))
+}
+
+// imageVariantArgs the requested size is clamped to the size of the image, so smaller images are never upscaled
+func imageVariantArgs(v *model.ImageVariant) []string {
+	w := fmt.Sprintf("'min(iw,%d)'", v.Width)
+	h := fmt.Sprintf("'min(ih,%d)'", v.Height)
+
+	var scale string
+	switch {
+	case v.Width == 0:
+		scale = fmt.Sprintf("scale=-1:%s", h)
+	case v.Height == 0:
+		scale = fmt.Sprintf("scale=%s:-1", w)
+	case v.Fit == model.ImageFitCover:
+		scale = fmt.Sprintf("scale=%s:%s:force_original_aspect_ratio=increase,crop=%s:%s", w, h, w, h)
+	case v.Fit == model.ImageFitFill:
+		scale = fmt.Sprintf("scale=%s:%s", w, h)
+	default:
+		scale = fmt.Sprintf("scale=%s:%s:force_original_aspect_ratio=decrease", w, h)
+	}
+
+	args := []string{
+		"-hide_banner", "-loglevel", "error",
+		"-i", "pipe:0",
+		"-vf", scale,
+		"-frames:v", "1",
+		"-threads", "1",
+	}
+
+	switch v.Format {
+	case model.ImageFormatWebp:
+		args = append(args, "-vcodec", "libwebp", "-f", "webp")
+	case model.ImageFormatPng:
+		args = append(args, "-vcodec", "png", "-f", "image2pipe")
+	default:
+		args = append(args, "-vcodec", "mjpeg", "-q:v", "3", "-pix_fmt", "yuvj420p", "-f", "image2pipe")
+	}
+
+	return append(args, "pipe:1")
+}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/webitel/storage/model"
+)
+
+func TestImageVariantArgsNoUpscale(t *testing.T) {
+	cases := []struct {
+		variant model.ImageVariant
+		filter  string
+	}{
+		{model.ImageVariant{Width: 320}, "scale='min(iw,320)':-1"},
+		{model.ImageVariant{Height: 240}, "scale=-1:'min(ih,240)'"},
+		{model.ImageVariant{Width: 320, Height: 240, Fit: model.ImageFitContain},
+			"scale='min(iw,320)':'min(ih,240)':force_original_aspect_ratio=decrease"},
+		{model.ImageVariant{Width: 320, Height: 240, Fit: model.ImageFitCover},
+			"scale='min(iw,320)':'min(ih,240)':force_original_aspect_ratio=increase,crop='min(iw,320)':'min(ih,240)'"},
+		{model.ImageVariant{Width: 320, Height: 240, Fit: model.ImageFitFill}, "scale='min(iw,320)':'min(ih,240)'"},
+	}
+
+	for _, c := range cases {
+		args := imageVariantArgs(&c.variant)
+		var filter string
+		for i := range args {
+			if args[i] == "-vf" && i+1 < len(args) {
+				filter = args[i+1]
+			}
+		}
+
+		if filter != c.filter {
+			t.Errorf("%+v: filter %s, expected %s", c.variant, filter, c.filter)
+		}
+	}
+}
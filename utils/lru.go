@@ -3,6 +3,7 @@ package utils
 import (
 	"container/list"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -29,6 +30,8 @@ type Cache struct {
 	invalidateClusterEvent string
 	currentGeneration      int64
 	len                    int
+	hits                   atomic.Int64
+	misses                 atomic.Int64
 }
 
 // entry is used to hold a value in the evictList
@@ -114,16 +117,24 @@ func (c *Cache) Get(key interface{}) (value interface{}, ok bool) {
 
 		if e.generation != c.currentGeneration || (e.expireAtSecs > 0 && (time.Now().UnixNano()/int64(time.Second)) > e.expireAtSecs) {
 			c.removeElement(ent)
+			c.misses.Add(1)
 			return nil, false
 		}
 
 		c.evictList.MoveToFront(ent)
+		c.hits.Add(1)
 		return ent.Value.(*entry).value, true
 	}
 
+	c.misses.Add(1)
 	return nil, false
 }
 
+// Stats number of Get calls that found and did not find the key
+func (c *Cache) Stats() (hits, misses int64) {
+	return c.hits.Load(), c.misses.Load()
+}
+
 func (c *Cache) Remove(key interface{}) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
@@ -151,6 +162,23 @@ func (c *Cache) Keys() []interface{} {
 	return keys
 }
 
+// Values returns the values that are not expired, from oldest to newest, without affecting recency and stats.
+func (c *Cache) Values() []interface{} {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	now := time.Now().UnixNano() / int64(time.Second)
+	values := make([]interface{}, 0, c.len)
+	for ent := c.evictList.Back(); ent != nil; ent = ent.Prev() {
+		e := ent.Value.(*entry)
+		if e.generation == c.currentGeneration && (e.expireAtSecs == 0 || now <= e.expireAtSecs) {
+			values = append(values, e.value)
+		}
+	}
+
+	return values
+}
+
 // Len returns the number of items in the cache.
 func (c *Cache) Len() int {
 	c.lock.RLock()
@@ -0,0 +1,277 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/webitel/storage/model"
+)
+
+const (
+	// MediaProbeTimeout ffprobe is killed after it, the file is stored without metadata
+	MediaProbeTimeout = 2 * time.Minute
+	// mediaProbeCloseTimeout time to finish probing after the whole file has been written
+	mediaProbeCloseTimeout = 5 * time.Second
+	// mediaProbeBufferSize data not yet read by ffprobe; when exceeded the probe is abandoned
+	// instead of slowing down the upload
+	mediaProbeBufferSize = 4 * 1024 * 1024
+)
+
+var errMediaProbeAbandoned = errors.New("ffprobe is too slow, probe abandoned")
+
+// MediaProbe extracts technical data of audio or video with ffprobe while the file is being written.
+// ffprobe reads only the beginning of the stream, the rest of the data is dropped. Write never blocks
+// and never fails: data is fed to ffprobe from a bounded buffer by a separate goroutine
+type MediaProbe struct {
+	stdin   io.WriteCloser
+	cmd     *exec.Cmd
+	cancel  context.CancelFunc
+	stdout  bytes.Buffer
+	stderr  bytes.Buffer
+	size    int64
+	data    chan []byte
+	fed     chan struct{}
+	pending atomic.Int64
+	done    atomic.Bool
+	err     error
+	once    sync.Once
+}
+
+type probeStream struct {
+	CodecType   string `json:"codec_type"`
+	CodecName   string `json:"codec_name"`
+	SampleRate  string `json:"sample_rate"`
+	Channels    int    `json:"channels"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Duration    string `json:"duration"`
+	BitRate     string `json:"bit_rate"`
+	Disposition struct {
+		AttachedPic int `json:"attached_pic"`
+	} `json:"disposition"`
+}
+
+type probeResult struct {
+	Streams []probeStream `json:"streams"`
+	Format  struct {
+		Duration string `json:"duration"`
+		BitRate  string `json:"bit_rate"`
+	} `json:"format"`
+}
+
+func IsSupportMediaProbe(mime string) bool {
+	return strings.HasPrefix(mime, "audio/") || strings.HasPrefix(mime, "video/")
+}
+
+// NewMediaProbe starts ffprobe; it is killed when ctx is done or after MediaProbeTimeout
+func NewMediaProbe(ctx context.Context) (*MediaProbe, error) {
+	p := &MediaProbe{
+		data: make(chan []byte, 256),
+		fed:  make(chan struct{}),
+	}
+
+	ctx, p.cancel = context.WithTimeout(ctx, MediaProbeTimeout)
+	p.cmd = exec.CommandContext(ctx, "ffprobe",
+		"-hide_banner", "-loglevel", "error",
+		"-of", "json",
+		"-show_format", "-show_streams",
+		"-i", "pipe:0",
+	)
+	p.cmd.Stdout = &p.stdout
+	p.cmd.Stderr = &p.stderr
+
+	var err error
+	if p.stdin, err = p.cmd.StdinPipe(); err != nil {
+		p.cancel()
+		return nil, err
+	}
+
+	if err = p.cmd.Start(); err != nil {
+		p.cancel()
+		return nil, err
+	}
+
+	go p.feed()
+
+	return p, nil
+}
+
+func (p *MediaProbe) feed() {
+	defer close(p.fed)
+
+	for b := range p.data {
+		p.pending.Add(-int64(len(b)))
+		p.input().Write(b)
+	}
+}
+
+// input writer to ffprobe stdin that drops data once ffprobe has finished reading or has failed
+func (p *MediaProbe) input() io.Writer {
+	return probeInput{p}
+}
+
+type probeInput struct {
+	p *MediaProbe
+}
+
+func (w probeInput) Write(b []byte) (int, error) {
+	if !w.p.done.Load() {
+		if _, err := w.p.stdin.Write(b); err != nil {
+			w.p.done.Store(true)
+		}
+	}
+
+	return len(b), nil
+}
+
+// Write copies b to the buffer of ffprobe; once the probe is done, failed or the buffer is full, data is dropped
+func (p *MediaProbe) Write(b []byte) (int, error) {
+	p.size += int64(len(b))
+	if p.done.Load() {
+		return len(b), nil
+	}
+
+	if p.pending.Load()+int64(len(b)) > mediaProbeBufferSize {
+		p.abandon()
+		return len(b), nil
+	}
+
+	buf := make([]byte, len(b))
+	copy(buf, b)
+	p.pending.Add(int64(len(buf)))
+
+	select {
+	case p.data <- buf:
+	default:
+		p.pending.Add(-int64(len(buf)))
+		p.abandon()
+	}
+
+	return len(b), nil
+}
+
+// ReadFrom feeds ffprobe synchronously, used by io.Copy when the probe is the only consumer of the file.
+// The whole source is read, the size is needed to estimate the duration
+func (p *MediaProbe) ReadFrom(r io.Reader) (int64, error) {
+	p.closeInput()
+	<-p.fed
+
+	n, err := io.Copy(p.input(), r)
+	p.size += n
+
+	return n, err
+}
+
+// Close waits for ffprobe to finish and returns the result
+func (p *MediaProbe) Close() (*model.MediaMetadata, error) {
+	defer p.cancel()
+	p.closeInput()
+
+	timer := time.AfterFunc(mediaProbeCloseTimeout, p.cancel)
+	defer timer.Stop()
+
+	<-p.fed
+	p.stdin.Close()
+	if err := p.cmd.Wait(); err != nil {
+		if p.err != nil {
+			return nil, p.err
+		}
+		if p.stderr.Len() > 0 {
+			return nil, errors.New(strings.TrimSpace(p.stderr.String()))
+		}
+		return nil, err
+	}
+
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	return ParseMediaProbe(p.stdout.Bytes(), p.size)
+}
+
+// abandon stops probing of a stream ffprobe can not keep up with
+func (p *MediaProbe) abandon() {
+	p.err = errMediaProbeAbandoned
+	p.done.Store(true)
+	p.cancel()
+	p.closeInput()
+}
+
+func (p *MediaProbe) closeInput() {
+	p.once.Do(func() {
+		close(p.data)
+	})
+}
+
+// ParseMediaProbe parses the ffprobe JSON; if the duration is unknown (stream without an index),
+// it is estimated from the size and the bit rate
+func ParseMediaProbe(data []byte, size int64) (*model.MediaMetadata, error) {
+	var res probeResult
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, err
+	}
+
+	m := &model.MediaMetadata{}
+	var audio, video *probeStream
+	for i := range res.Streams {
+		s := &res.Streams[i]
+		switch {
+		case s.CodecType == "audio" && audio == nil:
+			audio = s
+		case s.CodecType == "video" && video == nil && s.Disposition.AttachedPic == 0:
+			video = s
+		}
+	}
+
+	if audio == nil && video == nil {
+		return nil, errors.New("no media streams")
+	}
+
+	if audio != nil {
+		m.Codec = audio.CodecName
+		m.Channels = audio.Channels
+		m.SampleRate, _ = strconv.Atoi(audio.SampleRate)
+	}
+
+	if video != nil {
+		m.Codec = video.CodecName
+		m.Width = video.Width
+		m.Height = video.Height
+	}
+
+	m.BitRate = parseInt(res.Format.BitRate)
+	if m.BitRate == 0 && audio != nil {
+		m.BitRate = parseInt(audio.BitRate)
+	}
+
+	m.Duration = parseFloat(res.Format.Duration)
+	if m.Duration == 0 && audio != nil {
+		m.Duration = parseFloat(audio.Duration)
+	}
+	if m.Duration == 0 && m.BitRate > 0 && size > 0 {
+		m.Duration = float64(size*8) / float64(m.BitRate)
+	}
+	m.Duration = math.Round(m.Duration*1000) / 1000
+
+	return m, nil
+}
+
+func parseInt(s string) int64 {
+	i, _ := strconv.ParseInt(s, 10, 64)
+	return i
+}
+
+func parseFloat(s string) float64 {
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
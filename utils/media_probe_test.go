@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseMediaProbe(t *testing.T) {
+	m, err := ParseMediaProbe([]byte(`{"streams":[
+		{"codec_type":"video","codec_name":"mjpeg","width":300,"height":300,"disposition":{"attached_pic":1}},
+		{"codec_type":"audio","codec_name":"mp3","sample_rate":"8000","channels":2,"bit_rate":"32000"}
+	],"format":{"bit_rate":"32000"}}`), 40000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Codec != "mp3" || m.Channels != 2 || m.SampleRate != 8000 || m.Width != 0 {
+		t.Fatalf("bad metadata %+v", m)
+	}
+
+	if m.Duration != 10 {
+		t.Fatalf("bad estimated duration %v", m.Duration)
+	}
+}
+
+func TestMediaProbeStuck(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "ffprobe"), []byte("#!/bin/sh\nexec sleep 60\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	p, err := NewMediaProbe(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	chunk := make([]byte, 32*1024)
+	for i := 0; i < 512; i++ {
+		if n, err := p.Write(chunk); err != nil || n != len(chunk) {
+			t.Fatalf("write must not fail: %d, %v", n, err)
+		}
+	}
+
+	if _, err = p.Close(); err == nil {
+		t.Fatal("expected error of the abandoned probe")
+	}
+
+	if d := time.Since(start); d > 3*time.Second {
+		t.Fatalf("stuck ffprobe blocked the writer for %s", d)
+	}
+}
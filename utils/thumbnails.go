@@ -5,6 +5,8 @@ import (
 	"io"
 	"os/exec"
 	"strings"
+
+	"github.com/webitel/storage/transcoding"
 )
 
 const (
@@ -28,7 +30,7 @@ func NewThumbnail(mime string, scale string) (*Thumbnail, error) {
 		scale = ThumbnailScale
 	}
 	scale = "scale=" + scale
-	cmdArgs := mimeCmdArgs(mime, scale)
+	cmdArgs := transcoding.ThumbnailArgs(mime, scale)
 	if cmdArgs == nil {
 		return nil, errors.New("not supported")
 	}
@@ -48,6 +50,20 @@ func NewThumbnail(mime string, scale string) (*Thumbnail, error) {
 	}, nil
 }
 
+// NewThumbnailReader creates a thumbnail from src (a file in the storage), PNG is read from the result,
+// Close returns the ffmpeg error
+func NewThumbnailReader(src io.Reader, mime string, scale string) (*transcoding.Reader, error) {
+	if scale == "" {
+		scale = ThumbnailScale
+	}
+	cmdArgs := transcoding.ThumbnailArgs(mime, "scale="+scale)
+	if cmdArgs == nil {
+		return nil, errors.New("not supported")
+	}
+
+	return transcoding.NewReader(src, cmdArgs)
+}
+
 func (t *Thumbnail) Write(p []byte) (nn int, err error) {
 	if t.end {
 		return len(p), nil // TODO wait if io.EOF
@@ -88,36 +104,6 @@ func (t *Thumbnail) Scale() string {
 	return t.scale
 }
 
-func mimeCmdArgs(mime string, scale string) []string {
-	if strings.HasPrefix(mime, "image/") {
-		return []string{
-			"-i", "pipe:0",
-			"-f", "image2pipe",
-			"-vcodec", "png",
-			"-pix_fmt", "rgba", // Формат пікселів
-			"-threads", "1",
-			"-vf", scale,
-			"pipe:1",
-		}
-	} else if strings.HasPrefix(mime, "video/") {
-		return []string{
-			"-err_detect", "ignore_err",
-			//"-f", "mp4", // Вказуємо формат вхідного файлу
-			"-i", "pipe:0", // Використання pipe:0 для отримання даних з io.Reader
-			"-ss", "00:00:01", // Затримка 2 секунди
-			"-vframes", "1", // Захопити лише 1 кадр
-			"-f", "image2pipe", // Вивід у форматі image2pipe
-			"-vcodec", "png", // Виведення у форматі PNG
-			"-pix_fmt", "rgba", // Формат пікселів
-			//"-threads", "1",
-			"-vf", scale,
-			"pipe:1", // pipe:1 для виводу у io.Writer
-		}
-	}
-
-	return nil
-}
-
 func IsSupportThumbnail(mimeType string) bool {
 	return strings.HasPrefix(mimeType, "video/") || strings.HasPrefix(mimeType, "image/")
 }
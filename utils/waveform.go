@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/webitel/storage/transcoding"
+)
+
+const (
+	waveformMaxChannels = 8
+	waveformMaxChunk    = 1 << 20
+)
+
+// Waveform audio peaks in the audiowaveform JSON format (version 2): for each pixel
+// min and max of each channel in turn
+type Waveform struct {
+	Version         int    `json:"version"`
+	Channels        int    `json:"channels"`
+	SampleRate      int    `json:"sample_rate"`
+	SamplesPerPixel int    `json:"samples_per_pixel"`
+	Bits            int    `json:"bits"`
+	Length          int    `json:"length"`
+	Data            []int8 `json:"data"`
+}
+
+func IsSupportWaveform(mime string) bool {
+	return strings.HasPrefix(mime, "audio/") || strings.HasPrefix(mime, "video/")
+}
+
+// NewWaveform decodes src with ffmpeg into PCM at sampleRate and computes the peaks of each channel
+func NewWaveform(src io.Reader, sampleRate, samplesPerPixel int) (*Waveform, error) {
+	r, err := transcoding.NewPCMReader(src, sampleRate)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := ReadWaveform(r, samplesPerPixel)
+	if err != nil {
+		// ffmpeg waits until the output is read
+		io.Copy(io.Discard, r)
+	}
+
+	if e := r.Close(); e != nil {
+		return nil, e
+	}
+
+	return w, err
+}
+
+// ReadWaveform computes the peaks from a WAV (PCM 16 bit) stream
+func ReadWaveform(src io.Reader, samplesPerPixel int) (*Waveform, error) {
+	if samplesPerPixel <= 0 {
+		return nil, errors.New("bad samples per pixel")
+	}
+
+	r := bufio.NewReader(src)
+	channels, sampleRate, err := readWavHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Waveform{
+		Version:         2,
+		Channels:        channels,
+		SampleRate:      sampleRate,
+		SamplesPerPixel: samplesPerPixel,
+		Bits:            8,
+	}
+
+	frame := make([]byte, 2*channels)
+	min := make([]int16, channels)
+	max := make([]int16, channels)
+	n := 0
+
+	for {
+		if _, err = io.ReadFull(r, frame); err != nil {
+			break
+		}
+
+		for c := 0; c < channels; c++ {
+			s := int16(binary.LittleEndian.Uint16(frame[2*c:]))
+			if n == 0 || s < min[c] {
+				min[c] = s
+			}
+			if n == 0 || s > max[c] {
+				max[c] = s
+			}
+		}
+
+		if n++; n == samplesPerPixel {
+			w.appendPeaks(min, max)
+			n = 0
+		}
+	}
+
+	if err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+
+	if n > 0 {
+		w.appendPeaks(min, max)
+	}
+	w.Length = len(w.Data) / (2 * channels)
+
+	return w, nil
+}
+
+func (w *Waveform) appendPeaks(min, max []int16) {
+	for c := range min {
+		w.Data = append(w.Data, int8(min[c]>>8), int8(max[c]>>8))
+	}
+}
+
+// readWavHeader reads the header up to the start of the data; the data size is not checked because ffmpeg
+// does not know it when writing to a pipe
+func readWavHeader(r *bufio.Reader) (channels int, sampleRate int, err error) {
+	head := make([]byte, 12)
+	if _, err = io.ReadFull(r, head); err != nil {
+		return 0, 0, err
+	}
+
+	if string(head[:4]) != "RIFF" || string(head[8:]) != "WAVE" {
+		return 0, 0, errors.New("not a wav stream")
+	}
+
+	chunk := make([]byte, 8)
+	for {
+		if _, err = io.ReadFull(r, chunk); err != nil {
+			return 0, 0, err
+		}
+
+		size := binary.LittleEndian.Uint32(chunk[4:])
+		switch string(chunk[:4]) {
+		case "data":
+			if channels == 0 {
+				return 0, 0, errors.New("wav: data before fmt")
+			}
+			return channels, sampleRate, nil
+
+		case "fmt ":
+			if size < 16 || size > waveformMaxChunk {
+				return 0, 0, fmt.Errorf("wav: bad fmt size %d", size)
+			}
+			f := make([]byte, size+size%2)
+			if _, err = io.ReadFull(r, f); err != nil {
+				return 0, 0, err
+			}
+
+			format := binary.LittleEndian.Uint16(f[0:])
+			channels = int(binary.LittleEndian.Uint16(f[2:]))
+			sampleRate = int(binary.LittleEndian.Uint32(f[4:]))
+			bits := binary.LittleEndian.Uint16(f[14:])
+
+			// 0xFFFE - WAVE_FORMAT_EXTENSIBLE, ffmpeg uses it for more than 2 channels
+			if (format != 1 && format != 0xFFFE) || bits != 16 {
+				return 0, 0, fmt.Errorf("wav: not supported format %d, %d bits", format, bits)
+			}
+			if channels < 1 || channels > waveformMaxChannels {
+				return 0, 0, fmt.Errorf("wav: not supported channels %d", channels)
+			}
+
+		default:
+			if size > waveformMaxChunk {
+				return 0, 0, fmt.Errorf("wav: bad chunk size %d", size)
+			}
+			if _, err = r.Discard(int(size + size%2)); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func testWav(channels int, samples [][]int16) []byte {
+	var b bytes.Buffer
+	b.WriteString("RIFF\xff\xff\xff\xffWAVE")
+	b.WriteString("fmt ")
+	binary.Write(&b, binary.LittleEndian, struct {
+		Size             uint32
+		Format, Channels uint16
+		Rate, ByteRate   uint32
+		Align, Bits      uint16
+	}{16, 1, uint16(channels), 8000, uint32(8000 * 2 * channels), uint16(2 * channels), 16})
+	b.WriteString("LIST\x02\x00\x00\x00ab")
+	b.WriteString("data\xff\xff\xff\xff")
+	for _, frame := range samples {
+		binary.Write(&b, binary.LittleEndian, frame)
+	}
+
+	return b.Bytes()
+}
+
+func TestReadWaveform(t *testing.T) {
+	src := testWav(2, [][]int16{
+		{-256, 1024}, {512, -32768}, {0, 0},
+		{32767, 0},
+	})
+
+	w, err := ReadWaveform(bytes.NewReader(src), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w.Channels != 2 || w.SampleRate != 8000 || w.Length != 2 {
+		t.Fatalf("bad header %+v", w)
+	}
+
+	expected := []int8{-1, 2, -128, 4, 127, 127, 0, 0}
+	if !bytes.Equal(int8Bytes(w.Data), int8Bytes(expected)) {
+		t.Fatalf("data %v, expected %v", w.Data, expected)
+	}
+}
+
+func int8Bytes(src []int8) []byte {
+	b := make([]byte, len(src))
+	for i, v := range src {
+		b[i] = byte(v)
+	}
+	return b
+}
@@ -4,14 +4,22 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/gorilla/mux"
 	engine "github.com/webitel/engine/model"
 	"github.com/webitel/storage/app"
 	"github.com/webitel/storage/controller"
 	"github.com/webitel/storage/model"
 	"github.com/webitel/storage/utils"
 	"github.com/webitel/wlog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/webitel/storage/web")
+
 type Handler struct {
 	App            *app.App
 	Ctrl           *controller.Controller
@@ -25,6 +33,17 @@ type Handler struct {
 func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	wlog.Debug(fmt.Sprintf("%v - %v", r.Method, r.URL.Path))
 
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := tracer.Start(ctx, spanName(r),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("url.path", r.URL.Path),
+		),
+	)
+	defer span.End()
+	r = r.WithContext(ctx)
+
 	c := &Context{}
 	c.App = h.App
 	c.Ctrl = h.Ctrl
@@ -84,7 +103,23 @@ func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			c.LogError(c.Err)
 		}
 
+		span.SetAttributes(attribute.Int("http.response.status_code", c.Err.GetStatusCode()))
+		if c.Err.GetStatusCode() >= http.StatusInternalServerError {
+			span.RecordError(c.Err)
+			span.SetStatus(codes.Error, c.Err.Error())
+		}
+
 		w.WriteHeader(c.Err.GetStatusCode())
 		w.Write([]byte(c.Err.ToJson()))
 	}
 }
+
+func spanName(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			return r.Method + " " + tpl
+		}
+	}
+
+	return r.Method
+}
@@ -0,0 +1,29 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/webitel/storage/app"
+	"github.com/webitel/storage/model"
+)
+
+// InitHealth liveness and readiness probes, without a session
+func InitHealth(a *app.App, root *mux.Router) {
+	root.HandleFunc("/health/live", func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, a.Liveness())
+	}).Methods("GET")
+
+	root.HandleFunc("/health/ready", func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, a.Readiness(r.Context()))
+	}).Methods("GET")
+}
+
+func writeHealth(w http.ResponseWriter, h *model.Health) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if !h.Up() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	w.Write([]byte(h.ToJson()))
+}